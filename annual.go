@@ -0,0 +1,62 @@
+package main
+
+// spreadAnnual splits cents over n months as evenly as possible. The first months take the
+// leftover cents so the shares add up to cents exactly.
+func spreadAnnual(cents int64, n int) []int64 {
+	if n <= 0 {
+		return nil
+	}
+	out := make([]int64, n)
+	base, extra := cents/int64(n), cents%int64(n)
+	for i := range out {
+		out[i] = base
+		if int64(i) < extra {
+			out[i]++
+		}
+	}
+	return out
+}
+
+// annualShares spreads an annual plan over the open months from fromMonth to December.
+// closedLimits maps each closed month to the limit the category already has in it; those limits
+// count towards annualCents, so only the rest is spread and the year adds up to the plan (unless
+// the closed months already hold more).
+func annualShares(annualCents int64, fromMonth int, closedLimits map[int]int64) (months []int, shares []int64) {
+	remaining := annualCents
+	for month := fromMonth; month <= 12; month++ {
+		if limit, closed := closedLimits[month]; closed {
+			remaining -= limit
+		} else {
+			months = append(months, month)
+		}
+	}
+	return months, spreadAnnual(max(remaining, 0), len(months))
+}
+
+// AnnualCategory is a category's year: its limits and spending over the twelve months against
+// the annual plan, if one is set.
+type AnnualCategory struct {
+	CategoryTrend
+	PlanCents int64
+	HasPlan   bool
+}
+
+// annualCategories joins the year's category trends with the annual plan by name. Planned
+// categories with no budget yet are listed too, so a new plan shows straight away.
+func annualCategories(report BudgetReport, plan []AnnualPlanItem) []AnnualCategory {
+	var out []AnnualCategory
+	index := map[string]int{}
+	for _, c := range report.Categories {
+		index[envelopeKey(c.Name)] = len(out)
+		out = append(out, AnnualCategory{CategoryTrend: c})
+	}
+	for _, p := range plan {
+		i, ok := index[envelopeKey(p.CategoryName)]
+		if !ok {
+			i = len(out)
+			out = append(out, AnnualCategory{CategoryTrend: CategoryTrend{Name: p.CategoryName}})
+		}
+		out[i].PlanCents, out[i].HasPlan = p.AnnualCents, true
+	}
+	return out
+}
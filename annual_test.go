@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpreadAnnual(t *testing.T) {
+	tests := []struct {
+		cents int64
+		n     int
+		want  []int64
+	}{
+		{1200_00, 12, []int64{100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00}},
+		{1000_00, 3, []int64{333_34, 333_33, 333_33}},
+		{5, 3, []int64{2, 2, 1}},
+		{2, 3, []int64{1, 1, 0}},
+		{0, 2, []int64{0, 0}},
+		{500_00, 1, []int64{500_00}},
+		{500_00, 0, nil},
+	}
+	for _, tt := range tests {
+		got := spreadAnnual(tt.cents, tt.n)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spreadAnnual(%d, %d) = %v, want %v", tt.cents, tt.n, got, tt.want)
+		}
+		var sum int64
+		for _, c := range got {
+			sum += c
+		}
+		if tt.n > 0 && sum != tt.cents {
+			t.Errorf("spreadAnnual(%d, %d) adds up to %d", tt.cents, tt.n, sum)
+		}
+	}
+}
+
+func TestAnnualCategories(t *testing.T) {
+	report := BudgetReport{Categories: []CategoryTrend{{Name: "Groceries"}, {Name: "Vacation"}}}
+	plan := []AnnualPlanItem{
+		{CategoryName: " vacation", AnnualCents: 3000_00},
+		{CategoryName: "Property tax", AnnualCents: 4200_00},
+	}
+	got := annualCategories(report, plan)
+	want := []struct {
+		name    string
+		plan    int64
+		hasPlan bool
+	}{
+		{"Groceries", 0, false},
+		{"Vacation", 3000_00, true},
+		{"Property tax", 4200_00, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].PlanCents != w.plan || got[i].HasPlan != w.hasPlan {
+			t.Errorf("category %d: got %s %d %v, want %s %d %v", i, got[i].Name, got[i].PlanCents, got[i].HasPlan, w.name, w.plan, w.hasPlan)
+		}
+	}
+}
+
+func TestAnnualShares(t *testing.T) {
+	tests := []struct {
+		name       string
+		annual     int64
+		from       int
+		closed     map[int]int64
+		wantMonths []int
+		wantShares []int64
+	}{
+		{"whole year", 1200_00, 1, nil, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+			[]int64{100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00, 100_00}},
+		{"from October", 1000_00, 10, nil, []int{10, 11, 12}, []int64{333_34, 333_33, 333_33}},
+		// A closed month's limit comes off the plan before the rest is spread
+		{"closed month with a limit", 1200_00, 10, map[int]int64{11: 300_00}, []int{10, 12}, []int64{450_00, 450_00}},
+		{"closed month without the category", 900_00, 10, map[int]int64{10: 0}, []int{11, 12}, []int64{450_00, 450_00}},
+		{"closed months hold more than the plan", 500_00, 11, map[int]int64{11: 600_00}, []int{12}, []int64{0}},
+		{"every month closed", 500_00, 12, map[int]int64{12: 100_00}, nil, nil},
+		{"closed months before the plan are ignored", 300_00, 10, map[int]int64{3: 999_00}, []int{10, 11, 12}, []int64{100_00, 100_00, 100_00}},
+	}
+	for _, tt := range tests {
+		months, shares := annualShares(tt.annual, tt.from, tt.closed)
+		if !reflect.DeepEqual(months, tt.wantMonths) || !reflect.DeepEqual(shares, tt.wantShares) {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, months, shares, tt.wantMonths, tt.wantShares)
+		}
+	}
+}
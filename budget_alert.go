@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAlertThreshold caps alert thresholds at ten times the limit.
+const maxAlertThreshold = 1000
+
+// parseAlertThresholds reads percents such as "80, 100" or "80%,100%" and returns them sorted
+// without duplicates. An empty string means no alerts.
+func parseAlertThresholds(s string) ([]int, error) {
+	seen := map[int]bool{}
+	var out []int
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		n, err := strconv.Atoi(strings.TrimSuffix(f, "%"))
+		if err != nil || n < 1 || n > maxAlertThreshold {
+			return nil, fmt.Errorf("invalid alert threshold %q", f)
+		}
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func formatAlertThresholds(thresholds []int) string {
+	parts := make([]string, len(thresholds))
+	for i, t := range thresholds {
+		parts[i] = strconv.Itoa(t)
+	}
+	return strings.Join(parts, ",")
+}
+
+// crossedThresholds returns the thresholds spentCents has reached. A category with no limit
+// never alerts.
+func crossedThresholds(thresholds []int, limitCents, spentCents int64) []int {
+	if limitCents <= 0 {
+		return nil
+	}
+	var out []int
+	for _, t := range thresholds {
+		if spentCents*100 >= limitCents*int64(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// checkBudgetAlerts records an alert for each threshold the categories' spending has newly
+// crossed and, if the user opted in, emails them. Failures are only logged: the expense that
+// prompted the check is already saved.
+func (a *App) checkBudgetAlerts(userID int64, categoryIDs ...int64) {
+	var fresh []BudgetAlert
+	for _, id := range categoryIDs {
+		cat, err := getBudgetCategory(a.db, userID, id)
+		if err != nil {
+			log.Printf("Budget alerts: category %d: %v", id, err)
+			continue
+		}
+		thresholds, _ := parseAlertThresholds(cat.AlertThresholds)
+		if len(thresholds) == 0 || cat.LimitCents <= 0 {
+			continue
+		}
+		spent, err := categorySpent(a.db, userID, id)
+		if err != nil {
+			log.Printf("Budget alerts: spending for category %d: %v", id, err)
+			continue
+		}
+		budget, err := getBudget(a.db, userID, cat.BudgetID)
+		if err != nil {
+			log.Printf("Budget alerts: budget %d: %v", cat.BudgetID, err)
+			continue
+		}
+		for _, t := range crossedThresholds(thresholds, cat.LimitCents, spent) {
+			claimed, err := claimBudgetAlert(a.db, userID, id, t, cat.LimitCents, spent)
+			if err != nil {
+				log.Printf("Budget alerts: claiming category %d at %d%%: %v", id, t, err)
+				continue
+			}
+			if claimed {
+				fresh = append(fresh, BudgetAlert{
+					BudgetCategoryID: id,
+					CategoryName:     cat.Name,
+					Year:             budget.Year,
+					Month:            budget.Month,
+					ThresholdPct:     t,
+					LimitCents:       cat.LimitCents,
+					SpentCents:       spent,
+				})
+			}
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	prefs, err := getBudgetPreferences(a.db, userID)
+	if err != nil {
+		log.Printf("Budget alerts: preferences for user %d: %v", userID, err)
+		return
+	}
+	if !prefs.AlertEmail {
+		return
+	}
+	user, err := getUserByID(a.db, userID)
+	if err != nil {
+		log.Printf("Budget alerts: user %d: %v", userID, err)
+		return
+	}
+	subject, body := budgetAlertEmail(fresh, a.baseURL+"/settings")
+	go func() {
+		if err := sendEmail(user.Email, subject, body); err != nil {
+			log.Printf("Budget alerts: emailing user %d: %v", userID, err)
+		}
+	}()
+}
+
+// checkPaymentAlerts checks budget alerts for the debt payoff categories of the month a debt
+// payment was made in, since payments made outside the budget count as spent there.
+func (a *App) checkPaymentAlerts(userID int64, paidOn time.Time) {
+	budget, err := getBudgetByYearMonth(a.db, userID, paidOn.Year(), int(paidOn.Month()))
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("Budget alerts: budget %d-%02d: %v", paidOn.Year(), int(paidOn.Month()), err)
+		return
+	}
+	cats, err := listCategoriesForBudget(a.db, budget.ID, userID)
+	if err != nil {
+		log.Printf("Budget alerts: categories for budget %d: %v", budget.ID, err)
+		return
+	}
+	var categoryIDs []int64
+	for _, c := range cats {
+		if c.IsDebtPayoff {
+			categoryIDs = append(categoryIDs, c.ID)
+		}
+	}
+	a.checkBudgetAlerts(userID, categoryIDs...)
+}
+
+func budgetAlertEmail(alerts []BudgetAlert, settingsURL string) (subject, body string) {
+	var lines strings.Builder
+	for _, al := range alerts {
+		fmt.Fprintf(&lines, "- %s (%s %d): %s spent of %s (%d%% alert)\n",
+			al.CategoryName, time.Month(al.Month), al.Year, money(al.SpentCents), money(al.LimitCents), al.ThresholdPct)
+	}
+	if len(alerts) == 1 {
+		subject = fmt.Sprintf("Budget alert: %s is at %d%% - Debt Manager", alerts[0].CategoryName, alerts[0].ThresholdPct)
+	} else {
+		subject = fmt.Sprintf("Budget alert: %d category thresholds crossed - Debt Manager", len(alerts))
+	}
+	body = fmt.Sprintf(`Hello,
+
+Your spending just crossed these budget alerts:
+
+%s
+Each alert is sent once per month.
+
+To stop these emails, turn off budget alert emails in Settings:
+%s
+
+--
+Debt Manager`, lines.String(), settingsURL)
+	return subject, body
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAlertThresholds(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"80, 100", []int{80, 100}, false},
+		{"100%;80% 80", []int{80, 100}, false},
+		{"1,1000", []int{1, 1000}, false},
+		{"0", nil, true},
+		{"1001", nil, true},
+		{"eighty", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAlertThresholds(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAlertThresholds(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCrossedThresholds(t *testing.T) {
+	thresholds := []int{50, 80, 100, 150}
+	tests := []struct {
+		limit, spent int64
+		want         []int
+	}{
+		{400_00, 199_99, nil},
+		{400_00, 200_00, []int{50}},
+		{400_00, 400_00, []int{50, 80, 100}},
+		{400_00, 700_00, []int{50, 80, 100, 150}},
+		{0, 700_00, nil},
+	}
+	for _, tt := range tests {
+		if got := crossedThresholds(thresholds, tt.limit, tt.spent); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d spent %d: got %v, want %v", tt.limit, tt.spent, got, tt.want)
+		}
+	}
+}
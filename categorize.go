@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// compile prepares a regex rule's pattern once, so Matches does not recompile it for every
+// expense. Other match types need nothing.
+func (cr *CategoryRule) compile() error {
+	if cr.MatchType != "regex" {
+		return nil
+	}
+	re, err := regexp.Compile("(?i)" + cr.Pattern)
+	if err != nil {
+		return err
+	}
+	cr.re = re
+	return nil
+}
+
+// Matches reports whether an expense note and amount satisfy the rule. Text matching is
+// case-insensitive; a regex rule that was not compiled (see compile) never matches.
+func (cr CategoryRule) Matches(note string, amountCents int64) bool {
+	if cr.MinAmountCents.Valid && amountCents < cr.MinAmountCents.Int64 {
+		return false
+	}
+	if cr.MaxAmountCents.Valid && amountCents > cr.MaxAmountCents.Int64 {
+		return false
+	}
+	switch cr.MatchType {
+	case "regex":
+		return cr.re != nil && cr.re.MatchString(note)
+	default:
+		return strings.Contains(strings.ToLower(note), strings.ToLower(cr.Pattern))
+	}
+}
+
+// matchCategoryRule returns the first rule matching the note and amount.
+func matchCategoryRule(rules []CategoryRule, note string, amountCents int64) (CategoryRule, bool) {
+	for _, cr := range rules {
+		if cr.Matches(note, amountCents) {
+			return cr, true
+		}
+	}
+	return CategoryRule{}, false
+}
+
+// ruleCoversText reports whether any rule's text condition matches, ignoring amount limits.
+func ruleCoversText(rules []CategoryRule, text string) bool {
+	for _, cr := range rules {
+		cr.MinAmountCents, cr.MaxAmountCents = sql.NullInt64{}, sql.NullInt64{}
+		if cr.Matches(text, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleSuggestion is a "contains" rule learned from past categorizations.
+type RuleSuggestion struct {
+	Pattern      string
+	CategoryName string
+	Count        int // past expenses with this note key in this category
+}
+
+// noteKey reduces a note to its leading words without digits, so "COSTCO #123 03/15" and
+// "Costco #456" both become "costco".
+func noteKey(note string) string {
+	var words []string
+	for _, f := range strings.Fields(strings.ToLower(note)) {
+		w := strings.Trim(f, "#*-.,:;/()")
+		if w == "" || strings.ContainsAny(w, "0123456789") {
+			continue
+		}
+		words = append(words, w)
+		if len(words) == 2 {
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// suggestCategoryRules learns rules from past notes: a note key seen at least minCount times, with at
+// least 80% of those in one category, suggests "contains <key> → category". Keys an existing rule
+// already covers are left out. Suggestions are ordered by how often they occurred.
+func suggestCategoryRules(history []CategorizedNote, rules []CategoryRule) []RuleSuggestion {
+	const minCount = 2
+	byKey := map[string]map[string]int{}
+	for _, h := range history {
+		key := noteKey(h.Note)
+		if len(key) < 3 {
+			continue
+		}
+		if byKey[key] == nil {
+			byKey[key] = map[string]int{}
+		}
+		byKey[key][h.CategoryName]++
+	}
+	var out []RuleSuggestion
+	for key, cats := range byKey {
+		total, best, bestName := 0, 0, ""
+		for name, n := range cats {
+			total += n
+			if n > best || (n == best && name < bestName) {
+				best, bestName = n, name
+			}
+		}
+		if best < minCount || best*5 < total*4 {
+			continue
+		}
+		if ruleCoversText(rules, key) {
+			continue
+		}
+		out = append(out, RuleSuggestion{Pattern: key, CategoryName: bestName, Count: best})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Pattern < out[j].Pattern
+	})
+	return out
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestCategoryRuleMatches(t *testing.T) {
+	cents := func(c int64) sql.NullInt64 { return sql.NullInt64{Int64: c, Valid: true} }
+	rules := []CategoryRule{
+		{MatchType: "contains", Pattern: "Costco", MinAmountCents: cents(100_00), CategoryName: "Household"},
+		{MatchType: "contains", Pattern: "costco", CategoryName: "Groceries"},
+		{MatchType: "regex", Pattern: `^(shell|esso)\b`, CategoryName: "Gas"},
+		{MatchType: "contains", Pattern: "netflix", MaxAmountCents: cents(20_00), CategoryName: "Subscriptions"},
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		note   string
+		amount int64
+		want   string // "" for no match
+	}{
+		{"COSTCO #123", 150_00, "Household"},
+		{"COSTCO #123", 45_00, "Groceries"},
+		{"Shell 0042 Toronto", 60_00, "Gas"},
+		{"Seashell gifts", 60_00, ""},
+		{"Netflix.com", 16_99, "Subscriptions"},
+		{"Netflix.com", 25_00, ""},
+		{"", 10_00, ""},
+	}
+	for _, tt := range tests {
+		cr, ok := matchCategoryRule(rules, tt.note, tt.amount)
+		got := ""
+		if ok {
+			got = cr.CategoryName
+		}
+		if got != tt.want {
+			t.Errorf("%q %d: got %q, want %q", tt.note, tt.amount, got, tt.want)
+		}
+	}
+
+	uncompiled := CategoryRule{MatchType: "regex", Pattern: "shell"}
+	if uncompiled.Matches("shell", 1) {
+		t.Error("a regex rule that was not compiled should not match")
+	}
+	bad := CategoryRule{MatchType: "regex", Pattern: "(shell"}
+	if bad.compile() == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestDefaultSpentOn(t *testing.T) {
+	now := time.Date(2025, 3, 15, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		year, month int
+		want        string
+	}{
+		{2025, 3, "2025-03-15"},
+		{2025, 2, "2025-02-28"},
+		{2024, 2, "2024-02-29"},
+		{2025, 4, "2025-04-01"},
+	}
+	for _, tt := range tests {
+		if got := defaultSpentOn(tt.year, tt.month, now).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%d-%02d: got %s, want %s", tt.year, tt.month, got, tt.want)
+		}
+	}
+}
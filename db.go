@@ -3,8 +3,13 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"html"
+	"log"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -157,6 +162,308 @@ CREATE TABLE IF NOT EXISTS budget_expenses (
 CREATE INDEX IF NOT EXISTS idx_budgets_user ON budgets(user_id);
 CREATE INDEX IF NOT EXISTS idx_budget_categories_budget ON budget_categories(budget_id);
 CREATE INDEX IF NOT EXISTS idx_budget_expenses_category ON budget_expenses(budget_category_id);
+
+-- Credit limit for revolving debts (cards, lines of credit); 0 means no limit recorded.
+ALTER TABLE debts ADD COLUMN IF NOT EXISTS credit_limit_cents BIGINT NOT NULL DEFAULT 0 CHECK (credit_limit_cents >= 0);
+
+-- Recurring payment rules: posted automatically by the scheduler on day_of_month.
+-- amount_type: 'fixed' (amount_cents), 'minimum' (debt's minimum), 'statement' (full current balance).
+-- last_posted_on is the last occurrence handled, so undone payments are not re-posted. Resuming a
+-- paused rule moves it to the day before, so dates missed while paused are not posted.
+CREATE TABLE IF NOT EXISTS recurring_payments (
+  id BIGSERIAL PRIMARY KEY,
+  debt_id BIGINT NOT NULL,
+  amount_type TEXT NOT NULL CHECK (amount_type IN ('fixed', 'minimum', 'statement')),
+  amount_cents BIGINT NOT NULL DEFAULT 0 CHECK (amount_cents >= 0),
+  day_of_month INTEGER NOT NULL CHECK (day_of_month >= 1 AND day_of_month <= 28),
+  start_date DATE NOT NULL,
+  end_date DATE,
+  note TEXT NOT NULL DEFAULT '',
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  last_posted_on DATE,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (debt_id) REFERENCES debts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_payments_debt ON recurring_payments(debt_id);
+
+-- Auto-posted payments stay unconfirmed until the user confirms (or undoes) them.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS auto_posted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS confirmed BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS recurring_payment_id BIGINT REFERENCES recurring_payments(id) ON DELETE SET NULL;
+
+-- Due-date reminder preferences: one row per user (missing row = reminders off).
+CREATE TABLE IF NOT EXISTS reminder_preferences (
+  user_id BIGINT PRIMARY KEY,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  days_before INTEGER NOT NULL DEFAULT 3 CHECK (days_before >= 0 AND days_before <= 14),
+  unsubscribe_token TEXT NOT NULL UNIQUE,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One row per (debt, due date) reminded, so restarts never send the same reminder twice.
+CREATE TABLE IF NOT EXISTS reminders_sent (
+  debt_id BIGINT NOT NULL,
+  due_date DATE NOT NULL,
+  sent_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (debt_id, due_date),
+  FOREIGN KEY (debt_id) REFERENCES debts(id) ON DELETE CASCADE
+);
+
+-- Lender's late fee; when > 0 it is added to the balance automatically for each missed cycle.
+ALTER TABLE debts ADD COLUMN IF NOT EXISTS late_fee_cents BIGINT NOT NULL DEFAULT 0 CHECK (late_fee_cents >= 0);
+
+-- Missed cycles: the minimum was not paid in the cycle ending on due_date.
+CREATE TABLE IF NOT EXISTS missed_payments (
+  id BIGSERIAL PRIMARY KEY,
+  debt_id BIGINT NOT NULL,
+  due_date DATE NOT NULL,
+  min_payment_cents BIGINT NOT NULL,
+  paid_cents BIGINT NOT NULL,
+  late_fee_cents BIGINT NOT NULL DEFAULT 0,
+  detected_at TIMESTAMPTZ NOT NULL,
+  UNIQUE(debt_id, due_date),
+  FOREIGN KEY (debt_id) REFERENCES debts(id) ON DELETE CASCADE
+);
+
+-- Last due date the missed-payment check has covered. Existing debts start from the day this
+-- column is added, so history from before it is never charged late fees.
+ALTER TABLE debts ADD COLUMN IF NOT EXISTS missed_checked_through DATE NOT NULL DEFAULT CURRENT_DATE;
+
+-- Preferred payoff plan settings (used as plan page defaults and for the calendar feed).
+CREATE TABLE IF NOT EXISTS plan_preferences (
+  user_id BIGINT PRIMARY KEY,
+  monthly_budget_cents BIGINT NOT NULL CHECK (monthly_budget_cents >= 0),
+  strategy TEXT NOT NULL,
+  util_target_pct DOUBLE PRECISION NOT NULL DEFAULT 30,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Secret-URL iCalendar feed; deleting or replacing the row revokes the old URL.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+  user_id BIGINT PRIMARY KEY,
+  token TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Statement imports: transactions from an account whose number ends with account_ref go to this debt.
+ALTER TABLE debts ADD COLUMN IF NOT EXISTS account_ref TEXT NOT NULL DEFAULT '';
+
+-- OFX/QFX transaction IDs already imported, so re-importing a statement never duplicates entries.
+CREATE TABLE IF NOT EXISTS imported_transactions (
+  user_id BIGINT NOT NULL,
+  account_id TEXT NOT NULL,
+  fitid TEXT NOT NULL,
+  imported_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, account_id, fitid),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Payee rules: statement transactions whose payee contains pattern go to a debt (as a payment)
+-- or to the budget category with category_name in the transaction's month (as an expense).
+CREATE TABLE IF NOT EXISTS payee_rules (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  pattern TEXT NOT NULL,
+  debt_id BIGINT,
+  category_name TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (debt_id) REFERENCES debts(id) ON DELETE CASCADE
+);
+
+-- Expense categorization rules. Categories are per budget, so rules name the category and
+-- apply to whichever month the expense falls in. Rules are tried in id order; first match wins.
+CREATE TABLE IF NOT EXISTS category_rules (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  match_type TEXT NOT NULL CHECK (match_type IN ('contains', 'regex')),
+  pattern TEXT NOT NULL,
+  min_amount_cents BIGINT,
+  max_amount_cents BIGINT,
+  category_name TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Budget settings. auto_copy_previous: a month's budget is started from the latest earlier
+-- budget when it is created.
+CREATE TABLE IF NOT EXISTS budget_preferences (
+  user_id BIGINT PRIMARY KEY,
+  auto_copy_previous BOOLEAN NOT NULL DEFAULT FALSE,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Named budget templates (e.g. "Tight month"): reusable category lists applied to any budget.
+CREATE TABLE IF NOT EXISTS budget_templates (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS budget_template_categories (
+  id BIGSERIAL PRIMARY KEY,
+  template_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  limit_cents BIGINT NOT NULL DEFAULT 0 CHECK (limit_cents >= 0),
+  is_debt_payoff BOOLEAN NOT NULL DEFAULT FALSE,
+  sort_order INT NOT NULL DEFAULT 0,
+  FOREIGN KEY (template_id) REFERENCES budget_templates(id) ON DELETE CASCADE
+);
+
+-- Envelope categories. rollover: the leftover (carried in + limit - spent) carries into the
+-- same-named category next month. A sinking fund (sinking_target_cents > 0) always rolls over
+-- and saves toward the target by sinking_due_on.
+ALTER TABLE budget_categories ADD COLUMN IF NOT EXISTS rollover BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE budget_categories ADD COLUMN IF NOT EXISTS sinking_target_cents BIGINT NOT NULL DEFAULT 0 CHECK (sinking_target_cents >= 0);
+ALTER TABLE budget_categories ADD COLUMN IF NOT EXISTS sinking_due_on DATE;
+
+-- Template categories keep the envelope settings of the budget they were saved from.
+ALTER TABLE budget_template_categories ADD COLUMN IF NOT EXISTS rollover BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE budget_template_categories ADD COLUMN IF NOT EXISTS sinking_target_cents BIGINT NOT NULL DEFAULT 0 CHECK (sinking_target_cents >= 0);
+ALTER TABLE budget_template_categories ADD COLUMN IF NOT EXISTS sinking_due_on DATE;
+
+-- Income sources: expected pay on a schedule. next_pay_date anchors the schedule; 'irregular'
+-- income is expected only on next_pay_date.
+CREATE TABLE IF NOT EXISTS income_sources (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  amount_cents BIGINT NOT NULL CHECK (amount_cents >= 0),
+  frequency TEXT NOT NULL CHECK (frequency IN ('weekly', 'biweekly', 'semimonthly', 'monthly', 'irregular')),
+  next_pay_date DATE NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Income actually received, optionally against a source.
+CREATE TABLE IF NOT EXISTS income_receipts (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  income_source_id BIGINT REFERENCES income_sources(id) ON DELETE SET NULL,
+  received_on DATE NOT NULL,
+  amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_income_receipts_user_date ON income_receipts(user_id, received_on);
+
+-- Zero-based budgets must assign all income (income - category limits = 0) before closing.
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS zero_based BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+
+-- An expense in a debt-payoff category can be a real debt payment. Deleting the payment deletes the
+-- expense (deletePaymentTx); deleting the whole debt keeps the expenses as budget history.
+ALTER TABLE budget_expenses ADD COLUMN IF NOT EXISTS payment_id BIGINT REFERENCES payments(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budget_expenses_payment ON budget_expenses(payment_id) WHERE payment_id IS NOT NULL;
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'budget_expenses_payment_id_fkey' AND confdeltype = 'c') THEN
+    ALTER TABLE budget_expenses DROP CONSTRAINT budget_expenses_payment_id_fkey;
+    ALTER TABLE budget_expenses ADD CONSTRAINT budget_expenses_payment_id_fkey FOREIGN KEY (payment_id) REFERENCES payments(id) ON DELETE SET NULL;
+  END IF;
+END $$;
+
+-- Over-budget alerts. alert_thresholds is a comma-separated list of percents of limit_cents
+-- (e.g. "80,100"); one budget_alerts row per (category, threshold) keeps each threshold to a
+-- single alert per month, since a category belongs to one month's budget.
+ALTER TABLE budget_categories ADD COLUMN IF NOT EXISTS alert_thresholds TEXT NOT NULL DEFAULT '';
+ALTER TABLE budget_template_categories ADD COLUMN IF NOT EXISTS alert_thresholds TEXT NOT NULL DEFAULT '';
+ALTER TABLE budget_preferences ADD COLUMN IF NOT EXISTS alert_email BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS budget_alerts (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  budget_category_id BIGINT NOT NULL,
+  threshold_pct INT NOT NULL CHECK (threshold_pct > 0),
+  limit_cents BIGINT NOT NULL,
+  spent_cents BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  dismissed_at TIMESTAMPTZ,
+  UNIQUE(budget_category_id, threshold_pct),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (budget_category_id) REFERENCES budget_categories(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_budget_alerts_user ON budget_alerts(user_id, dismissed_at);
+
+-- Split expenses: one receipt divided across categories of a month's budget. Each allocation is
+-- a budget_expenses row with split_id set, so category totals are unchanged; the allocations
+-- sum to amount_cents.
+CREATE TABLE IF NOT EXISTS expense_splits (
+  id BIGSERIAL PRIMARY KEY,
+  budget_id BIGINT NOT NULL,
+  spent_on DATE NOT NULL,
+  amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (budget_id) REFERENCES budgets(id) ON DELETE CASCADE
+);
+
+ALTER TABLE budget_expenses ADD COLUMN IF NOT EXISTS split_id BIGINT REFERENCES expense_splits(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_budget_expenses_split ON budget_expenses(split_id) WHERE split_id IS NOT NULL;
+
+-- Annual plan: a yearly amount for a category, spread evenly over the open months of the year
+-- from from_month on by setting that category's monthly limit_cents. Limits already set in
+-- closed months count towards the amount. Each month's share is stored in annual_plan_shares
+-- when the plan is saved, and months without a budget get it when the budget is created.
+CREATE TABLE IF NOT EXISTS annual_plan_items (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  year INT NOT NULL,
+  category_name TEXT NOT NULL,
+  annual_cents BIGINT NOT NULL CHECK (annual_cents >= 0),
+  from_month INT NOT NULL DEFAULT 1 CHECK (from_month BETWEEN 1 AND 12),
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  UNIQUE(user_id, year, category_name),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS annual_plan_shares (
+  plan_id BIGINT NOT NULL REFERENCES annual_plan_items(id) ON DELETE CASCADE,
+  month INT NOT NULL CHECK (month BETWEEN 1 AND 12),
+  amount_cents BIGINT NOT NULL CHECK (amount_cents >= 0),
+  PRIMARY KEY (plan_id, month)
+);
+
+-- Salary for the net pay estimate. A budget with income_from_salary takes its income from the
+-- after-tax monthly pay on it.
+CREATE TABLE IF NOT EXISTS salary_profiles (
+  user_id BIGINT PRIMARY KEY,
+  gross_cents BIGINT NOT NULL CHECK (gross_cents >= 0),
+  province TEXT NOT NULL,
+  pay_frequency TEXT NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS income_from_salary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One-time lump sums (tax refunds, bonuses) the payoff plan puts toward debt in the month
+-- they're expected.
+CREATE TABLE IF NOT EXISTS plan_windfalls (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  label TEXT NOT NULL,
+  amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+  expected_on DATE NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
 `
 	_, err := db.Exec(schema)
 	return err
@@ -173,8 +480,12 @@ type Debt struct {
 	DueDay          int
 	Notes           string
 	Active          bool
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	// CreditLimitCents is only meaningful for revolving kinds (see isRevolvingKind); 0 = not set.
+	CreditLimitCents int64
+	LateFeeCents     int64  // posted to the balance when a cycle is missed; 0 = none
+	AccountRef       string // account number (or its last digits) as it appears in bank statement files
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 type Payment struct {
@@ -183,6 +494,8 @@ type Payment struct {
 	PaidOn      time.Time
 	AmountCents int64
 	Note        string
+	AutoPosted  bool // posted by a recurring rule
+	Confirmed   bool // false until the user confirms an auto-posted payment
 	CreatedAt   time.Time
 }
 
@@ -193,8 +506,13 @@ type Budget struct {
 	Year        int
 	Month       int
 	IncomeCents int64
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ZeroBased   bool // every dollar of income must be assigned to a category
+	// IncomeFromSalary: income is the after-tax monthly pay on the saved salary and is refreshed
+	// when the salary or the tax tables change.
+	IncomeFromSalary bool
+	ClosedAt         sql.NullTime // set when the month is closed
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // BudgetCategory: spending category with a limit. is_debt_payoff = true means "Extra for debt" (explicit link to payoff plan).
@@ -205,8 +523,13 @@ type BudgetCategory struct {
 	LimitCents   int64
 	IsDebtPayoff bool
 	SortOrder    int
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	Rollover     bool
+	// Sinking fund: target saved by SinkingDueOn; zero target means an ordinary category
+	SinkingTargetCents int64
+	SinkingDueOn       sql.NullTime
+	AlertThresholds    string // comma-separated percents of LimitCents, e.g. "80,100"
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
 }
 
 // BudgetExpense: one manual spending entry for a category.
@@ -216,6 +539,10 @@ type BudgetExpense struct {
 	SpentOn          time.Time
 	AmountCents      int64
 	Note             string
+	PaymentID        sql.NullInt64 // debt payment recorded by this expense (debt-payoff categories)
+	DebtID           int64         // debt of the linked payment, 0 if none
+	SplitID          sql.NullInt64 // split expense this is one allocation of
+	SplitTotalCents  int64         // the whole split's total, 0 if not split
 	CreatedAt        time.Time
 }
 
@@ -225,7 +552,7 @@ func listDebts(db *sql.DB, userID int64) ([]Debt, error) {
 
 func listDebtsFiltered(db *sql.DB, userID int64, searchQuery, kindFilter, statusFilter, sortBy string) ([]Debt, error) {
 	query := `
-SELECT id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, created_at, updated_at
+SELECT id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, credit_limit_cents, late_fee_cents, account_ref, created_at, updated_at
 FROM debts
 WHERE user_id = $1`
 	args := []any{userID}
@@ -287,7 +614,7 @@ WHERE user_id = $1`
 	var out []Debt
 	for rows.Next() {
 		var d Debt
-		if err := rows.Scan(&d.ID, &d.Name, &d.Kind, &d.BalanceCents, &d.APRBps, &d.MinPaymentCents, &d.PaymentCents, &d.DueDay, &d.Notes, &d.Active, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if err := rows.Scan(&d.ID, &d.Name, &d.Kind, &d.BalanceCents, &d.APRBps, &d.MinPaymentCents, &d.PaymentCents, &d.DueDay, &d.Notes, &d.Active, &d.CreditLimitCents, &d.LateFeeCents, &d.AccountRef, &d.CreatedAt, &d.UpdatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, d)
@@ -298,9 +625,9 @@ WHERE user_id = $1`
 func getDebt(db *sql.DB, userID, id int64) (Debt, error) {
 	var d Debt
 	err := db.QueryRow(`
-SELECT id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, created_at, updated_at
+SELECT id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, credit_limit_cents, late_fee_cents, account_ref, created_at, updated_at
 FROM debts WHERE id = $1 AND user_id = $2`, id, userID).
-		Scan(&d.ID, &d.Name, &d.Kind, &d.BalanceCents, &d.APRBps, &d.MinPaymentCents, &d.PaymentCents, &d.DueDay, &d.Notes, &d.Active, &d.CreatedAt, &d.UpdatedAt)
+		Scan(&d.ID, &d.Name, &d.Kind, &d.BalanceCents, &d.APRBps, &d.MinPaymentCents, &d.PaymentCents, &d.DueDay, &d.Notes, &d.Active, &d.CreditLimitCents, &d.LateFeeCents, &d.AccountRef, &d.CreatedAt, &d.UpdatedAt)
 	if err != nil {
 		return Debt{}, err
 	}
@@ -309,7 +636,7 @@ FROM debts WHERE id = $1 AND user_id = $2`, id, userID).
 
 func listPaymentsForDebt(db *sql.DB, userID, debtID int64) ([]Payment, error) {
 	rows, err := db.Query(`
-SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.created_at
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at
 FROM payments p
 JOIN debts d ON p.debt_id = d.id
 WHERE p.debt_id = $1 AND d.user_id = $2
@@ -322,7 +649,7 @@ ORDER BY p.paid_on DESC, p.id DESC`, debtID, userID)
 	var out []Payment
 	for rows.Next() {
 		var p Payment
-		if err := rows.Scan(&p.ID, &p.DebtID, &p.PaidOn, &p.AmountCents, &p.Note, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.DebtID, &p.PaidOn, &p.AmountCents, &p.Note, &p.AutoPosted, &p.Confirmed, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, p)
@@ -337,7 +664,7 @@ type PaymentWithDebt struct {
 
 func listAllPayments(db *sql.DB, userID int64) ([]PaymentWithDebt, error) {
 	rows, err := db.Query(`
-SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.created_at, d.name
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at, d.name
 FROM payments p
 JOIN debts d ON p.debt_id = d.id
 WHERE d.user_id = $1
@@ -350,7 +677,7 @@ ORDER BY p.paid_on DESC, p.id DESC`, userID)
 	var out []PaymentWithDebt
 	for rows.Next() {
 		var pwd PaymentWithDebt
-		if err := rows.Scan(&pwd.ID, &pwd.DebtID, &pwd.PaidOn, &pwd.AmountCents, &pwd.Note, &pwd.CreatedAt, &pwd.DebtName); err != nil {
+		if err := rows.Scan(&pwd.ID, &pwd.DebtID, &pwd.PaidOn, &pwd.AmountCents, &pwd.Note, &pwd.AutoPosted, &pwd.Confirmed, &pwd.CreatedAt, &pwd.DebtName); err != nil {
 			return nil, err
 		}
 		out = append(out, pwd)
@@ -373,10 +700,10 @@ WHERE d.user_id = $1
 func createDebt(db *sql.DB, userID int64, d Debt) (int64, error) {
 	now := time.Now().UTC()
 	err := db.QueryRow(`
-INSERT INTO debts(user_id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, created_at, updated_at)
-VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,TRUE,$10,$10)
+INSERT INTO debts(user_id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, credit_limit_cents, late_fee_cents, account_ref, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,TRUE,$10,$11,$12,$13,$13)
 RETURNING id`,
-		userID, d.Name, d.Kind, d.BalanceCents, d.APRBps, d.MinPaymentCents, d.PaymentCents, d.DueDay, d.Notes, now).
+		userID, d.Name, d.Kind, d.BalanceCents, d.APRBps, d.MinPaymentCents, d.PaymentCents, d.DueDay, d.Notes, d.CreditLimitCents, d.LateFeeCents, d.AccountRef, now).
 		Scan(&d.ID)
 	if err != nil {
 		return 0, err
@@ -400,9 +727,9 @@ func updateDebt(db *sql.DB, userID int64, d Debt) error {
 	now := time.Now().UTC()
 	_, err := db.Exec(`
 UPDATE debts 
-SET name = $1, kind = $2, balance_cents = $3, apr_bps = $4, min_payment_cents = $5, payment_cents = $6, due_day = $7, notes = $8, updated_at = $9
-WHERE id = $10 AND user_id = $11`,
-		d.Name, d.Kind, d.BalanceCents, d.APRBps, d.MinPaymentCents, d.PaymentCents, d.DueDay, d.Notes, now, d.ID, userID)
+SET name = $1, kind = $2, balance_cents = $3, apr_bps = $4, min_payment_cents = $5, payment_cents = $6, due_day = $7, notes = $8, credit_limit_cents = $9, late_fee_cents = $10, account_ref = $11, updated_at = $12
+WHERE id = $13 AND user_id = $14`,
+		d.Name, d.Kind, d.BalanceCents, d.APRBps, d.MinPaymentCents, d.PaymentCents, d.DueDay, d.Notes, d.CreditLimitCents, d.LateFeeCents, d.AccountRef, now, d.ID, userID)
 	return err
 }
 
@@ -418,8 +745,18 @@ func deletePayment(db *sql.DB, userID, paymentID int64) error {
 	}
 	defer tx.Rollback()
 
+	if err := deletePaymentTx(tx, userID, paymentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deletePaymentTx removes a payment and restores the debt balance inside tx. A budget expense
+// linked to the payment is deleted with it.
+func deletePaymentTx(tx *sql.Tx, userID, paymentID int64) error {
 	var debtID, amountCents int64
-	err = tx.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT p.debt_id, p.amount_cents 
 		FROM payments p
 		JOIN debts d ON p.debt_id = d.id
@@ -428,6 +765,9 @@ func deletePayment(db *sql.DB, userID, paymentID int64) error {
 		return err
 	}
 
+	if _, err := tx.Exec(`DELETE FROM budget_expenses WHERE payment_id = $1`, paymentID); err != nil {
+		return err
+	}
 	_, err = tx.Exec(`DELETE FROM payments WHERE id = $1`, paymentID)
 	if err != nil {
 		return err
@@ -439,21 +779,18 @@ func deletePayment(db *sql.DB, userID, paymentID int64) error {
 	}
 	newBal := bal + amountCents
 	now := time.Now().UTC()
-	if _, err := tx.Exec(`UPDATE debts SET balance_cents = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`, newBal, now, debtID, userID); err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	_, err = tx.Exec(`UPDATE debts SET balance_cents = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`, newBal, now, debtID, userID)
+	return err
 }
 
 func getPayment(db *sql.DB, userID, id int64) (Payment, error) {
 	var p Payment
 	err := db.QueryRow(`
-SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.created_at
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at
 FROM payments p
 JOIN debts d ON p.debt_id = d.id
 WHERE p.id = $1 AND d.user_id = $2`, id, userID).
-		Scan(&p.ID, &p.DebtID, &p.PaidOn, &p.AmountCents, &p.Note, &p.CreatedAt)
+		Scan(&p.ID, &p.DebtID, &p.PaidOn, &p.AmountCents, &p.Note, &p.AutoPosted, &p.Confirmed, &p.CreatedAt)
 	if err != nil {
 		return Payment{}, err
 	}
@@ -467,8 +804,23 @@ func updatePayment(db *sql.DB, userID, paymentID int64, paidOn time.Time, amount
 	}
 	defer tx.Rollback()
 
+	if err := updatePaymentTx(tx, userID, paymentID, paidOn, amountCents, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// errOutsideBudgetMonth is returned when a payment linked to a budget expense would move out of
+// that budget's month.
+var errOutsideBudgetMonth = errors.New("payment date is outside the month of its budget expense")
+
+// updatePaymentTx changes a payment and adjusts the debt balance by the difference inside tx.
+// A budget expense linked to the payment follows its date and amount; the date must stay in the
+// expense's budget month.
+func updatePaymentTx(tx *sql.Tx, userID, paymentID int64, paidOn time.Time, amountCents int64, note string) error {
 	var oldAmountCents, debtID int64
-	err = tx.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT p.debt_id, p.amount_cents 
 		FROM payments p
 		JOIN debts d ON p.debt_id = d.id
@@ -476,6 +828,18 @@ func updatePayment(db *sql.DB, userID, paymentID int64, paidOn time.Time, amount
 	if err != nil {
 		return err
 	}
+	var year, month int
+	err = tx.QueryRow(`
+SELECT b.year, b.month FROM budget_expenses e
+JOIN budget_categories c ON e.budget_category_id = c.id
+JOIN budgets b ON c.budget_id = b.id
+WHERE e.payment_id = $1`, paymentID).Scan(&year, &month)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && (paidOn.Year() != year || int(paidOn.Month()) != month) {
+		return errOutsideBudgetMonth
+	}
 
 	_, err = tx.Exec(`UPDATE payments SET paid_on = $1, amount_cents = $2, note = $3 WHERE id = $4`,
 		paidOn, amountCents, note, paymentID)
@@ -496,7 +860,8 @@ func updatePayment(db *sql.DB, userID, paymentID int64, paidOn time.Time, amount
 		return err
 	}
 
-	return tx.Commit()
+	_, err = tx.Exec(`UPDATE budget_expenses SET spent_on = $1, amount_cents = $2 WHERE payment_id = $3`, paidOn, amountCents, paymentID)
+	return err
 }
 
 func addPayment(db *sql.DB, userID, debtID int64, paidOn time.Time, amountCents int64, note string) error {
@@ -506,23 +871,35 @@ func addPayment(db *sql.DB, userID, debtID int64, paidOn time.Time, amountCents
 	}
 	defer tx.Rollback()
 
-	created := time.Now().UTC()
-	_, err = tx.Exec(`
-INSERT INTO payments(debt_id, paid_on, amount_cents, note, created_at)
-VALUES($1,$2,$3,$4,$5)`, debtID, paidOn, amountCents, note, created)
-	if err != nil {
+	if _, err := addPaymentTx(tx, userID, debtID, paidOn, amountCents, note, 0); err != nil {
 		return err
 	}
 
-	var bal int64
+	return tx.Commit()
+}
+
+// addPaymentTx inserts a payment and reduces the debt balance inside tx. recurringID > 0 marks the
+// payment as auto-posted (unconfirmed) by that recurring rule. Returns the new payment ID.
+func addPaymentTx(tx *sql.Tx, userID, debtID int64, paidOn time.Time, amountCents int64, note string, recurringID int64) (int64, error) {
 	var exists int
-	err = tx.QueryRow(`SELECT 1 FROM debts WHERE id = $1 AND user_id = $2`, debtID, userID).Scan(&exists)
+	err := tx.QueryRow(`SELECT 1 FROM debts WHERE id = $1 AND user_id = $2`, debtID, userID).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("debt not found or access denied")
+	}
+
+	created := time.Now().UTC()
+	var paymentID int64
+	err = tx.QueryRow(`
+INSERT INTO payments(debt_id, paid_on, amount_cents, note, auto_posted, confirmed, recurring_payment_id, created_at)
+VALUES($1,$2,$3,$4,$5,$6,NULLIF($7, 0),$8)
+RETURNING id`, debtID, paidOn, amountCents, note, recurringID > 0, recurringID == 0, recurringID, created).Scan(&paymentID)
 	if err != nil {
-		return fmt.Errorf("debt not found or access denied")
+		return 0, err
 	}
 
+	var bal int64
 	if err := tx.QueryRow(`SELECT balance_cents FROM debts WHERE id = $1 AND user_id = $2`, debtID, userID).Scan(&bal); err != nil {
-		return err
+		return 0, err
 	}
 	newBal := bal - amountCents
 	if newBal < 0 {
@@ -530,10 +907,25 @@ VALUES($1,$2,$3,$4,$5)`, debtID, paidOn, amountCents, note, created)
 	}
 	now := time.Now().UTC()
 	if _, err := tx.Exec(`UPDATE debts SET balance_cents = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`, newBal, now, debtID, userID); err != nil {
-		return err
+		return 0, err
 	}
 
-	return tx.Commit()
+	return paymentID, nil
+}
+
+// confirmPayment marks an auto-posted payment as confirmed by the user.
+func confirmPayment(db *sql.DB, userID, paymentID int64) error {
+	res, err := db.Exec(`
+UPDATE payments SET confirmed = TRUE
+WHERE id = $1 AND debt_id IN (SELECT id FROM debts WHERE user_id = $2)`, paymentID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 func createUser(db *sql.DB, email, passwordHash string) (int64, error) {
@@ -609,9 +1001,9 @@ func updateUserPassword(db *sql.DB, userID int64, passwordHash string) error {
 func getBudgetByYearMonth(db *sql.DB, userID int64, year, month int) (Budget, error) {
 	var b Budget
 	err := db.QueryRow(`
-SELECT id, user_id, year, month, income_cents, created_at, updated_at
+SELECT id, user_id, year, month, income_cents, zero_based, income_from_salary, closed_at, created_at, updated_at
 FROM budgets WHERE user_id = $1 AND year = $2 AND month = $3`, userID, year, month).
-		Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.CreatedAt, &b.UpdatedAt)
+		Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.ZeroBased, &b.IncomeFromSalary, &b.ClosedAt, &b.CreatedAt, &b.UpdatedAt)
 	if err != nil {
 		return Budget{}, err
 	}
@@ -620,29 +1012,72 @@ FROM budgets WHERE user_id = $1 AND year = $2 AND month = $3`, userID, year, mon
 
 func getOrCreateBudget(db *sql.DB, userID int64, year, month int, incomeCents int64) (Budget, error) {
 	b, err := getBudgetByYearMonth(db, userID, year, month)
-	if err == nil {
-		return b, nil
+	if err != sql.ErrNoRows {
+		return b, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return Budget{}, err
+	}
+	defer tx.Rollback()
+	id, err := createBudgetTx(tx, userID, year, month, incomeCents)
+	if err != nil {
+		return Budget{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Budget{}, err
 	}
+	return getBudget(db, userID, id)
+}
+
+// createBudgetTx adds the user's budget for year/month. When the user has turned on automatic
+// copying it is started from their latest earlier budget, and annual plans covering the month
+// set their limits, so a month begins the same way whether it is first opened, imported into or
+// quick-added to.
+func createBudgetTx(tx *sql.Tx, userID int64, year, month int, incomeCents int64) (int64, error) {
 	now := time.Now().UTC()
-	err = db.QueryRow(`
+	var id int64
+	err := tx.QueryRow(`
 INSERT INTO budgets(user_id, year, month, income_cents, created_at, updated_at)
 VALUES($1,$2,$3,$4,$5,$5)
-RETURNING id, user_id, year, month, income_cents, created_at, updated_at`,
-		userID, year, month, incomeCents, now).
-		Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.CreatedAt, &b.UpdatedAt)
+RETURNING id`, userID, year, month, incomeCents, now).Scan(&id)
 	if err != nil {
-		return Budget{}, err
+		return 0, err
 	}
-	return b, nil
+	var autoCopy bool
+	err = tx.QueryRow(`SELECT auto_copy_previous FROM budget_preferences WHERE user_id = $1`, userID).Scan(&autoCopy)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if autoCopy {
+		var prevID int64
+		err = tx.QueryRow(`
+SELECT id FROM budgets WHERE user_id = $1 AND (year < $2 OR (year = $2 AND month < $3))
+ORDER BY year DESC, month DESC LIMIT 1`, userID, year, month).Scan(&prevID)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		if err == nil {
+			if _, err := copyBudgetTx(tx, prevID, id, now); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := applyAnnualPlanTx(tx, userID, id, year, month); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
+// listBudgets returns the user's most recent budgets, newest first; all of them when limit <= 0.
 func listBudgets(db *sql.DB, userID int64, limit int) ([]Budget, error) {
-	if limit <= 0 {
-		limit = 24
+	var max sql.NullInt64 // LIMIT NULL is no limit
+	if limit > 0 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
 	}
 	rows, err := db.Query(`
-SELECT id, user_id, year, month, income_cents, created_at, updated_at
-FROM budgets WHERE user_id = $1 ORDER BY year DESC, month DESC LIMIT $2`, userID, limit)
+SELECT id, user_id, year, month, income_cents, zero_based, income_from_salary, closed_at, created_at, updated_at
+FROM budgets WHERE user_id = $1 ORDER BY year DESC, month DESC LIMIT $2`, userID, max)
 	if err != nil {
 		return nil, err
 	}
@@ -650,7 +1085,7 @@ FROM budgets WHERE user_id = $1 ORDER BY year DESC, month DESC LIMIT $2`, userID
 	var out []Budget
 	for rows.Next() {
 		var b Budget
-		if err := rows.Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.ZeroBased, &b.IncomeFromSalary, &b.ClosedAt, &b.CreatedAt, &b.UpdatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, b)
@@ -661,9 +1096,9 @@ FROM budgets WHERE user_id = $1 ORDER BY year DESC, month DESC LIMIT $2`, userID
 func getBudget(db *sql.DB, userID, budgetID int64) (Budget, error) {
 	var b Budget
 	err := db.QueryRow(`
-SELECT id, user_id, year, month, income_cents, created_at, updated_at
+SELECT id, user_id, year, month, income_cents, zero_based, income_from_salary, closed_at, created_at, updated_at
 FROM budgets WHERE id = $1 AND user_id = $2`, budgetID, userID).
-		Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.CreatedAt, &b.UpdatedAt)
+		Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.ZeroBased, &b.IncomeFromSalary, &b.ClosedAt, &b.CreatedAt, &b.UpdatedAt)
 	if err != nil {
 		return Budget{}, err
 	}
@@ -671,18 +1106,24 @@ FROM budgets WHERE id = $1 AND user_id = $2`, budgetID, userID).
 }
 
 func createBudget(db *sql.DB, userID int64, year, month int, incomeCents int64) (int64, error) {
-	now := time.Now().UTC()
-	var id int64
-	err := db.QueryRow(`
-INSERT INTO budgets(user_id, year, month, income_cents, created_at, updated_at)
-VALUES($1,$2,$3,$4,$5,$5)
-RETURNING id`, userID, year, month, incomeCents, now).Scan(&id)
-	return id, err
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	id, err := createBudgetTx(tx, userID, year, month, incomeCents)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
 func updateBudget(db *sql.DB, userID, budgetID int64, incomeCents int64) error {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return err
+	}
 	now := time.Now().UTC()
-	res, err := db.Exec(`UPDATE budgets SET income_cents = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+	res, err := db.Exec(`UPDATE budgets SET income_cents = $1, income_from_salary = FALSE, updated_at = $2 WHERE id = $3 AND user_id = $4`,
 		incomeCents, now, budgetID, userID)
 	if err != nil {
 		return err
@@ -694,62 +1135,169 @@ func updateBudget(db *sql.DB, userID, budgetID int64, incomeCents int64) error {
 	return nil
 }
 
-func listCategoriesForBudget(db *sql.DB, budgetID, userID int64) ([]BudgetCategory, error) {
-	rows, err := db.Query(`
-SELECT c.id, c.budget_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.created_at, c.updated_at
-FROM budget_categories c
-JOIN budgets b ON c.budget_id = b.id
-WHERE c.budget_id = $1 AND b.user_id = $2 ORDER BY c.sort_order ASC, c.id ASC`, budgetID, userID)
+func setBudgetZeroBased(db *sql.DB, userID, budgetID int64, zeroBased bool) error {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return err
+	}
+	res, err := db.Exec(`UPDATE budgets SET zero_based = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+		zeroBased, time.Now().UTC(), budgetID, userID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-	var out []BudgetCategory
-	for rows.Next() {
-		var c BudgetCategory
-		if err := rows.Scan(&c.ID, &c.BudgetID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
-			return nil, err
-		}
-		out = append(out, c)
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
 	}
-	return out, rows.Err()
+	return nil
+}
+
+// errBudgetClosed is returned when changing a budget whose month has been closed.
+var errBudgetClosed = errors.New("budget month is closed")
+
+// checkBudgetOpen verifies the budget belongs to the user and its month is still open.
+func checkBudgetOpen(db *sql.DB, userID, budgetID int64) error {
+	b, err := getBudget(db, userID, budgetID)
+	if err != nil {
+		return err
+	}
+	if b.ClosedAt.Valid {
+		return errBudgetClosed
+	}
+	return nil
+}
+
+// setBudgetClosed closes (closed true) or reopens a month.
+func setBudgetClosed(db *sql.DB, userID, budgetID int64, closed bool) error {
+	now := time.Now().UTC()
+	closedAt := sql.NullTime{Time: now, Valid: closed}
+	res, err := db.Exec(`UPDATE budgets SET closed_at = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+		closedAt, now, budgetID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// assignRemainderToDebt raises the budget's first "Extra for debt" category by amountCents,
+// creating a "Debt payoff" category when there is none. Returns the category name.
+func assignRemainderToDebt(db *sql.DB, userID, budgetID, amountCents int64) (string, error) {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	var id int64
+	var name string
+	err := db.QueryRow(`
+SELECT id, name FROM budget_categories
+WHERE budget_id = $1 AND is_debt_payoff = TRUE
+ORDER BY sort_order ASC, id ASC LIMIT 1`, budgetID).Scan(&id, &name)
+	if err == sql.ErrNoRows {
+		name = "Debt payoff"
+		_, err = db.Exec(`
+INSERT INTO budget_categories(budget_id, name, limit_cents, is_debt_payoff, sort_order, created_at, updated_at)
+VALUES($1,$2,$3,TRUE,(SELECT COALESCE(MAX(sort_order), 0) + 1 FROM budget_categories WHERE budget_id = $1),$4,$4)`,
+			budgetID, name, amountCents, now)
+		return name, err
+	}
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`UPDATE budget_categories SET limit_cents = limit_cents + $1, updated_at = $2 WHERE id = $3`, amountCents, now, id)
+	return name, err
+}
+
+func listCategoriesForBudget(db *sql.DB, budgetID, userID int64) ([]BudgetCategory, error) {
+	rows, err := db.Query(`
+SELECT c.id, c.budget_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.rollover, c.sinking_target_cents, c.sinking_due_on, c.alert_thresholds, c.created_at, c.updated_at
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+WHERE c.budget_id = $1 AND b.user_id = $2 ORDER BY c.sort_order ASC, c.id ASC`, budgetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BudgetCategory
+	for rows.Next() {
+		var c BudgetCategory
+		if err := rows.Scan(&c.ID, &c.BudgetID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.Rollover, &c.SinkingTargetCents, &c.SinkingDueOn, &c.AlertThresholds, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
 }
 
 func getBudgetCategory(db *sql.DB, userID, categoryID int64) (BudgetCategory, error) {
 	var c BudgetCategory
 	err := db.QueryRow(`
-SELECT c.id, c.budget_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.created_at, c.updated_at
+SELECT c.id, c.budget_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.rollover, c.sinking_target_cents, c.sinking_due_on, c.alert_thresholds, c.created_at, c.updated_at
 FROM budget_categories c
 JOIN budgets b ON c.budget_id = b.id
 WHERE c.id = $1 AND b.user_id = $2`, categoryID, userID).
-		Scan(&c.ID, &c.BudgetID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt)
+		Scan(&c.ID, &c.BudgetID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.Rollover, &c.SinkingTargetCents, &c.SinkingDueOn, &c.AlertThresholds, &c.CreatedAt, &c.UpdatedAt)
 	if err != nil {
 		return BudgetCategory{}, err
 	}
 	return c, nil
 }
 
-func createBudgetCategory(db *sql.DB, userID, budgetID int64, name string, limitCents int64, isDebtPayoff bool, sortOrder int) (int64, error) {
-	// Verify budget belongs to user
-	if _, err := getBudget(db, userID, budgetID); err != nil {
+// CategorySettings are the settings saved with a category besides its name and limit.
+type CategorySettings struct {
+	Rollover           bool
+	SinkingTargetCents int64
+	SinkingDueOn       sql.NullTime
+	AlertThresholds    string // over-budget alert percents, "80,100"
+}
+
+// createBudgetCategory adds a category and its settings in one transaction, so a failure leaves
+// no half-configured category behind.
+func createBudgetCategory(db *sql.DB, userID, budgetID int64, name string, limitCents int64, isDebtPayoff bool, sortOrder int, settings CategorySettings) (int64, error) {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
+
 	now := time.Now().UTC()
 	var id int64
-	err := db.QueryRow(`
+	err = tx.QueryRow(`
 INSERT INTO budget_categories(budget_id, name, limit_cents, is_debt_payoff, sort_order, created_at, updated_at)
 VALUES($1,$2,$3,$4,$5,$6,$6)
 RETURNING id`, budgetID, name, limitCents, isDebtPayoff, sortOrder, now).Scan(&id)
-	return id, err
+	if err != nil {
+		return 0, err
+	}
+	if err := setCategorySettingsTx(tx, id, settings); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
-func updateBudgetCategory(db *sql.DB, userID, categoryID int64, name string, limitCents int64, isDebtPayoff bool, sortOrder int) error {
+// updateBudgetCategory saves a category and its settings in one transaction.
+func updateBudgetCategory(db *sql.DB, userID, categoryID int64, name string, limitCents int64, isDebtPayoff bool, sortOrder int, settings CategorySettings) error {
 	// Verify category belongs to user via budget
-	if _, err := getBudgetCategory(db, userID, categoryID); err != nil {
+	cat, err := getBudgetCategory(db, userID, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, cat.BudgetID); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
 	now := time.Now().UTC()
-	res, err := db.Exec(`
+	res, err := tx.Exec(`
 UPDATE budget_categories SET name = $1, limit_cents = $2, is_debt_payoff = $3, sort_order = $4, updated_at = $5
 WHERE id = $6`, name, limitCents, isDebtPayoff, sortOrder, now, categoryID)
 	if err != nil {
@@ -759,14 +1307,96 @@ WHERE id = $6`, name, limitCents, isDebtPayoff, sortOrder, now, categoryID)
 	if n == 0 {
 		return sql.ErrNoRows
 	}
-	return nil
+	if err := setCategorySettingsTx(tx, categoryID, settings); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setCategorySettingsTx saves a category's rollover, sinking-fund and alert settings inside tx.
+// A sinking fund always rolls over, since it saves across months.
+func setCategorySettingsTx(tx *sql.Tx, categoryID int64, s CategorySettings) error {
+	if s.SinkingTargetCents <= 0 {
+		s.SinkingTargetCents, s.SinkingDueOn = 0, sql.NullTime{}
+	} else {
+		s.Rollover = true
+	}
+	_, err := tx.Exec(`
+UPDATE budget_categories SET rollover = $1, sinking_target_cents = $2, sinking_due_on = $3, alert_thresholds = $4, updated_at = $5
+WHERE id = $6`, s.Rollover, s.SinkingTargetCents, s.SinkingDueOn, s.AlertThresholds, time.Now().UTC(), categoryID)
+	return err
+}
+
+// categorySpentSQL is the spending of category c in budget b, as the budget view counts it: its
+// expenses plus, in the month's first debt payoff category, debt payments made outside the budget.
+const categorySpentSQL = `COALESCE((SELECT SUM(e.amount_cents) FROM budget_expenses e WHERE e.budget_category_id = c.id), 0)
+  + CASE WHEN c.id = (SELECT f.id FROM budget_categories f WHERE f.budget_id = b.id AND f.is_debt_payoff = TRUE ORDER BY f.sort_order ASC, f.id ASC LIMIT 1)
+    THEN COALESCE((
+      SELECT SUM(p.amount_cents) FROM payments p
+      JOIN debts d ON p.debt_id = d.id
+      WHERE d.user_id = b.user_id
+        AND p.paid_on >= make_date(b.year, b.month, 1) AND p.paid_on < make_date(b.year, b.month, 1) + INTERVAL '1 month'
+        AND NOT EXISTS (SELECT 1 FROM budget_expenses e WHERE e.payment_id = p.id)), 0)
+    ELSE 0 END`
+
+// categorySpent returns a category's spending, counting debt payments made outside the budget
+// (see categorySpentSQL).
+func categorySpent(db *sql.DB, userID, categoryID int64) (int64, error) {
+	var spent int64
+	err := db.QueryRow(`
+SELECT `+categorySpentSQL+`
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+WHERE c.id = $1 AND b.user_id = $2`, categoryID, userID).Scan(&spent)
+	return spent, err
+}
+
+// listEnvelopeHistory returns every category in the user's budgets before year/month with its
+// spending (see categorySpentSQL), oldest month first, for carrying rollover balances forward.
+func listEnvelopeHistory(db *sql.DB, userID int64, year, month int) ([]EnvelopeMonth, error) {
+	rows, err := db.Query(`
+SELECT c.name, b.year, b.month, c.limit_cents, c.rollover, `+categorySpentSQL+`
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1 AND b.year * 12 + b.month < $2
+ORDER BY b.year ASC, b.month ASC, c.sort_order ASC, c.id ASC`, userID, year*12+month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EnvelopeMonth
+	for rows.Next() {
+		var m EnvelopeMonth
+		if err := rows.Scan(&m.Name, &m.Year, &m.Month, &m.LimitCents, &m.Rollover, &m.SpentCents); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
 }
 
+// errCategoryHasSplits is returned when deleting a category that holds part of a split expense.
+var errCategoryHasSplits = errors.New("category has split expense allocations")
+
+// deleteBudgetCategory removes a category and its expenses. A category with split expense
+// allocations is refused, since deleting them would change the splits' totals.
 func deleteBudgetCategory(db *sql.DB, userID, categoryID int64) error {
-	if _, err := getBudgetCategory(db, userID, categoryID); err != nil {
+	cat, err := getBudgetCategory(db, userID, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, cat.BudgetID); err != nil {
+		return err
+	}
+	var hasSplits bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM budget_expenses WHERE budget_category_id = $1 AND split_id IS NOT NULL)`,
+		categoryID).Scan(&hasSplits); err != nil {
 		return err
 	}
-	_, err := db.Exec(`DELETE FROM budget_categories WHERE id = $1`, categoryID)
+	if hasSplits {
+		return errCategoryHasSplits
+	}
+	_, err = db.Exec(`DELETE FROM budget_categories WHERE id = $1`, categoryID)
 	return err
 }
 
@@ -784,8 +1414,10 @@ func totalSpentForCategory(db *sql.DB, categoryID int64) (int64, error) {
 
 func listExpensesForCategory(db *sql.DB, userID, categoryID int64) ([]BudgetExpense, error) {
 	rows, err := db.Query(`
-SELECT e.id, e.budget_category_id, e.spent_on, e.amount_cents, e.note, e.created_at
+SELECT e.id, e.budget_category_id, e.spent_on, e.amount_cents, e.note, e.payment_id, COALESCE(p.debt_id, 0), e.split_id, COALESCE(sp.amount_cents, 0), e.created_at
 FROM budget_expenses e
+LEFT JOIN payments p ON e.payment_id = p.id
+LEFT JOIN expense_splits sp ON e.split_id = sp.id
 JOIN budget_categories c ON e.budget_category_id = c.id
 JOIN budgets b ON c.budget_id = b.id
 WHERE e.budget_category_id = $1 AND b.user_id = $2 ORDER BY e.spent_on DESC, e.id DESC`, categoryID, userID)
@@ -796,7 +1428,7 @@ WHERE e.budget_category_id = $1 AND b.user_id = $2 ORDER BY e.spent_on DESC, e.i
 	var out []BudgetExpense
 	for rows.Next() {
 		var e BudgetExpense
-		if err := rows.Scan(&e.ID, &e.BudgetCategoryID, &e.SpentOn, &e.AmountCents, &e.Note, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.BudgetCategoryID, &e.SpentOn, &e.AmountCents, &e.Note, &e.PaymentID, &e.DebtID, &e.SplitID, &e.SplitTotalCents, &e.CreatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, e)
@@ -807,44 +1439,165 @@ WHERE e.budget_category_id = $1 AND b.user_id = $2 ORDER BY e.spent_on DESC, e.i
 func getBudgetExpense(db *sql.DB, userID, expenseID int64) (BudgetExpense, error) {
 	var e BudgetExpense
 	err := db.QueryRow(`
-SELECT e.id, e.budget_category_id, e.spent_on, e.amount_cents, e.note, e.created_at
+SELECT e.id, e.budget_category_id, e.spent_on, e.amount_cents, e.note, e.payment_id, COALESCE(p.debt_id, 0), e.split_id, COALESCE(sp.amount_cents, 0), e.created_at
 FROM budget_expenses e
+LEFT JOIN payments p ON e.payment_id = p.id
+LEFT JOIN expense_splits sp ON e.split_id = sp.id
 JOIN budget_categories c ON e.budget_category_id = c.id
 JOIN budgets b ON c.budget_id = b.id
 WHERE e.id = $1 AND b.user_id = $2`, expenseID, userID).
-		Scan(&e.ID, &e.BudgetCategoryID, &e.SpentOn, &e.AmountCents, &e.Note, &e.CreatedAt)
+		Scan(&e.ID, &e.BudgetCategoryID, &e.SpentOn, &e.AmountCents, &e.Note, &e.PaymentID, &e.DebtID, &e.SplitID, &e.SplitTotalCents, &e.CreatedAt)
 	if err != nil {
 		return BudgetExpense{}, err
 	}
 	return e, nil
 }
 
-func addBudgetExpense(db *sql.DB, userID, categoryID int64, spentOn time.Time, amountCents int64, note string) error {
-	if _, err := getBudgetCategory(db, userID, categoryID); err != nil {
+// addBudgetExpense records an expense. With debtID > 0 (debt-payoff categories only) a matching
+// payment is posted to that debt in the same transaction and linked to the expense.
+func addBudgetExpense(db *sql.DB, userID, categoryID int64, spentOn time.Time, amountCents int64, note string, debtID int64) error {
+	cat, err := getBudgetCategory(db, userID, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, cat.BudgetID); err != nil {
+		return err
+	}
+	if debtID > 0 && !cat.IsDebtPayoff {
+		return fmt.Errorf("only debt payoff categories can record debt payments")
+	}
+	tx, err := db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	var paymentID sql.NullInt64
+	if debtID > 0 {
+		// Payment notes are stored escaped, like payments recorded by hand
+		id, err := addPaymentTx(tx, userID, debtID, spentOn, amountCents, html.EscapeString(note), 0)
+		if err != nil {
+			return err
+		}
+		paymentID = sql.NullInt64{Int64: id, Valid: true}
+	}
 	now := time.Now().UTC()
-	_, err := db.Exec(`
-INSERT INTO budget_expenses(budget_category_id, spent_on, amount_cents, note, created_at)
-VALUES($1,$2,$3,$4,$5)`, categoryID, spentOn, amountCents, note, now)
-	return err
+	if _, err := tx.Exec(`
+INSERT INTO budget_expenses(budget_category_id, spent_on, amount_cents, note, payment_id, created_at)
+VALUES($1,$2,$3,$4,$5,$6)`, categoryID, spentOn, amountCents, note, paymentID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func updateBudgetExpense(db *sql.DB, userID, expenseID int64, spentOn time.Time, amountCents int64, note string) error {
-	if _, err := getBudgetExpense(db, userID, expenseID); err != nil {
+// updateBudgetExpense changes an expense and keeps its debt payment in step: the payment is
+// updated, moved to another debt, created or removed to match debtID (0 = no payment).
+func updateBudgetExpense(db *sql.DB, userID, expenseID int64, spentOn time.Time, amountCents int64, note string, debtID int64) error {
+	exp, err := getBudgetExpense(db, userID, expenseID)
+	if err != nil {
 		return err
 	}
-	_, err := db.Exec(`UPDATE budget_expenses SET spent_on = $1, amount_cents = $2, note = $3 WHERE id = $4`,
-		spentOn, amountCents, note, expenseID)
-	return err
+	cat, err := getBudgetCategory(db, userID, exp.BudgetCategoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, cat.BudgetID); err != nil {
+		return err
+	}
+	if debtID > 0 && !cat.IsDebtPayoff {
+		return fmt.Errorf("only debt payoff categories can record debt payments")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	paymentID := exp.PaymentID
+	switch {
+	case exp.PaymentID.Valid && exp.DebtID == debtID:
+		if err := updatePaymentTx(tx, userID, exp.PaymentID.Int64, spentOn, amountCents, html.EscapeString(note)); err != nil {
+			return err
+		}
+	case exp.PaymentID.Valid:
+		// Clear the link first so deleting the payment doesn't delete this expense
+		if _, err := tx.Exec(`UPDATE budget_expenses SET payment_id = NULL WHERE id = $1`, expenseID); err != nil {
+			return err
+		}
+		if err := deletePaymentTx(tx, userID, exp.PaymentID.Int64); err != nil {
+			return err
+		}
+		paymentID = sql.NullInt64{}
+	}
+	if debtID > 0 && !paymentID.Valid {
+		id, err := addPaymentTx(tx, userID, debtID, spentOn, amountCents, html.EscapeString(note), 0)
+		if err != nil {
+			return err
+		}
+		paymentID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	if _, err := tx.Exec(`UPDATE budget_expenses SET spent_on = $1, amount_cents = $2, note = $3, payment_id = $4 WHERE id = $5`,
+		spentOn, amountCents, note, paymentID, expenseID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
+// deleteBudgetExpense removes an expense; a linked debt payment is deleted with it and the debt
+// balance restored.
 func deleteBudgetExpense(db *sql.DB, userID, expenseID int64) error {
-	if _, err := getBudgetExpense(db, userID, expenseID); err != nil {
+	exp, err := getBudgetExpense(db, userID, expenseID)
+	if err != nil {
 		return err
 	}
-	_, err := db.Exec(`DELETE FROM budget_expenses WHERE id = $1`, expenseID)
-	return err
+	cat, err := getBudgetCategory(db, userID, exp.BudgetCategoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, cat.BudgetID); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if exp.PaymentID.Valid {
+		if err := deletePaymentTx(tx, userID, exp.PaymentID.Int64); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM budget_expenses WHERE id = $1`, expenseID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listUnlinkedDebtPayments returns the user's debt payments in [from, to) that no budget expense
+// records, so they can be shown as spent in the debt payoff category.
+func listUnlinkedDebtPayments(db *sql.DB, userID int64, from, to time.Time) ([]PaymentWithDebt, error) {
+	rows, err := db.Query(`
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at, d.name
+FROM payments p
+JOIN debts d ON p.debt_id = d.id
+WHERE d.user_id = $1 AND p.paid_on >= $2 AND p.paid_on < $3
+  AND NOT EXISTS (SELECT 1 FROM budget_expenses e WHERE e.payment_id = p.id)
+ORDER BY p.paid_on DESC, p.id DESC`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PaymentWithDebt
+	for rows.Next() {
+		var pwd PaymentWithDebt
+		if err := rows.Scan(&pwd.ID, &pwd.DebtID, &pwd.PaidOn, &pwd.AmountCents, &pwd.Note, &pwd.AutoPosted, &pwd.Confirmed, &pwd.CreatedAt, &pwd.DebtName); err != nil {
+			return nil, err
+		}
+		out = append(out, pwd)
+	}
+	return out, rows.Err()
 }
 
 // SumOfMinPaymentsForUser returns the total minimum payment per month for active debts (for plan/budget link).
@@ -860,3 +1613,2182 @@ SELECT COALESCE(SUM(min_payment_cents), 0) FROM debts WHERE user_id = $1 AND act
 	}
 	return 0, nil
 }
+
+// --- Recurring payments ---
+
+// RecurringPayment is a rule that posts a payment to a debt on DayOfMonth each month.
+type RecurringPayment struct {
+	ID           int64
+	DebtID       int64
+	AmountType   string // "fixed", "minimum", or "statement"
+	AmountCents  int64  // only used for "fixed"
+	DayOfMonth   int
+	StartDate    time.Time
+	EndDate      sql.NullTime
+	Note         string
+	Active       bool
+	LastPostedOn sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+const recurringPaymentColumns = `r.id, r.debt_id, r.amount_type, r.amount_cents, r.day_of_month, r.start_date, r.end_date, r.note, r.active, r.last_posted_on, r.created_at, r.updated_at`
+
+func scanRecurringPayment(sc interface{ Scan(...any) error }) (RecurringPayment, error) {
+	var rp RecurringPayment
+	err := sc.Scan(&rp.ID, &rp.DebtID, &rp.AmountType, &rp.AmountCents, &rp.DayOfMonth, &rp.StartDate, &rp.EndDate, &rp.Note, &rp.Active, &rp.LastPostedOn, &rp.CreatedAt, &rp.UpdatedAt)
+	return rp, err
+}
+
+func listRecurringPaymentsForDebt(db *sql.DB, userID, debtID int64) ([]RecurringPayment, error) {
+	rows, err := db.Query(`
+SELECT `+recurringPaymentColumns+`
+FROM recurring_payments r
+JOIN debts d ON r.debt_id = d.id
+WHERE r.debt_id = $1 AND d.user_id = $2
+ORDER BY r.day_of_month ASC, r.id ASC`, debtID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RecurringPayment
+	for rows.Next() {
+		rp, err := scanRecurringPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rp)
+	}
+	return out, rows.Err()
+}
+
+func getRecurringPayment(db *sql.DB, userID, id int64) (RecurringPayment, error) {
+	row := db.QueryRow(`
+SELECT `+recurringPaymentColumns+`
+FROM recurring_payments r
+JOIN debts d ON r.debt_id = d.id
+WHERE r.id = $1 AND d.user_id = $2`, id, userID)
+	return scanRecurringPayment(row)
+}
+
+func createRecurringPayment(db *sql.DB, userID int64, rp RecurringPayment) (int64, error) {
+	if _, err := getDebt(db, userID, rp.DebtID); err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO recurring_payments(debt_id, amount_type, amount_cents, day_of_month, start_date, end_date, note, active, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,$6,$7,TRUE,$8,$8)
+RETURNING id`, rp.DebtID, rp.AmountType, rp.AmountCents, rp.DayOfMonth, rp.StartDate, rp.EndDate, rp.Note, now).Scan(&id)
+	return id, err
+}
+
+// setRecurringPaymentActive pauses or resumes a rule. Resuming a paused rule marks every date
+// up to yesterday as handled, so the scheduler does not post the months it was paused.
+func setRecurringPaymentActive(db *sql.DB, userID, id int64, active bool) error {
+	rp, err := getRecurringPayment(db, userID, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	lastPosted := rp.LastPostedOn
+	if active && !rp.Active {
+		yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+		if !lastPosted.Valid || lastPosted.Time.Before(yesterday) {
+			lastPosted = sql.NullTime{Time: yesterday, Valid: true}
+		}
+	}
+	_, err = db.Exec(`UPDATE recurring_payments SET active = $1, last_posted_on = $2, updated_at = $3 WHERE id = $4`,
+		active, lastPosted, now, id)
+	return err
+}
+
+func deleteRecurringPayment(db *sql.DB, userID, id int64) error {
+	if _, err := getRecurringPayment(db, userID, id); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM recurring_payments WHERE id = $1`, id)
+	return err
+}
+
+// RecurringPaymentDue is an active rule together with its owning user (for the scheduler).
+type RecurringPaymentDue struct {
+	RecurringPayment
+	UserID int64
+}
+
+// listActiveRecurringPayments returns active rules on active debts for all users.
+func listActiveRecurringPayments(db *sql.DB) ([]RecurringPaymentDue, error) {
+	rows, err := db.Query(`
+SELECT ` + recurringPaymentColumns + `, d.user_id
+FROM recurring_payments r
+JOIN debts d ON r.debt_id = d.id
+WHERE r.active = TRUE AND d.active = TRUE
+ORDER BY r.id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RecurringPaymentDue
+	for rows.Next() {
+		var rd RecurringPaymentDue
+		rp := &rd.RecurringPayment
+		if err := rows.Scan(&rp.ID, &rp.DebtID, &rp.AmountType, &rp.AmountCents, &rp.DayOfMonth, &rp.StartDate, &rp.EndDate, &rp.Note, &rp.Active, &rp.LastPostedOn, &rp.CreatedAt, &rp.UpdatedAt, &rd.UserID); err != nil {
+			return nil, err
+		}
+		out = append(out, rd)
+	}
+	return out, rows.Err()
+}
+
+// postRecurringOccurrence posts one occurrence of a rule in a single transaction: it locks the rule,
+// skips if the occurrence was already handled, computes the amount, adds the payment the same way
+// addPayment does, and records last_posted_on. Returns the payment ID (0 if nothing was posted).
+func postRecurringOccurrence(db *sql.DB, userID, ruleID int64, on time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var amountType, note string
+	var amountCents, debtID int64
+	var active bool
+	var lastPosted sql.NullTime
+	err = tx.QueryRow(`
+SELECT debt_id, amount_type, amount_cents, note, active, last_posted_on
+FROM recurring_payments WHERE id = $1 FOR UPDATE`, ruleID).Scan(&debtID, &amountType, &amountCents, &note, &active, &lastPosted)
+	if err != nil {
+		return 0, err
+	}
+	// The rule may have been paused, or this date posted, since the scheduler listed it.
+	if !active || (lastPosted.Valid && !on.After(lastPosted.Time)) {
+		return 0, nil
+	}
+
+	var balance, minPayment int64
+	if err := tx.QueryRow(`SELECT balance_cents, min_payment_cents FROM debts WHERE id = $1 AND user_id = $2`, debtID, userID).Scan(&balance, &minPayment); err != nil {
+		return 0, err
+	}
+	switch amountType {
+	case "minimum":
+		amountCents = minPayment
+	case "statement":
+		amountCents = balance
+	}
+	if amountCents > balance {
+		amountCents = balance
+	}
+
+	var paymentID int64
+	if amountCents > 0 {
+		if note == "" {
+			note = "Automatic payment"
+		}
+		paymentID, err = addPaymentTx(tx, userID, debtID, on, amountCents, note, ruleID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`UPDATE recurring_payments SET last_posted_on = $1, updated_at = $2 WHERE id = $3`, on, now, ruleID); err != nil {
+		return 0, err
+	}
+	return paymentID, tx.Commit()
+}
+
+// --- Reminders ---
+
+// ReminderPreferences: per-user due-date reminder settings.
+type ReminderPreferences struct {
+	UserID           int64
+	Enabled          bool
+	DaysBefore       int
+	UnsubscribeToken string
+	UpdatedAt        time.Time
+}
+
+// getReminderPreferences returns the user's preferences, creating a default (disabled) row if needed.
+func getReminderPreferences(db *sql.DB, userID int64) (ReminderPreferences, error) {
+	now := time.Now().UTC()
+	if _, err := db.Exec(`
+INSERT INTO reminder_preferences(user_id, enabled, days_before, unsubscribe_token, updated_at)
+VALUES($1, FALSE, 3, $2, $3)
+ON CONFLICT (user_id) DO NOTHING`, userID, generateResetToken(), now); err != nil {
+		return ReminderPreferences{}, err
+	}
+	var p ReminderPreferences
+	err := db.QueryRow(`
+SELECT user_id, enabled, days_before, unsubscribe_token, updated_at
+FROM reminder_preferences WHERE user_id = $1`, userID).
+		Scan(&p.UserID, &p.Enabled, &p.DaysBefore, &p.UnsubscribeToken, &p.UpdatedAt)
+	return p, err
+}
+
+func updateReminderPreferences(db *sql.DB, userID int64, enabled bool, daysBefore int) error {
+	if _, err := getReminderPreferences(db, userID); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err := db.Exec(`UPDATE reminder_preferences SET enabled = $1, days_before = $2, updated_at = $3 WHERE user_id = $4`,
+		enabled, daysBefore, now, userID)
+	return err
+}
+
+// reminderTokenExists reports whether token is a user's unsubscribe token.
+func reminderTokenExists(db *sql.DB, token string) bool {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM reminder_preferences WHERE unsubscribe_token = $1)`, token).Scan(&exists)
+	return err == nil && exists
+}
+
+// unsubscribeReminders disables reminders for the owner of token.
+func unsubscribeReminders(db *sql.DB, token string) error {
+	now := time.Now().UTC()
+	res, err := db.Exec(`UPDATE reminder_preferences SET enabled = FALSE, updated_at = $1 WHERE unsubscribe_token = $2`, now, token)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReminderRecipient is a user with reminders enabled.
+type ReminderRecipient struct {
+	UserID           int64
+	Email            string
+	DaysBefore       int
+	UnsubscribeToken string
+}
+
+func listReminderRecipients(db *sql.DB) ([]ReminderRecipient, error) {
+	rows, err := db.Query(`
+SELECT u.id, u.email, p.days_before, p.unsubscribe_token
+FROM reminder_preferences p
+JOIN users u ON p.user_id = u.id
+WHERE p.enabled = TRUE
+ORDER BY u.id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ReminderRecipient
+	for rows.Next() {
+		var rr ReminderRecipient
+		if err := rows.Scan(&rr.UserID, &rr.Email, &rr.DaysBefore, &rr.UnsubscribeToken); err != nil {
+			return nil, err
+		}
+		out = append(out, rr)
+	}
+	return out, rows.Err()
+}
+
+// claimReminder records that a reminder for (debt, due date) is being sent. It returns false if
+// one was already recorded, which is what keeps reminders from repeating across restarts.
+func claimReminder(db *sql.DB, debtID int64, dueDate time.Time) (bool, error) {
+	res, err := db.Exec(`
+INSERT INTO reminders_sent(debt_id, due_date, sent_at) VALUES($1,$2,$3)
+ON CONFLICT (debt_id, due_date) DO NOTHING`, debtID, dueDate, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// releaseReminder removes a claim (used when sending the email failed so it is retried).
+func releaseReminder(db *sql.DB, debtID int64, dueDate time.Time) error {
+	_, err := db.Exec(`DELETE FROM reminders_sent WHERE debt_id = $1 AND due_date = $2`, debtID, dueDate)
+	return err
+}
+
+// --- Missed payments ---
+
+// MissedPayment: a cycle (ending on DueDate) in which less than the minimum was paid.
+type MissedPayment struct {
+	ID              int64
+	DebtID          int64
+	DueDate         time.Time
+	MinPaymentCents int64
+	PaidCents       int64
+	LateFeeCents    int64
+	DetectedAt      time.Time
+}
+
+// listPaymentsSince returns the user's payments on or after since (all debts), oldest first.
+func listPaymentsSince(db *sql.DB, userID int64, since time.Time) ([]Payment, error) {
+	rows, err := db.Query(`
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at
+FROM payments p
+JOIN debts d ON p.debt_id = d.id
+WHERE d.user_id = $1 AND p.paid_on >= $2
+ORDER BY p.paid_on ASC, p.id ASC`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Payment
+	for rows.Next() {
+		var p Payment
+		if err := rows.Scan(&p.ID, &p.DebtID, &p.PaidOn, &p.AmountCents, &p.Note, &p.AutoPosted, &p.Confirmed, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// DebtWithOwner is a debt and its user ID (for background jobs that span all users).
+type DebtWithOwner struct {
+	Debt
+	UserID               int64
+	MissedCheckedThrough time.Time // cycles due on or before this date have been checked
+}
+
+func listActiveDebtsAllUsers(db *sql.DB) ([]DebtWithOwner, error) {
+	rows, err := db.Query(`
+SELECT id, name, kind, balance_cents, apr_bps, min_payment_cents, payment_cents, due_day, notes, active, credit_limit_cents, late_fee_cents, account_ref, created_at, updated_at, user_id, missed_checked_through
+FROM debts
+WHERE active = TRUE AND balance_cents > 0
+ORDER BY user_id ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DebtWithOwner
+	for rows.Next() {
+		var d DebtWithOwner
+		if err := rows.Scan(&d.ID, &d.Name, &d.Kind, &d.BalanceCents, &d.APRBps, &d.MinPaymentCents, &d.PaymentCents, &d.DueDay, &d.Notes, &d.Active, &d.CreditLimitCents, &d.LateFeeCents, &d.AccountRef, &d.CreatedAt, &d.UpdatedAt, &d.UserID, &d.MissedCheckedThrough); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// setMissedCheckedThrough records that a debt's cycles due on or before through have been checked.
+func setMissedCheckedThrough(db *sql.DB, debtID int64, through time.Time) error {
+	_, err := db.Exec(`UPDATE debts SET missed_checked_through = $1 WHERE id = $2 AND missed_checked_through < $1`, through, debtID)
+	return err
+}
+
+// skipIdleMissedChecks moves the missed-payment check past today for debts that are inactive or
+// paid off, so cycles from while they had nothing owing are not checked when they come back.
+func skipIdleMissedChecks(db *sql.DB, today time.Time) error {
+	_, err := db.Exec(`UPDATE debts SET missed_checked_through = $1 WHERE (active = FALSE OR balance_cents <= 0) AND missed_checked_through < $1`, today)
+	return err
+}
+
+func listMissedPaymentsForDebt(db *sql.DB, userID, debtID int64) ([]MissedPayment, error) {
+	rows, err := db.Query(`
+SELECT m.id, m.debt_id, m.due_date, m.min_payment_cents, m.paid_cents, m.late_fee_cents, m.detected_at
+FROM missed_payments m
+JOIN debts d ON m.debt_id = d.id
+WHERE m.debt_id = $1 AND d.user_id = $2
+ORDER BY m.due_date DESC`, debtID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []MissedPayment
+	for rows.Next() {
+		var m MissedPayment
+		if err := rows.Scan(&m.ID, &m.DebtID, &m.DueDate, &m.MinPaymentCents, &m.PaidCents, &m.LateFeeCents, &m.DetectedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// recordMissedPayment stores a missed cycle once and, if the debt has a late fee, adds it to the
+// balance in the same transaction. Returns false if the cycle was already recorded.
+func recordMissedPayment(db *sql.DB, userID int64, m MissedPayment) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.Exec(`
+INSERT INTO missed_payments(debt_id, due_date, min_payment_cents, paid_cents, late_fee_cents, detected_at)
+SELECT id, $2, $3, $4, $5, $6 FROM debts WHERE id = $1 AND user_id = $7
+ON CONFLICT (debt_id, due_date) DO NOTHING`, m.DebtID, m.DueDate, m.MinPaymentCents, m.PaidCents, m.LateFeeCents, now, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if m.LateFeeCents > 0 {
+		if _, err := tx.Exec(`UPDATE debts SET balance_cents = balance_cents + $1, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+			m.LateFeeCents, now, m.DebtID, userID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// --- Plan preferences ---
+
+// PlanPreferences: the user's saved payoff plan inputs.
+type PlanPreferences struct {
+	UserID             int64
+	MonthlyBudgetCents int64
+	Strategy           Strategy
+	UtilTargetPct      float64
+	UpdatedAt          time.Time
+}
+
+func getPlanPreferences(db *sql.DB, userID int64) (PlanPreferences, error) {
+	var p PlanPreferences
+	err := db.QueryRow(`
+SELECT user_id, monthly_budget_cents, strategy, util_target_pct, updated_at
+FROM plan_preferences WHERE user_id = $1`, userID).
+		Scan(&p.UserID, &p.MonthlyBudgetCents, &p.Strategy, &p.UtilTargetPct, &p.UpdatedAt)
+	if err != nil {
+		return PlanPreferences{}, err
+	}
+	return p, nil
+}
+
+func savePlanPreferences(db *sql.DB, userID int64, p PlanPreferences) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+INSERT INTO plan_preferences(user_id, monthly_budget_cents, strategy, util_target_pct, updated_at)
+VALUES($1,$2,$3,$4,$5)
+ON CONFLICT (user_id) DO UPDATE SET monthly_budget_cents = $2, strategy = $3, util_target_pct = $4, updated_at = $5`,
+		userID, p.MonthlyBudgetCents, string(p.Strategy), p.UtilTargetPct, now)
+	return err
+}
+
+// --- Calendar feed ---
+
+// getCalendarFeedToken returns the user's feed token, or "" if no feed exists.
+func getCalendarFeedToken(db *sql.DB, userID int64) (string, error) {
+	var token string
+	err := db.QueryRow(`SELECT token FROM calendar_feeds WHERE user_id = $1`, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return token, err
+}
+
+// resetCalendarFeedToken creates a new feed token, replacing (and so revoking) any previous one.
+func resetCalendarFeedToken(db *sql.DB, userID int64) (string, error) {
+	token := generateResetToken()
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+INSERT INTO calendar_feeds(user_id, token, created_at) VALUES($1,$2,$3)
+ON CONFLICT (user_id) DO UPDATE SET token = $2, created_at = $3`, userID, token, now)
+	return token, err
+}
+
+func deleteCalendarFeed(db *sql.DB, userID int64) error {
+	_, err := db.Exec(`DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	return err
+}
+
+func getUserIDByCalendarToken(db *sql.DB, token string) (int64, error) {
+	var userID int64
+	err := db.QueryRow(`SELECT user_id FROM calendar_feeds WHERE token = $1`, token).Scan(&userID)
+	return userID, err
+}
+
+// --- Export queries ---
+
+// listPaymentsInRange is listAllPayments limited to from <= paid_on <= to, oldest first.
+// A zero from or to leaves that end open.
+func listPaymentsInRange(db *sql.DB, userID int64, from, to time.Time) ([]PaymentWithDebt, error) {
+	rows, err := db.Query(`
+SELECT p.id, p.debt_id, p.paid_on, p.amount_cents, p.note, p.auto_posted, p.confirmed, p.created_at, d.name
+FROM payments p
+JOIN debts d ON p.debt_id = d.id
+WHERE d.user_id = $1
+  AND ($2::date IS NULL OR p.paid_on >= $2)
+  AND ($3::date IS NULL OR p.paid_on <= $3)
+ORDER BY p.paid_on ASC, p.id ASC`, userID, nullDate(from), nullDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PaymentWithDebt
+	for rows.Next() {
+		var pwd PaymentWithDebt
+		if err := rows.Scan(&pwd.ID, &pwd.DebtID, &pwd.PaidOn, &pwd.AmountCents, &pwd.Note, &pwd.AutoPosted, &pwd.Confirmed, &pwd.CreatedAt, &pwd.DebtName); err != nil {
+			return nil, err
+		}
+		out = append(out, pwd)
+	}
+	return out, rows.Err()
+}
+
+// ExpenseWithCategory is a budget expense with its category and budget month.
+type ExpenseWithCategory struct {
+	BudgetExpense
+	CategoryName string
+	Year         int
+	Month        int
+}
+
+// listExpensesInRange returns every budget expense with from <= spent_on <= to, oldest first.
+// A zero from or to leaves that end open.
+func listExpensesInRange(db *sql.DB, userID int64, from, to time.Time) ([]ExpenseWithCategory, error) {
+	rows, err := db.Query(`
+SELECT e.id, e.budget_category_id, e.spent_on, e.amount_cents, e.note, e.split_id, e.created_at, c.name, b.year, b.month
+FROM budget_expenses e
+JOIN budget_categories c ON e.budget_category_id = c.id
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1
+  AND ($2::date IS NULL OR e.spent_on >= $2)
+  AND ($3::date IS NULL OR e.spent_on <= $3)
+ORDER BY e.spent_on ASC, e.id ASC`, userID, nullDate(from), nullDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ExpenseWithCategory
+	for rows.Next() {
+		var e ExpenseWithCategory
+		if err := rows.Scan(&e.ID, &e.BudgetCategoryID, &e.SpentOn, &e.AmountCents, &e.Note, &e.SplitID, &e.CreatedAt, &e.CategoryName, &e.Year, &e.Month); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// CategorySpend is one budget category for a month with its total spent.
+type CategorySpend struct {
+	BudgetCategory
+	Year        int
+	Month       int
+	IncomeCents int64
+	SpentCents  int64
+}
+
+// listCategorySpendInRange returns categories of every budget whose month overlaps
+// [from, to], with spent totals. A zero from or to leaves that end open.
+func listCategorySpendInRange(db *sql.DB, userID int64, from, to time.Time) ([]CategorySpend, error) {
+	rows, err := db.Query(`
+SELECT c.id, c.budget_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.created_at, c.updated_at,
+       b.year, b.month, b.income_cents, COALESCE(SUM(e.amount_cents), 0)
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+LEFT JOIN budget_expenses e ON e.budget_category_id = c.id
+WHERE b.user_id = $1
+  AND ($2::date IS NULL OR make_date(b.year, b.month, 1) + interval '1 month' > $2)
+  AND ($3::date IS NULL OR make_date(b.year, b.month, 1) <= $3)
+GROUP BY c.id, b.id
+ORDER BY b.year ASC, b.month ASC, c.sort_order ASC, c.id ASC`, userID, nullDate(from), nullDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CategorySpend
+	for rows.Next() {
+		var c CategorySpend
+		if err := rows.Scan(&c.ID, &c.BudgetID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt,
+			&c.Year, &c.Month, &c.IncomeCents, &c.SpentCents); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// nullDate maps a zero time to SQL NULL for optional date filters.
+func nullDate(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// importPayments records a batch of payments for one debt in a single transaction, lowering the
+// balance for each exactly as addPayment does. Either every payment is recorded or none is.
+func importPayments(db *sql.DB, userID, debtID int64, payments []Payment) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range payments {
+		if _, err := addPaymentTx(tx, userID, debtID, p.PaidOn, p.AmountCents, p.Note, 0); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// --- Statement import (OFX/QFX) ---
+
+// PayeeRule sends statement transactions whose payee contains Pattern (case-insensitive) to a
+// debt as a payment or, when DebtID is not set, to the CategoryName budget category as an expense.
+type PayeeRule struct {
+	ID           int64
+	UserID       int64
+	Pattern      string
+	DebtID       sql.NullInt64
+	CategoryName string
+	CreatedAt    time.Time
+}
+
+func listPayeeRules(db *sql.DB, userID int64) ([]PayeeRule, error) {
+	rows, err := db.Query(`
+SELECT id, user_id, pattern, debt_id, category_name, created_at
+FROM payee_rules WHERE user_id = $1 ORDER BY LENGTH(pattern) DESC, id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PayeeRule
+	for rows.Next() {
+		var pr PayeeRule
+		if err := rows.Scan(&pr.ID, &pr.UserID, &pr.Pattern, &pr.DebtID, &pr.CategoryName, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, pr)
+	}
+	return out, rows.Err()
+}
+
+// savePayeeRule creates a rule, replacing any existing rule with the same pattern.
+func savePayeeRule(db *sql.DB, userID int64, pattern string, debtID int64, categoryName string) error {
+	if debtID > 0 {
+		if _, err := getDebt(db, userID, debtID); err != nil {
+			return err
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM payee_rules WHERE user_id = $1 AND LOWER(pattern) = LOWER($2)`, userID, pattern); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+INSERT INTO payee_rules(user_id, pattern, debt_id, category_name, created_at)
+VALUES($1,$2,NULLIF($3, 0),$4,$5)`, userID, pattern, debtID, categoryName, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deletePayeeRule(db *sql.DB, userID, id int64) error {
+	_, err := db.Exec(`DELETE FROM payee_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// listImportedFITIDs returns the transaction IDs already imported from an account.
+func listImportedFITIDs(db *sql.DB, userID int64, accountID string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT fitid FROM imported_transactions WHERE user_id = $1 AND account_id = $2`, userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]bool{}
+	for rows.Next() {
+		var fitid string
+		if err := rows.Scan(&fitid); err != nil {
+			return nil, err
+		}
+		out[fitid] = true
+	}
+	return out, rows.Err()
+}
+
+// listBudgetCategoryNames returns the distinct category names used across all of a user's budgets.
+func listBudgetCategoryNames(db *sql.DB, userID int64) ([]string, error) {
+	rows, err := db.Query(`
+SELECT DISTINCT c.name
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1 ORDER BY c.name ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+// categoryForMonthTx returns the ID of the named category in the user's budget for year/month,
+// creating the budget and/or category (with no limit) when missing. A closed month returns
+// errBudgetClosed.
+func categoryForMonthTx(tx *sql.Tx, userID int64, year, month int, name string) (int64, error) {
+	var budgetID int64
+	var closed bool
+	err := tx.QueryRow(`SELECT id, closed_at IS NOT NULL FROM budgets WHERE user_id = $1 AND year = $2 AND month = $3`, userID, year, month).
+		Scan(&budgetID, &closed)
+	if err == sql.ErrNoRows {
+		budgetID, err = createBudgetTx(tx, userID, year, month, 0)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if closed {
+		return 0, errBudgetClosed
+	}
+	return categoryInBudgetTx(tx, budgetID, name)
+}
+
+// categoryInBudgetTx returns the ID of the budget's category matching name by envelopeKey,
+// adding it (with no limit) at the end when missing.
+func categoryInBudgetTx(tx *sql.Tx, budgetID int64, name string) (int64, error) {
+	var categoryID int64
+	err := tx.QueryRow(`SELECT id FROM budget_categories WHERE budget_id = $1 AND LOWER(TRIM(name)) = $2 ORDER BY sort_order ASC, id ASC LIMIT 1`,
+		budgetID, envelopeKey(name)).Scan(&categoryID)
+	if err == sql.ErrNoRows {
+		now := time.Now().UTC()
+		err = tx.QueryRow(`
+INSERT INTO budget_categories(budget_id, name, limit_cents, is_debt_payoff, sort_order, created_at, updated_at)
+VALUES($1,$2,0,FALSE,(SELECT COALESCE(MAX(sort_order), 0) + 1 FROM budget_categories WHERE budget_id = $1),$3,$3)
+RETURNING id`, budgetID, strings.TrimSpace(name), now).Scan(&categoryID)
+	}
+	return categoryID, err
+}
+
+// StatementImportItem is one accepted statement transaction: a payment when DebtID > 0,
+// otherwise an expense in CategoryName.
+type StatementImportItem struct {
+	Txn          OFXTransaction
+	AmountCents  int64 // the payment or expense amount, always positive
+	DebtID       int64
+	CategoryName string
+	Note         string
+}
+
+// importStatementTransactions records accepted transactions in one transaction. Payments lower
+// the debt balance as addPayment does. Transactions whose FITID was already imported (e.g. by a
+// concurrent import) are skipped, as are items without a positive amount. Returns how many
+// payments and expenses were recorded.
+func importStatementTransactions(db *sql.DB, userID int64, items []StatementImportItem) (payments, expenses int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, it := range items {
+		if it.AmountCents <= 0 {
+			continue
+		}
+		res, err := tx.Exec(`
+INSERT INTO imported_transactions(user_id, account_id, fitid, imported_at)
+VALUES($1,$2,$3,$4)
+ON CONFLICT DO NOTHING`, userID, it.Txn.AccountID, it.Txn.FITID, now)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		amount := it.AmountCents
+		if it.DebtID > 0 {
+			if _, err := addPaymentTx(tx, userID, it.DebtID, it.Txn.PostedOn, amount, it.Note, 0); err != nil {
+				return 0, 0, err
+			}
+			payments++
+			continue
+		}
+		categoryID, err := categoryForMonthTx(tx, userID, it.Txn.PostedOn.Year(), int(it.Txn.PostedOn.Month()), it.CategoryName)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := tx.Exec(`
+INSERT INTO budget_expenses(budget_category_id, spent_on, amount_cents, note, created_at)
+VALUES($1,$2,$3,$4,$5)`, categoryID, it.Txn.PostedOn, amount, it.Note, now); err != nil {
+			return 0, 0, err
+		}
+		expenses++
+	}
+
+	return payments, expenses, tx.Commit()
+}
+
+// --- Expense categorization rules ---
+
+type CategoryRule struct {
+	ID             int64
+	UserID         int64
+	MatchType      string // "contains" or "regex"
+	Pattern        string
+	MinAmountCents sql.NullInt64
+	MaxAmountCents sql.NullInt64
+	CategoryName   string
+	CreatedAt      time.Time
+	re             *regexp.Regexp // compiled Pattern of a "regex" rule, set by compile
+}
+
+func listCategoryRules(db *sql.DB, userID int64) ([]CategoryRule, error) {
+	rows, err := db.Query(`
+SELECT id, user_id, match_type, pattern, min_amount_cents, max_amount_cents, category_name, created_at
+FROM category_rules WHERE user_id = $1 ORDER BY id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CategoryRule
+	for rows.Next() {
+		var cr CategoryRule
+		if err := rows.Scan(&cr.ID, &cr.UserID, &cr.MatchType, &cr.Pattern, &cr.MinAmountCents, &cr.MaxAmountCents, &cr.CategoryName, &cr.CreatedAt); err != nil {
+			return nil, err
+		}
+		// Patterns are validated when saved; one that no longer compiles never matches.
+		if err := cr.compile(); err != nil {
+			log.Printf("Category rule %d has an invalid pattern %q: %v", cr.ID, cr.Pattern, err)
+		}
+		out = append(out, cr)
+	}
+	return out, rows.Err()
+}
+
+func createCategoryRule(db *sql.DB, userID int64, cr CategoryRule) (int64, error) {
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO category_rules(user_id, match_type, pattern, min_amount_cents, max_amount_cents, category_name, created_at)
+VALUES($1,$2,$3,$4,$5,$6,$7)
+RETURNING id`, userID, cr.MatchType, cr.Pattern, cr.MinAmountCents, cr.MaxAmountCents, cr.CategoryName, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+func deleteCategoryRule(db *sql.DB, userID, id int64) error {
+	_, err := db.Exec(`DELETE FROM category_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// CategorizedNote is a past expense note and the category it was filed under.
+type CategorizedNote struct {
+	Note         string
+	CategoryName string
+}
+
+// listCategorizedNotes returns the notes of the user's most recent expenses (up to limit) with
+// their category names, for learning rule suggestions.
+func listCategorizedNotes(db *sql.DB, userID int64, limit int) ([]CategorizedNote, error) {
+	rows, err := db.Query(`
+SELECT e.note, c.name
+FROM budget_expenses e
+JOIN budget_categories c ON e.budget_category_id = c.id
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1 AND e.note <> ''
+ORDER BY e.spent_on DESC, e.id DESC
+LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CategorizedNote
+	for rows.Next() {
+		var cn CategorizedNote
+		if err := rows.Scan(&cn.Note, &cn.CategoryName); err != nil {
+			return nil, err
+		}
+		out = append(out, cn)
+	}
+	return out, rows.Err()
+}
+
+// addExpenseToNamedCategory records an expense in the named category of the year/month budget,
+// creating the budget or category if needed. Returns the category ID.
+func addExpenseToNamedCategory(db *sql.DB, userID int64, year, month int, categoryName string, spentOn time.Time, amountCents int64, note string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	categoryID, err := categoryForMonthTx(tx, userID, year, month, categoryName)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+INSERT INTO budget_expenses(budget_category_id, spent_on, amount_cents, note, created_at)
+VALUES($1,$2,$3,$4,$5)`, categoryID, spentOn, amountCents, note, time.Now().UTC()); err != nil {
+		return 0, err
+	}
+	return categoryID, tx.Commit()
+}
+
+// --- Budget copying ---
+
+type BudgetPreferences struct {
+	UserID           int64
+	AutoCopyPrevious bool
+	AlertEmail       bool // email over-budget alerts as well as showing them in the app
+	UpdatedAt        time.Time
+}
+
+// getBudgetPreferences returns the user's budget settings, or defaults when none are saved.
+func getBudgetPreferences(db *sql.DB, userID int64) (BudgetPreferences, error) {
+	p := BudgetPreferences{UserID: userID}
+	err := db.QueryRow(`SELECT auto_copy_previous, alert_email, updated_at FROM budget_preferences WHERE user_id = $1`, userID).
+		Scan(&p.AutoCopyPrevious, &p.AlertEmail, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	return p, err
+}
+
+func saveBudgetPreferences(db *sql.DB, userID int64, p BudgetPreferences) error {
+	_, err := db.Exec(`
+INSERT INTO budget_preferences(user_id, auto_copy_previous, alert_email, updated_at)
+VALUES($1,$2,$3,$4)
+ON CONFLICT (user_id) DO UPDATE SET auto_copy_previous = EXCLUDED.auto_copy_previous, alert_email = EXCLUDED.alert_email, updated_at = EXCLUDED.updated_at`,
+		userID, p.AutoCopyPrevious, p.AlertEmail, time.Now().UTC())
+	return err
+}
+
+// copyBudget starts toBudgetID from fromBudgetID: income is copied and every category (name,
+// limit, debt-payoff flag, sort order) the target doesn't already have by name is added.
+// Expenses are not copied. Returns the number of categories added.
+func copyBudget(db *sql.DB, userID, fromBudgetID, toBudgetID int64) (int, error) {
+	if _, err := getBudget(db, userID, fromBudgetID); err != nil {
+		return 0, err
+	}
+	if err := checkBudgetOpen(db, userID, toBudgetID); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n, err := copyBudgetTx(tx, fromBudgetID, toBudgetID, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func copyBudgetTx(tx *sql.Tx, fromBudgetID, toBudgetID int64, now time.Time) (int, error) {
+	if _, err := tx.Exec(`
+UPDATE budgets t SET income_cents = f.income_cents, income_from_salary = f.income_from_salary, updated_at = $3
+FROM budgets f WHERE f.id = $1 AND t.id = $2`, fromBudgetID, toBudgetID, now); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+INSERT INTO budget_categories(budget_id, name, limit_cents, is_debt_payoff, sort_order, rollover, sinking_target_cents, sinking_due_on, alert_thresholds, created_at, updated_at)
+SELECT $1, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.rollover, c.sinking_target_cents, c.sinking_due_on, c.alert_thresholds, $3, $3
+FROM budget_categories c
+WHERE c.budget_id = $2
+  AND NOT EXISTS (SELECT 1 FROM budget_categories t WHERE t.budget_id = $1 AND t.name = c.name)
+ORDER BY c.sort_order ASC, c.id ASC`, toBudgetID, fromBudgetID, now)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// --- Budget templates ---
+
+type BudgetTemplate struct {
+	ID            int64
+	UserID        int64
+	Name          string
+	CategoryCount int
+	LimitCents    int64 // total of category limits
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type BudgetTemplateCategory struct {
+	ID                 int64
+	TemplateID         int64
+	Name               string
+	LimitCents         int64
+	IsDebtPayoff       bool
+	SortOrder          int
+	Rollover           bool
+	SinkingTargetCents int64
+	SinkingDueOn       sql.NullTime
+	AlertThresholds    string
+}
+
+func listBudgetTemplates(db *sql.DB, userID int64) ([]BudgetTemplate, error) {
+	rows, err := db.Query(`
+SELECT t.id, t.user_id, t.name, COUNT(c.id), COALESCE(SUM(c.limit_cents), 0), t.created_at, t.updated_at
+FROM budget_templates t
+LEFT JOIN budget_template_categories c ON c.template_id = t.id
+WHERE t.user_id = $1
+GROUP BY t.id
+ORDER BY t.name ASC, t.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BudgetTemplate
+	for rows.Next() {
+		var t BudgetTemplate
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.CategoryCount, &t.LimitCents, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func getBudgetTemplate(db *sql.DB, userID, templateID int64) (BudgetTemplate, error) {
+	var t BudgetTemplate
+	err := db.QueryRow(`
+SELECT id, user_id, name, created_at, updated_at
+FROM budget_templates WHERE id = $1 AND user_id = $2`, templateID, userID).
+		Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return BudgetTemplate{}, err
+	}
+	return t, nil
+}
+
+// createBudgetTemplate creates a template, seeded with the categories of fromBudgetID when > 0.
+func createBudgetTemplate(db *sql.DB, userID int64, name string, fromBudgetID int64) (int64, error) {
+	if fromBudgetID > 0 {
+		if _, err := getBudget(db, userID, fromBudgetID); err != nil {
+			return 0, err
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var id int64
+	if err := tx.QueryRow(`
+INSERT INTO budget_templates(user_id, name, created_at, updated_at)
+VALUES($1,$2,$3,$3)
+RETURNING id`, userID, name, now).Scan(&id); err != nil {
+		return 0, err
+	}
+	if fromBudgetID > 0 {
+		if _, err := tx.Exec(`
+INSERT INTO budget_template_categories(template_id, name, limit_cents, is_debt_payoff, sort_order, rollover, sinking_target_cents, sinking_due_on, alert_thresholds)
+SELECT $1, name, limit_cents, is_debt_payoff, sort_order, rollover, sinking_target_cents, sinking_due_on, alert_thresholds
+FROM budget_categories WHERE budget_id = $2
+ORDER BY sort_order ASC, id ASC`, id, fromBudgetID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+func renameBudgetTemplate(db *sql.DB, userID, templateID int64, name string) error {
+	res, err := db.Exec(`UPDATE budget_templates SET name = $1, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+		name, time.Now().UTC(), templateID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func deleteBudgetTemplate(db *sql.DB, userID, templateID int64) error {
+	_, err := db.Exec(`DELETE FROM budget_templates WHERE id = $1 AND user_id = $2`, templateID, userID)
+	return err
+}
+
+func listTemplateCategories(db *sql.DB, userID, templateID int64) ([]BudgetTemplateCategory, error) {
+	rows, err := db.Query(`
+SELECT c.id, c.template_id, c.name, c.limit_cents, c.is_debt_payoff, c.sort_order, c.rollover, c.sinking_target_cents, c.sinking_due_on, c.alert_thresholds
+FROM budget_template_categories c
+JOIN budget_templates t ON c.template_id = t.id
+WHERE c.template_id = $1 AND t.user_id = $2 ORDER BY c.sort_order ASC, c.id ASC`, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BudgetTemplateCategory
+	for rows.Next() {
+		var c BudgetTemplateCategory
+		if err := rows.Scan(&c.ID, &c.TemplateID, &c.Name, &c.LimitCents, &c.IsDebtPayoff, &c.SortOrder, &c.Rollover, &c.SinkingTargetCents, &c.SinkingDueOn, &c.AlertThresholds); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func addTemplateCategory(db *sql.DB, userID int64, c BudgetTemplateCategory) error {
+	if _, err := getBudgetTemplate(db, userID, c.TemplateID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`
+INSERT INTO budget_template_categories(template_id, name, limit_cents, is_debt_payoff, sort_order)
+VALUES($1,$2,$3,$4,$5)`, c.TemplateID, c.Name, c.LimitCents, c.IsDebtPayoff, c.SortOrder)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE budget_templates SET updated_at = $1 WHERE id = $2`, time.Now().UTC(), c.TemplateID)
+	return err
+}
+
+func updateTemplateCategory(db *sql.DB, userID int64, c BudgetTemplateCategory) error {
+	res, err := db.Exec(`
+UPDATE budget_template_categories SET name = $1, limit_cents = $2, is_debt_payoff = $3, sort_order = $4
+WHERE id = $5 AND template_id IN (SELECT id FROM budget_templates WHERE user_id = $6)`,
+		c.Name, c.LimitCents, c.IsDebtPayoff, c.SortOrder, c.ID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func deleteTemplateCategory(db *sql.DB, userID, categoryID int64) error {
+	_, err := db.Exec(`
+DELETE FROM budget_template_categories
+WHERE id = $1 AND template_id IN (SELECT id FROM budget_templates WHERE user_id = $2)`, categoryID, userID)
+	return err
+}
+
+// TemplateChange describes what applying a template did to one category.
+type TemplateChange struct {
+	Name            string
+	Action          string // "added", "updated" or "unchanged"
+	OldLimitCents   int64
+	NewLimitCents   int64
+	OldIsDebtPayoff bool
+	NewIsDebtPayoff bool
+}
+
+// applyBudgetTemplate merges a template into a budget by category name: missing categories are
+// added with the template's rollover, sinking-fund and alert settings, categories with the same
+// name take the template's limit and debt-payoff flag (keeping their own settings, as copyBudget
+// does), and categories not in the template are left alone (returned as kept). Expenses are
+// untouched.
+func applyBudgetTemplate(db *sql.DB, userID, templateID, budgetID int64) (changes []TemplateChange, kept []string, err error) {
+	tplCats, err := listTemplateCategories(db, userID, templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing, err := listCategoriesForBudget(db, budgetID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]BudgetCategory{}
+	for _, c := range existing {
+		key := strings.ToLower(strings.TrimSpace(c.Name))
+		if _, dup := byName[key]; !dup {
+			byName[key] = c
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	inTemplate := map[string]bool{}
+	for _, tc := range tplCats {
+		key := strings.ToLower(strings.TrimSpace(tc.Name))
+		if inTemplate[key] {
+			continue
+		}
+		inTemplate[key] = true
+		change := TemplateChange{Name: tc.Name, NewLimitCents: tc.LimitCents, NewIsDebtPayoff: tc.IsDebtPayoff}
+		cur, ok := byName[key]
+		switch {
+		case !ok:
+			change.Action = "added"
+			if _, err := tx.Exec(`
+INSERT INTO budget_categories(budget_id, name, limit_cents, is_debt_payoff, sort_order, rollover, sinking_target_cents, sinking_due_on, alert_thresholds, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$10)`, budgetID, tc.Name, tc.LimitCents, tc.IsDebtPayoff, tc.SortOrder,
+				tc.Rollover, tc.SinkingTargetCents, tc.SinkingDueOn, tc.AlertThresholds, now); err != nil {
+				return nil, nil, err
+			}
+		case cur.LimitCents != tc.LimitCents || cur.IsDebtPayoff != tc.IsDebtPayoff:
+			change.Action = "updated"
+			change.Name = cur.Name
+			change.OldLimitCents, change.OldIsDebtPayoff = cur.LimitCents, cur.IsDebtPayoff
+			if _, err := tx.Exec(`UPDATE budget_categories SET limit_cents = $1, is_debt_payoff = $2, updated_at = $3 WHERE id = $4`,
+				tc.LimitCents, tc.IsDebtPayoff, now, cur.ID); err != nil {
+				return nil, nil, err
+			}
+		default:
+			change.Action = "unchanged"
+			change.Name = cur.Name
+			change.OldLimitCents, change.OldIsDebtPayoff = cur.LimitCents, cur.IsDebtPayoff
+		}
+		changes = append(changes, change)
+	}
+	for _, c := range existing {
+		if !inTemplate[strings.ToLower(strings.TrimSpace(c.Name))] {
+			kept = append(kept, c.Name)
+		}
+	}
+	return changes, kept, tx.Commit()
+}
+
+// --- Income sources and receipts ---
+
+type IncomeSource struct {
+	ID          int64
+	UserID      int64
+	Name        string
+	AmountCents int64  // per paycheque
+	Frequency   string // "weekly", "biweekly", "semimonthly", "monthly" or "irregular"
+	NextPayDate time.Time
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type IncomeReceipt struct {
+	ID             int64
+	UserID         int64
+	IncomeSourceID sql.NullInt64
+	SourceName     string // empty when not linked to a source
+	ReceivedOn     time.Time
+	AmountCents    int64
+	Note           string
+	CreatedAt      time.Time
+}
+
+const incomeSourceColumns = `id, user_id, name, amount_cents, frequency, next_pay_date, active, created_at, updated_at`
+
+func scanIncomeSource(sc interface{ Scan(...any) error }) (IncomeSource, error) {
+	var s IncomeSource
+	err := sc.Scan(&s.ID, &s.UserID, &s.Name, &s.AmountCents, &s.Frequency, &s.NextPayDate, &s.Active, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+func listIncomeSources(db *sql.DB, userID int64) ([]IncomeSource, error) {
+	rows, err := db.Query(`
+SELECT `+incomeSourceColumns+`
+FROM income_sources
+WHERE user_id = $1
+ORDER BY active DESC, name ASC, id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []IncomeSource
+	for rows.Next() {
+		s, err := scanIncomeSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func getIncomeSource(db *sql.DB, userID, id int64) (IncomeSource, error) {
+	row := db.QueryRow(`SELECT `+incomeSourceColumns+` FROM income_sources WHERE id = $1 AND user_id = $2`, id, userID)
+	return scanIncomeSource(row)
+}
+
+func createIncomeSource(db *sql.DB, userID int64, s IncomeSource) (int64, error) {
+	now := time.Now().UTC()
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO income_sources(user_id, name, amount_cents, frequency, next_pay_date, active, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,TRUE,$6,$6)
+RETURNING id`, userID, s.Name, s.AmountCents, s.Frequency, s.NextPayDate, now).Scan(&id)
+	return id, err
+}
+
+func updateIncomeSource(db *sql.DB, userID int64, s IncomeSource) error {
+	res, err := db.Exec(`
+UPDATE income_sources SET name = $1, amount_cents = $2, frequency = $3, next_pay_date = $4, active = $5, updated_at = $6
+WHERE id = $7 AND user_id = $8`, s.Name, s.AmountCents, s.Frequency, s.NextPayDate, s.Active, time.Now().UTC(), s.ID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func deleteIncomeSource(db *sql.DB, userID, id int64) error {
+	_, err := db.Exec(`DELETE FROM income_sources WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// listIncomeReceipts returns income received in [from, to), newest first.
+func listIncomeReceipts(db *sql.DB, userID int64, from, to time.Time) ([]IncomeReceipt, error) {
+	rows, err := db.Query(`
+SELECT r.id, r.user_id, r.income_source_id, COALESCE(s.name, ''), r.received_on, r.amount_cents, r.note, r.created_at
+FROM income_receipts r
+LEFT JOIN income_sources s ON r.income_source_id = s.id
+WHERE r.user_id = $1 AND r.received_on >= $2 AND r.received_on < $3
+ORDER BY r.received_on DESC, r.id DESC`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []IncomeReceipt
+	for rows.Next() {
+		var r IncomeReceipt
+		if err := rows.Scan(&r.ID, &r.UserID, &r.IncomeSourceID, &r.SourceName, &r.ReceivedOn, &r.AmountCents, &r.Note, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func createIncomeReceipt(db *sql.DB, userID int64, r IncomeReceipt) (int64, error) {
+	if r.IncomeSourceID.Valid {
+		if _, err := getIncomeSource(db, userID, r.IncomeSourceID.Int64); err != nil {
+			return 0, err
+		}
+	}
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO income_receipts(user_id, income_source_id, received_on, amount_cents, note, created_at)
+VALUES($1,$2,$3,$4,$5,$6)
+RETURNING id`, userID, r.IncomeSourceID, r.ReceivedOn, r.AmountCents, r.Note, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+func deleteIncomeReceipt(db *sql.DB, userID, id int64) error {
+	_, err := db.Exec(`DELETE FROM income_receipts WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// --- Budget reports ---
+
+// CategoryActual is one budget category's limit and spending in one month.
+type CategoryActual struct {
+	Year       int
+	Month      int
+	Name       string
+	LimitCents int64
+	SpentCents int64
+}
+
+// listCategoryActuals returns every category in the user's budgets from fromYM to toYM
+// (inclusive, as year*12+month), oldest first, with their spending (see categorySpentSQL).
+func listCategoryActuals(db *sql.DB, userID int64, fromYM, toYM int) ([]CategoryActual, error) {
+	rows, err := db.Query(`
+SELECT b.year, b.month, c.name, c.limit_cents, `+categorySpentSQL+`
+FROM budget_categories c
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1 AND b.year * 12 + b.month BETWEEN $2 AND $3
+ORDER BY b.year ASC, b.month ASC, c.sort_order ASC, c.id ASC`, userID, fromYM, toYM)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CategoryActual
+	for rows.Next() {
+		var c CategoryActual
+		if err := rows.Scan(&c.Year, &c.Month, &c.Name, &c.LimitCents, &c.SpentCents); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// MonthTotal is a month's budgeted income, spending (expenses that are not debt payments) and
+// debt payments.
+type MonthTotal struct {
+	Year              int
+	Month             int
+	IncomeCents       int64
+	SpentCents        int64
+	DebtPaymentsCents int64
+}
+
+// listMonthTotals returns totals for each month from fromYM to toYM that has a budget or payments.
+func listMonthTotals(db *sql.DB, userID int64, fromYM, toYM int) ([]MonthTotal, error) {
+	byYM := map[int]*MonthTotal{}
+	get := func(year, month int) *MonthTotal {
+		ym := year*12 + month
+		if byYM[ym] == nil {
+			byYM[ym] = &MonthTotal{Year: year, Month: month}
+		}
+		return byYM[ym]
+	}
+	scan := func(query string, apply func(t *MonthTotal, cents int64)) error {
+		rows, err := db.Query(query, userID, fromYM, toYM)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var year, month int
+			var cents int64
+			if err := rows.Scan(&year, &month, &cents); err != nil {
+				return err
+			}
+			apply(get(year, month), cents)
+		}
+		return rows.Err()
+	}
+	if err := scan(`
+SELECT year, month, income_cents FROM budgets
+WHERE user_id = $1 AND year * 12 + month BETWEEN $2 AND $3`,
+		func(t *MonthTotal, c int64) { t.IncomeCents = c }); err != nil {
+		return nil, err
+	}
+	if err := scan(`
+SELECT b.year, b.month, COALESCE(SUM(e.amount_cents), 0)
+FROM budget_expenses e
+JOIN budget_categories c ON e.budget_category_id = c.id
+JOIN budgets b ON c.budget_id = b.id
+WHERE b.user_id = $1 AND b.year * 12 + b.month BETWEEN $2 AND $3 AND e.payment_id IS NULL
+GROUP BY b.year, b.month`,
+		func(t *MonthTotal, c int64) { t.SpentCents = c }); err != nil {
+		return nil, err
+	}
+	if err := scan(`
+SELECT EXTRACT(YEAR FROM p.paid_on)::int, EXTRACT(MONTH FROM p.paid_on)::int, SUM(p.amount_cents)
+FROM payments p
+JOIN debts d ON p.debt_id = d.id
+WHERE d.user_id = $1
+  AND EXTRACT(YEAR FROM p.paid_on)::int * 12 + EXTRACT(MONTH FROM p.paid_on)::int BETWEEN $2 AND $3
+GROUP BY 1, 2`,
+		func(t *MonthTotal, c int64) { t.DebtPaymentsCents = c }); err != nil {
+		return nil, err
+	}
+	out := make([]MonthTotal, 0, len(byYM))
+	for _, t := range byYM {
+		out = append(out, *t)
+	}
+	return out, nil
+}
+
+// --- Over-budget alerts ---
+
+// BudgetAlert is a category whose spending crossed one of its alert thresholds.
+type BudgetAlert struct {
+	ID               int64
+	BudgetCategoryID int64
+	CategoryName     string
+	Year             int
+	Month            int
+	ThresholdPct     int
+	LimitCents       int64
+	SpentCents       int64 // spending when the threshold was crossed
+	CreatedAt        time.Time
+}
+
+// claimBudgetAlert records that a category crossed thresholdPct. It returns false if that
+// threshold already alerted, which is what keeps it to one alert per month.
+func claimBudgetAlert(db *sql.DB, userID, categoryID int64, thresholdPct int, limitCents, spentCents int64) (bool, error) {
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO budget_alerts(user_id, budget_category_id, threshold_pct, limit_cents, spent_cents, created_at)
+VALUES($1,$2,$3,$4,$5,$6)
+ON CONFLICT (budget_category_id, threshold_pct) DO NOTHING
+RETURNING id`, userID, categoryID, thresholdPct, limitCents, spentCents, time.Now().UTC()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// listBudgetAlerts returns the user's alerts that have not been dismissed, newest first.
+func listBudgetAlerts(db *sql.DB, userID int64) ([]BudgetAlert, error) {
+	rows, err := db.Query(`
+SELECT a.id, a.budget_category_id, c.name, b.year, b.month, a.threshold_pct, a.limit_cents, a.spent_cents, a.created_at
+FROM budget_alerts a
+JOIN budget_categories c ON a.budget_category_id = c.id
+JOIN budgets b ON c.budget_id = b.id
+WHERE a.user_id = $1 AND a.dismissed_at IS NULL
+ORDER BY a.created_at DESC, a.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BudgetAlert
+	for rows.Next() {
+		var al BudgetAlert
+		if err := rows.Scan(&al.ID, &al.BudgetCategoryID, &al.CategoryName, &al.Year, &al.Month, &al.ThresholdPct, &al.LimitCents, &al.SpentCents, &al.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, al)
+	}
+	return out, rows.Err()
+}
+
+// dismissBudgetAlert hides one alert, or every open alert when alertID is 0.
+func dismissBudgetAlert(db *sql.DB, userID, alertID int64) error {
+	_, err := db.Exec(`
+UPDATE budget_alerts SET dismissed_at = $1
+WHERE user_id = $2 AND dismissed_at IS NULL AND ($3::bigint = 0 OR id = $3)`, time.Now().UTC(), userID, alertID)
+	return err
+}
+
+// --- Split expenses ---
+
+// ExpenseSplit is one receipt divided across categories of a month's budget. Each allocation is
+// a budget expense with SplitID set.
+type ExpenseSplit struct {
+	ID          int64
+	BudgetID    int64
+	SpentOn     time.Time
+	AmountCents int64
+	Note        string
+	Allocations []SplitAllocation
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type SplitAllocation struct {
+	ExpenseID    int64
+	CategoryID   int64
+	CategoryName string
+	AmountCents  int64
+}
+
+// listExpenseSplits returns a budget's split expenses with their allocations, newest first.
+func listExpenseSplits(db *sql.DB, userID, budgetID int64) ([]ExpenseSplit, error) {
+	rows, err := db.Query(`
+SELECT s.id, s.budget_id, s.spent_on, s.amount_cents, s.note, s.created_at, s.updated_at
+FROM expense_splits s
+JOIN budgets b ON s.budget_id = b.id
+WHERE s.budget_id = $1 AND b.user_id = $2
+ORDER BY s.spent_on DESC, s.id DESC`, budgetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ExpenseSplit
+	index := map[int64]int{}
+	for rows.Next() {
+		var s ExpenseSplit
+		if err := rows.Scan(&s.ID, &s.BudgetID, &s.SpentOn, &s.AmountCents, &s.Note, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[s.ID] = len(out)
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	allocs, err := listSplitAllocations(db, `s.budget_id = $1`, budgetID)
+	if err != nil {
+		return nil, err
+	}
+	for splitID, list := range allocs {
+		if i, ok := index[splitID]; ok {
+			out[i].Allocations = list
+		}
+	}
+	return out, nil
+}
+
+func getExpenseSplit(db *sql.DB, userID, splitID int64) (ExpenseSplit, error) {
+	var s ExpenseSplit
+	err := db.QueryRow(`
+SELECT s.id, s.budget_id, s.spent_on, s.amount_cents, s.note, s.created_at, s.updated_at
+FROM expense_splits s
+JOIN budgets b ON s.budget_id = b.id
+WHERE s.id = $1 AND b.user_id = $2`, splitID, userID).
+		Scan(&s.ID, &s.BudgetID, &s.SpentOn, &s.AmountCents, &s.Note, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return ExpenseSplit{}, err
+	}
+	allocs, err := listSplitAllocations(db, `s.id = $1`, splitID)
+	if err != nil {
+		return ExpenseSplit{}, err
+	}
+	s.Allocations = allocs[s.ID]
+	return s, nil
+}
+
+// listSplitAllocations returns allocations by split ID for the splits matching where (on s).
+func listSplitAllocations(db *sql.DB, where string, arg int64) (map[int64][]SplitAllocation, error) {
+	rows, err := db.Query(`
+SELECT e.split_id, e.id, c.id, c.name, e.amount_cents
+FROM budget_expenses e
+JOIN expense_splits s ON e.split_id = s.id
+JOIN budget_categories c ON e.budget_category_id = c.id
+WHERE `+where+`
+ORDER BY c.sort_order ASC, c.id ASC`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64][]SplitAllocation{}
+	for rows.Next() {
+		var splitID int64
+		var a SplitAllocation
+		if err := rows.Scan(&splitID, &a.ExpenseID, &a.CategoryID, &a.CategoryName, &a.AmountCents); err != nil {
+			return nil, err
+		}
+		out[splitID] = append(out[splitID], a)
+	}
+	return out, rows.Err()
+}
+
+// createExpenseSplit records a split expense in one transaction. Every allocation's category
+// must belong to the budget; the split's total is the sum of the allocations.
+func createExpenseSplit(db *sql.DB, userID, budgetID int64, spentOn time.Time, note string, allocs []SplitAllocation) (int64, error) {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var id int64
+	if err := tx.QueryRow(`
+INSERT INTO expense_splits(budget_id, spent_on, amount_cents, note, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,$5)
+RETURNING id`, budgetID, spentOn, splitTotal(allocs), note, now).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := insertSplitAllocationsTx(tx, budgetID, id, spentOn, note, allocs, now); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// updateExpenseSplit replaces a split's date, note and allocations.
+func updateExpenseSplit(db *sql.DB, userID, splitID int64, spentOn time.Time, note string, allocs []SplitAllocation) error {
+	s, err := getExpenseSplit(db, userID, splitID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, s.BudgetID); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`DELETE FROM budget_expenses WHERE split_id = $1`, splitID); err != nil {
+		return err
+	}
+	if err := insertSplitAllocationsTx(tx, s.BudgetID, splitID, spentOn, note, allocs, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE expense_splits SET spent_on = $1, amount_cents = $2, note = $3, updated_at = $4 WHERE id = $5`,
+		spentOn, splitTotal(allocs), note, now, splitID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSplitAllocationsTx(tx *sql.Tx, budgetID, splitID int64, spentOn time.Time, note string, allocs []SplitAllocation, now time.Time) error {
+	for _, a := range allocs {
+		res, err := tx.Exec(`
+INSERT INTO budget_expenses(budget_category_id, spent_on, amount_cents, note, split_id, created_at)
+SELECT c.id, $3, $4, $5, $6, $7 FROM budget_categories c WHERE c.id = $1 AND c.budget_id = $2`,
+			a.CategoryID, budgetID, spentOn, a.AmountCents, note, splitID, now)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("category %d is not in budget %d", a.CategoryID, budgetID)
+		}
+	}
+	return nil
+}
+
+// deleteExpenseSplit removes a split expense and all its allocations.
+func deleteExpenseSplit(db *sql.DB, userID, splitID int64) error {
+	s, err := getExpenseSplit(db, userID, splitID)
+	if err != nil {
+		return err
+	}
+	if err := checkBudgetOpen(db, userID, s.BudgetID); err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM expense_splits WHERE id = $1`, splitID)
+	return err
+}
+
+// --- Transactions register ---
+
+// RegisterFilter selects and orders rows of the transactions register.
+type RegisterFilter struct {
+	From     time.Time // inclusive
+	To       time.Time // inclusive
+	Search   string    // note contains, ignoring case
+	Category string    // category name; a split matches when any allocation does
+	MinCents sql.NullInt64
+	MaxCents sql.NullInt64
+	Sort     string // see sortRegister
+	Page     int    // 1-based
+}
+
+// RegisterRow is one line of the register: a single expense, or a whole split expense
+// (SplitID > 0) with its categories joined in CategoryName.
+type RegisterRow struct {
+	ExpenseID    int64
+	SplitID      int64
+	SpentOn      time.Time
+	AmountCents  int64
+	Note         string
+	CategoryID   int64
+	CategoryName string
+	Year         int
+	Month        int
+	DebtID       int64 // debt of a linked payment, 0 if none
+	RunningCents int64 // set by registerPage
+}
+
+// listRegister returns every register row matching f's filters, unordered; sortRegister and
+// registerPage order and page them.
+func listRegister(db *sql.DB, userID int64, f RegisterFilter) ([]RegisterRow, error) {
+	query := `
+WITH register AS (
+  SELECT e.id AS expense_id, 0::bigint AS split_id, e.spent_on, e.amount_cents, e.note,
+    c.id AS category_id, c.name AS category_name, ARRAY[LOWER(TRIM(c.name))] AS category_keys,
+    b.year, b.month, COALESCE(p.debt_id, 0) AS debt_id
+  FROM budget_expenses e
+  JOIN budget_categories c ON e.budget_category_id = c.id
+  JOIN budgets b ON c.budget_id = b.id
+  LEFT JOIN payments p ON e.payment_id = p.id
+  WHERE b.user_id = $1 AND e.split_id IS NULL
+  UNION ALL
+  SELECT 0, s.id, s.spent_on, s.amount_cents, s.note,
+    0, STRING_AGG(c.name, ', ' ORDER BY c.sort_order, c.id), ARRAY_AGG(LOWER(TRIM(c.name))),
+    b.year, b.month, 0
+  FROM expense_splits s
+  JOIN budgets b ON s.budget_id = b.id
+  JOIN budget_expenses e ON e.split_id = s.id
+  JOIN budget_categories c ON e.budget_category_id = c.id
+  WHERE b.user_id = $1
+  GROUP BY s.id, b.year, b.month
+)
+SELECT expense_id, split_id, spent_on, amount_cents, note, category_id, category_name, year, month, debt_id
+FROM register
+WHERE spent_on >= $2 AND spent_on <= $3`
+	args := []any{userID, f.From, f.To}
+	n := 4
+
+	if f.Search != "" {
+		query += fmt.Sprintf(` AND note ILIKE $%d ESCAPE '\'`, n)
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+		n++
+	}
+	if f.Category != "" {
+		query += fmt.Sprintf(" AND $%d = ANY(category_keys)", n)
+		args = append(args, envelopeKey(f.Category))
+		n++
+	}
+	if f.MinCents.Valid {
+		query += fmt.Sprintf(" AND amount_cents >= $%d", n)
+		args = append(args, f.MinCents.Int64)
+		n++
+	}
+	if f.MaxCents.Valid {
+		query += fmt.Sprintf(" AND amount_cents <= $%d", n)
+		args = append(args, f.MaxCents.Int64)
+		n++
+	}
+
+	result, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	var rows []RegisterRow
+	for result.Next() {
+		var r RegisterRow
+		if err := result.Scan(&r.ExpenseID, &r.SplitID, &r.SpentOn, &r.AmountCents, &r.Note, &r.CategoryID, &r.CategoryName, &r.Year, &r.Month, &r.DebtID); err != nil {
+			return nil, err
+		}
+		rows = append(rows, r)
+	}
+	return rows, result.Err()
+}
+
+// likeEscaper escapes LIKE wildcards so a search matches them literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// moveBudgetExpense re-files an expense under the category called categoryName in the same
+// month's budget. Split allocations move with their split, and an expense that is a debt
+// payment can only move to another debt payoff category.
+func moveBudgetExpense(db *sql.DB, userID, expenseID int64, categoryName string) (BudgetCategory, error) {
+	exp, err := getBudgetExpense(db, userID, expenseID)
+	if err != nil {
+		return BudgetCategory{}, err
+	}
+	if exp.SplitID.Valid {
+		return BudgetCategory{}, fmt.Errorf("part of a split expense")
+	}
+	from, err := getBudgetCategory(db, userID, exp.BudgetCategoryID)
+	if err != nil {
+		return BudgetCategory{}, err
+	}
+	if err := checkBudgetOpen(db, userID, from.BudgetID); err != nil {
+		return BudgetCategory{}, err
+	}
+	var targetID int64
+	err = db.QueryRow(`SELECT id FROM budget_categories WHERE budget_id = $1 AND name = $2 ORDER BY id LIMIT 1`,
+		from.BudgetID, categoryName).Scan(&targetID)
+	if err != nil {
+		return BudgetCategory{}, err
+	}
+	target, err := getBudgetCategory(db, userID, targetID)
+	if err != nil {
+		return BudgetCategory{}, err
+	}
+	if exp.PaymentID.Valid && !target.IsDebtPayoff {
+		return BudgetCategory{}, fmt.Errorf("debt payments belong in a debt payoff category")
+	}
+	_, err = db.Exec(`UPDATE budget_expenses SET budget_category_id = $1 WHERE id = $2`, target.ID, expenseID)
+	return target, err
+}
+
+// --- Annual plan ---
+
+// AnnualPlanItem is a yearly amount for a category, spread over the months of Year.
+type AnnualPlanItem struct {
+	ID           int64
+	Year         int
+	CategoryName string
+	AnnualCents  int64
+	FromMonth    int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func listAnnualPlanItems(db *sql.DB, userID int64, year int) ([]AnnualPlanItem, error) {
+	rows, err := db.Query(`
+SELECT id, year, category_name, annual_cents, from_month, created_at, updated_at
+FROM annual_plan_items WHERE user_id = $1 AND year = $2 ORDER BY category_name ASC`, userID, year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AnnualPlanItem
+	for rows.Next() {
+		var p AnnualPlanItem
+		if err := rows.Scan(&p.ID, &p.Year, &p.CategoryName, &p.AnnualCents, &p.FromMonth, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// saveAnnualPlanItem stores the plan for a category (matched by envelopeKey) and spreads it
+// into the monthly limits of the open (not closed) months from FromMonth to December; see
+// annualShares. The shares are stored with the plan. Months that have a budget are set now,
+// adding the category where missing; the rest get their share when their budget is created
+// (applyAnnualPlanTx). Earlier and closed months keep their limits. Returns how many months the
+// plan is spread over and the amount spread over them.
+func saveAnnualPlanItem(db *sql.DB, userID int64, p AnnualPlanItem) (int, int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var planID int64
+	err = tx.QueryRow(`
+UPDATE annual_plan_items SET annual_cents = $1, from_month = $2, updated_at = $3
+WHERE user_id = $4 AND year = $5 AND LOWER(TRIM(category_name)) = $6
+RETURNING id`,
+		p.AnnualCents, p.FromMonth, now, userID, p.Year, envelopeKey(p.CategoryName)).Scan(&planID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+INSERT INTO annual_plan_items(user_id, year, category_name, annual_cents, from_month, created_at, updated_at)
+VALUES($1,$2,$3,$4,$5,$6,$6)
+RETURNING id`,
+			userID, p.Year, p.CategoryName, p.AnnualCents, p.FromMonth, now).Scan(&planID)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	closedLimits, err := closedPlanLimitsTx(tx, userID, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	months, shares := annualShares(p.AnnualCents, p.FromMonth, closedLimits)
+	if _, err := tx.Exec(`DELETE FROM annual_plan_shares WHERE plan_id = $1`, planID); err != nil {
+		return 0, 0, err
+	}
+	var spread int64
+	for i, month := range months {
+		spread += shares[i]
+		if _, err := tx.Exec(`INSERT INTO annual_plan_shares(plan_id, month, amount_cents) VALUES($1,$2,$3)`,
+			planID, month, shares[i]); err != nil {
+			return 0, 0, err
+		}
+		var budgetID int64
+		err := tx.QueryRow(`SELECT id FROM budgets WHERE user_id = $1 AND year = $2 AND month = $3`, userID, p.Year, month).Scan(&budgetID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		categoryID, err := categoryInBudgetTx(tx, budgetID, p.CategoryName)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := tx.Exec(`UPDATE budget_categories SET limit_cents = $1, updated_at = $2 WHERE id = $3`, shares[i], now, categoryID); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(months), spread, tx.Commit()
+}
+
+// closedPlanLimitsTx returns the closed months of a plan, from FromMonth on, with the limit the
+// planned category already has in each (0 when the month has no such category).
+func closedPlanLimitsTx(tx *sql.Tx, userID int64, p AnnualPlanItem) (map[int]int64, error) {
+	rows, err := tx.Query(`
+SELECT b.month, COALESCE((
+  SELECT c.limit_cents FROM budget_categories c
+  WHERE c.budget_id = b.id AND LOWER(TRIM(c.name)) = $4
+  ORDER BY c.sort_order ASC, c.id ASC LIMIT 1), 0)
+FROM budgets b
+WHERE b.user_id = $1 AND b.year = $2 AND b.month >= $3 AND b.closed_at IS NOT NULL`,
+		userID, p.Year, p.FromMonth, envelopeKey(p.CategoryName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	closed := map[int]int64{}
+	for rows.Next() {
+		var month int
+		var limit int64
+		if err := rows.Scan(&month, &limit); err != nil {
+			return nil, err
+		}
+		closed[month] = limit
+	}
+	return closed, rows.Err()
+}
+
+// applyAnnualPlanTx sets the limits of a newly created budget to the shares its month was given
+// when the user's annual plans were saved, adding the planned categories where missing.
+func applyAnnualPlanTx(tx *sql.Tx, userID, budgetID int64, year, month int) error {
+	rows, err := tx.Query(`
+SELECT i.category_name, s.amount_cents
+FROM annual_plan_shares s
+JOIN annual_plan_items i ON s.plan_id = i.id
+WHERE i.user_id = $1 AND i.year = $2 AND s.month = $3
+ORDER BY i.category_name ASC, i.id ASC`, userID, year, month)
+	if err != nil {
+		return err
+	}
+	type share struct {
+		name  string
+		cents int64
+	}
+	var shares []share
+	for rows.Next() {
+		var sh share
+		if err := rows.Scan(&sh.name, &sh.cents); err != nil {
+			rows.Close()
+			return err
+		}
+		shares = append(shares, sh)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, sh := range shares {
+		categoryID, err := categoryInBudgetTx(tx, budgetID, sh.name)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE budget_categories SET limit_cents = $1, updated_at = $2 WHERE id = $3`, sh.cents, now, categoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAnnualPlanItem removes a plan; the monthly limits it set are kept.
+func deleteAnnualPlanItem(db *sql.DB, userID, id int64) error {
+	_, err := db.Exec(`DELETE FROM annual_plan_items WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// --- Salary ---
+
+// SalaryProfile is the user's yearly salary, used to estimate after-tax income.
+type SalaryProfile struct {
+	UserID       int64
+	GrossCents   int64
+	Province     string
+	PayFrequency string
+	UpdatedAt    time.Time
+}
+
+// getSalaryProfile returns sql.ErrNoRows when the user has not saved a salary.
+func getSalaryProfile(db *sql.DB, userID int64) (SalaryProfile, error) {
+	p := SalaryProfile{UserID: userID}
+	err := db.QueryRow(`SELECT gross_cents, province, pay_frequency, updated_at FROM salary_profiles WHERE user_id = $1`, userID).
+		Scan(&p.GrossCents, &p.Province, &p.PayFrequency, &p.UpdatedAt)
+	return p, err
+}
+
+func saveSalaryProfile(db *sql.DB, userID int64, p SalaryProfile) error {
+	_, err := db.Exec(`
+INSERT INTO salary_profiles(user_id, gross_cents, province, pay_frequency, updated_at)
+VALUES($1,$2,$3,$4,$5)
+ON CONFLICT (user_id) DO UPDATE SET gross_cents = EXCLUDED.gross_cents, province = EXCLUDED.province, pay_frequency = EXCLUDED.pay_frequency, updated_at = EXCLUDED.updated_at`,
+		userID, p.GrossCents, p.Province, p.PayFrequency, time.Now().UTC())
+	return err
+}
+
+// deleteSalaryProfile removes the salary. Budgets that followed it keep their last income.
+func deleteSalaryProfile(db *sql.DB, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM salary_profiles WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE budgets SET income_from_salary = FALSE WHERE user_id = $1 AND income_from_salary`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listSalaryProfiles returns every saved salary.
+func listSalaryProfiles(db *sql.DB) ([]SalaryProfile, error) {
+	rows, err := db.Query(`SELECT user_id, gross_cents, province, pay_frequency, updated_at FROM salary_profiles ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SalaryProfile
+	for rows.Next() {
+		var p SalaryProfile
+		if err := rows.Scan(&p.UserID, &p.GrossCents, &p.Province, &p.PayFrequency, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// listSalaryBudgets returns the user's open budgets from year/month on whose income follows the
+// salary.
+func listSalaryBudgets(db *sql.DB, userID int64, year, month int) ([]Budget, error) {
+	rows, err := db.Query(`
+SELECT id, user_id, year, month, income_cents, zero_based, income_from_salary, closed_at, created_at, updated_at
+FROM budgets
+WHERE user_id = $1 AND income_from_salary AND closed_at IS NULL AND year * 12 + month >= $2
+ORDER BY year ASC, month ASC`, userID, year*12+month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Budget
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Year, &b.Month, &b.IncomeCents, &b.ZeroBased, &b.IncomeFromSalary, &b.ClosedAt, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// setBudgetSalaryIncome sets a budget's income to the salary's after-tax monthly pay and keeps
+// it following the salary.
+func setBudgetSalaryIncome(db *sql.DB, userID, budgetID int64, incomeCents int64) error {
+	if err := checkBudgetOpen(db, userID, budgetID); err != nil {
+		return err
+	}
+	res, err := db.Exec(`UPDATE budgets SET income_cents = $1, income_from_salary = TRUE, updated_at = $2 WHERE id = $3 AND user_id = $4`,
+		incomeCents, time.Now().UTC(), budgetID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Plan windfalls ---
+
+// Windfall is a one-time lump sum for the payoff plan.
+type Windfall struct {
+	ID          int64
+	Label       string
+	AmountCents int64
+	ExpectedOn  time.Time
+	CreatedAt   time.Time
+}
+
+func listWindfalls(db *sql.DB, userID int64) ([]Windfall, error) {
+	rows, err := db.Query(`
+SELECT id, label, amount_cents, expected_on, created_at
+FROM plan_windfalls WHERE user_id = $1 ORDER BY expected_on ASC, id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Windfall
+	for rows.Next() {
+		var w Windfall
+		if err := rows.Scan(&w.ID, &w.Label, &w.AmountCents, &w.ExpectedOn, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func createWindfall(db *sql.DB, userID int64, w Windfall) (int64, error) {
+	var id int64
+	err := db.QueryRow(`
+INSERT INTO plan_windfalls(user_id, label, amount_cents, expected_on, created_at)
+VALUES($1,$2,$3,$4,$5)
+RETURNING id`, userID, w.Label, w.AmountCents, w.ExpectedOn, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+func deleteWindfall(db *sql.DB, userID, id int64) error {
+	res, err := db.Exec(`DELETE FROM plan_windfalls WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
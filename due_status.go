@@ -0,0 +1,150 @@
+package main
+
+import "time"
+
+// dueSoonDays is how close the next due date must be for a "due soon" badge.
+const dueSoonDays = 7
+
+const (
+	DueStatusOverdue = "overdue"
+	DueStatusDueSoon = "due_soon"
+)
+
+// DueStatus describes where a debt stands against its payment cycle.
+// Status is DueStatusOverdue, DueStatusDueSoon, or "" when nothing is needed.
+type DueStatus struct {
+	Status           string
+	DueDate          time.Time // the missed due date (overdue) or the next one
+	OutstandingCents int64     // amount still needed to reach the minimum
+}
+
+// lastPassedDueDate returns the most recent due date strictly before today.
+func lastPassedDueDate(dueDay int, today time.Time) time.Time {
+	d := time.Date(today.Year(), today.Month(), dueDay, 0, 0, 0, 0, time.UTC)
+	if !d.Before(today) {
+		d = d.AddDate(0, -1, 0)
+	}
+	return d
+}
+
+// sumPaidBetween totals a debt's payments with from < paid_on <= to.
+func sumPaidBetween(payments []Payment, debtID int64, from, to time.Time) int64 {
+	var total int64
+	for _, p := range payments {
+		if p.DebtID != debtID {
+			continue
+		}
+		on := time.Date(p.PaidOn.Year(), p.PaidOn.Month(), p.PaidOn.Day(), 0, 0, 0, 0, time.UTC)
+		if on.After(from) && !on.After(to) {
+			total += p.AmountCents
+		}
+	}
+	return total
+}
+
+// cycleMissed reports whether the cycle ending on dueDate (the month up to and including it)
+// received less than the minimum, and how much was paid. A cycle that started before the debt
+// was added is never considered missed.
+func cycleMissed(d Debt, payments []Payment, dueDate time.Time) (missed bool, paidCents int64) {
+	start := dueDate.AddDate(0, -1, 0)
+	added := time.Date(d.CreatedAt.Year(), d.CreatedAt.Month(), d.CreatedAt.Day(), 0, 0, 0, 0, time.UTC)
+	if d.MinPaymentCents <= 0 || added.After(start) {
+		return false, 0
+	}
+	paid := sumPaidBetween(payments, d.ID, start, dueDate)
+	return paid < d.MinPaymentCents, paid
+}
+
+// passedDueDatesAfter returns the due dates after checkedThrough and before today, oldest first.
+func passedDueDatesAfter(dueDay int, checkedThrough, today time.Time) []time.Time {
+	var out []time.Time
+	for d := lastPassedDueDate(dueDay, today); d.After(checkedThrough); d = d.AddDate(0, -1, 0) {
+		out = append([]time.Time{d}, out...)
+	}
+	return out
+}
+
+// computeDueStatus works out the overdue / due-soon state of an active debt. payments must cover
+// at least the last two months. A late payment after a missed due date first covers the shortfall;
+// only the rest counts toward the next cycle.
+func computeDueStatus(d Debt, payments []Payment, today time.Time) DueStatus {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if !d.Active || d.BalanceCents <= 0 || d.MinPaymentCents <= 0 {
+		return DueStatus{}
+	}
+	lastDue := lastPassedDueDate(d.DueDay, today)
+	paidSince := sumPaidBetween(payments, d.ID, lastDue, today)
+	if missed, paid := cycleMissed(d, payments, lastDue); missed {
+		shortfall := d.MinPaymentCents - paid
+		if paidSince < shortfall {
+			return DueStatus{Status: DueStatusOverdue, DueDate: lastDue, OutstandingCents: shortfall - paidSince}
+		}
+		paidSince -= shortfall
+	}
+	next := nextDueDate(d.DueDay, today)
+	if next.Sub(today) <= dueSoonDays*24*time.Hour && paidSince < d.MinPaymentCents {
+		return DueStatus{Status: DueStatusDueSoon, DueDate: next, OutstandingCents: d.MinPaymentCents - paidSince}
+	}
+	return DueStatus{}
+}
+
+// detectMissedPayments records every cycle of every active debt that passed since the last check
+// as missed when less than the minimum was paid, posting the debt's late fee if it has one, so
+// cycles that passed while the server was down are still caught. Recording is keyed on
+// (debt, due date), so re-running never duplicates history or fees. Returns the number recorded.
+func (a *App) detectMissedPayments(now time.Time) (int, error) {
+	debts, err := listActiveDebtsAllUsers(a.db)
+	if err != nil {
+		return 0, err
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if err := skipIdleMissedChecks(a.db, today); err != nil {
+		return 0, err
+	}
+	// Payments must reach back to the start of the oldest cycle still to check.
+	since := today.AddDate(0, -3, 0)
+	for _, d := range debts {
+		if start := d.MissedCheckedThrough.AddDate(0, -1, 0); start.Before(since) {
+			since = start
+		}
+	}
+	paymentsByUser := map[int64][]Payment{}
+	recorded := 0
+	for _, d := range debts {
+		dues := passedDueDatesAfter(d.DueDay, d.MissedCheckedThrough, today)
+		if len(dues) == 0 {
+			continue
+		}
+		payments, ok := paymentsByUser[d.UserID]
+		if !ok {
+			payments, err = listPaymentsSince(a.db, d.UserID, since)
+			if err != nil {
+				return recorded, err
+			}
+			paymentsByUser[d.UserID] = payments
+		}
+		for _, due := range dues {
+			missed, paid := cycleMissed(d.Debt, payments, due)
+			if !missed {
+				continue
+			}
+			inserted, err := recordMissedPayment(a.db, d.UserID, MissedPayment{
+				DebtID:          d.ID,
+				DueDate:         due,
+				MinPaymentCents: d.MinPaymentCents,
+				PaidCents:       paid,
+				LateFeeCents:    d.LateFeeCents,
+			})
+			if err != nil {
+				return recorded, err
+			}
+			if inserted {
+				recorded++
+			}
+		}
+		if err := setMissedCheckedThrough(a.db, d.ID, dues[len(dues)-1]); err != nil {
+			return recorded, err
+		}
+	}
+	return recorded, nil
+}
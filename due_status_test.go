@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestComputeDueStatus(t *testing.T) {
+	today := date(t, "2025-03-15")
+	debt := func(dueDay int, created string) Debt {
+		return Debt{ID: 1, DueDay: dueDay, MinPaymentCents: 100_00, BalanceCents: 1000_00, Active: true, CreatedAt: date(t, created)}
+	}
+	paid := func(on string, cents int64) Payment {
+		return Payment{DebtID: 1, PaidOn: date(t, on), AmountCents: cents}
+	}
+	tests := []struct {
+		name     string
+		debt     Debt
+		payments []Payment
+		want     DueStatus
+	}{
+		{"nothing paid", debt(10, "2024-01-01"), nil,
+			DueStatus{DueStatusOverdue, date(t, "2025-03-10"), 100_00}},
+		{"minimum paid", debt(10, "2024-01-01"), []Payment{paid("2025-03-01", 100_00)},
+			DueStatus{}},
+		{"part paid", debt(10, "2024-01-01"), []Payment{paid("2025-03-01", 40_00)},
+			DueStatus{DueStatusOverdue, date(t, "2025-03-10"), 60_00}},
+		{"late payment covers the shortfall", debt(10, "2024-01-01"), []Payment{paid("2025-03-01", 40_00), paid("2025-03-12", 60_00)},
+			DueStatus{}},
+		{"other debt's payment", debt(10, "2024-01-01"), []Payment{{DebtID: 2, PaidOn: date(t, "2025-03-01"), AmountCents: 100_00}},
+			DueStatus{DueStatusOverdue, date(t, "2025-03-10"), 100_00}},
+		{"due soon", debt(20, "2024-01-01"), []Payment{paid("2025-02-15", 100_00)},
+			DueStatus{DueStatusDueSoon, date(t, "2025-03-20"), 100_00}},
+		{"due soon, already paid", debt(20, "2024-01-01"), []Payment{paid("2025-02-15", 100_00), paid("2025-03-14", 100_00)},
+			DueStatus{}},
+		{"added the day before the due date", debt(10, "2025-03-09"), nil,
+			DueStatus{}},
+		{"added on the cycle's first day", debt(10, "2025-02-10"), nil,
+			DueStatus{DueStatusOverdue, date(t, "2025-03-10"), 100_00}},
+		{"inactive", Debt{ID: 1, DueDay: 10, MinPaymentCents: 100_00, BalanceCents: 1000_00}, nil,
+			DueStatus{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeDueStatus(tt.debt, tt.payments, today)
+			if got.Status != tt.want.Status || !got.DueDate.Equal(tt.want.DueDate) || got.OutstandingCents != tt.want.OutstandingCents {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassedDueDatesAfter(t *testing.T) {
+	today := date(t, "2025-03-15")
+	tests := []struct {
+		dueDay  int
+		checked string
+		want    []string
+	}{
+		{10, "2025-01-20", []string{"2025-02-10", "2025-03-10"}},
+		{15, "2025-01-20", []string{"2025-02-15"}},
+		{10, "2025-03-10", nil},
+		{10, "2025-03-15", nil},
+	}
+	for _, tt := range tests {
+		got := passedDueDatesAfter(tt.dueDay, date(t, tt.checked), today)
+		if len(got) != len(tt.want) {
+			t.Errorf("day %d after %s: got %v, want %v", tt.dueDay, tt.checked, got, tt.want)
+			continue
+		}
+		for i, w := range tt.want {
+			if !got[i].Equal(date(t, w)) {
+				t.Errorf("day %d after %s: date %d = %s, want %s", tt.dueDay, tt.checked, i, got[i].Format("2006-01-02"), w)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// EnvelopeMonth is one category in one month, as needed to carry rollover balances forward.
+type EnvelopeMonth struct {
+	Name       string
+	Year       int
+	Month      int
+	LimitCents int64
+	SpentCents int64
+	Rollover   bool
+}
+
+// envelopeKey matches categories across months by name, ignoring case and surrounding space.
+func envelopeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// carriedBalances walks the history (oldest first) and returns, by envelope key, the balance
+// carried into year/month. A rollover category's leftover is carried in + limit - spent; an
+// overspent envelope carries nothing rather than a debt. The chain breaks when a month has no
+// category of that name or the category does not roll over.
+func carriedBalances(history []EnvelopeMonth, year, month int) map[string]int64 {
+	type balance struct {
+		ym    int
+		cents int64
+	}
+	last := map[string]balance{}
+	for _, m := range history {
+		key := envelopeKey(m.Name)
+		ym := m.Year*12 + m.Month
+		prev, ok := last[key]
+		if ok && prev.ym == ym {
+			continue // duplicate name in one month: the first one carries
+		}
+		if !m.Rollover {
+			delete(last, key)
+			continue
+		}
+		var in int64
+		if ok && prev.ym == ym-1 {
+			in = prev.cents
+		}
+		left := in + m.LimitCents - m.SpentCents
+		if left < 0 {
+			left = 0
+		}
+		last[key] = balance{ym: ym, cents: left}
+	}
+	out := map[string]int64{}
+	for key, b := range last {
+		if b.ym == year*12+month-1 && b.cents > 0 {
+			out[key] = b.cents
+		}
+	}
+	return out
+}
+
+// sinkingContribution returns the monthly amount needed from year/month through the due month
+// (inclusive) to grow savedCents to targetCents, and how many months that spreads over. Past the
+// due date the whole shortfall is due now.
+func sinkingContribution(targetCents, savedCents int64, year, month int, due time.Time) (monthlyCents int64, monthsLeft int) {
+	monthsLeft = due.Year()*12 + int(due.Month()) - (year*12 + month) + 1
+	if monthsLeft < 1 {
+		monthsLeft = 1
+	}
+	short := targetCents - savedCents
+	if short <= 0 {
+		return 0, monthsLeft
+	}
+	return (short + int64(monthsLeft) - 1) / int64(monthsLeft), monthsLeft
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCarriedBalances(t *testing.T) {
+	env := func(name string, year, month int, limit, spent int64, rollover bool) EnvelopeMonth {
+		return EnvelopeMonth{Name: name, Year: year, Month: month, LimitCents: limit, SpentCents: spent, Rollover: rollover}
+	}
+	tests := []struct {
+		name    string
+		history []EnvelopeMonth
+		month   int // carried into this month of 2025
+		want    map[string]int64
+	}{
+		{"leftovers add up", []EnvelopeMonth{
+			env("Groceries", 2025, 1, 500_00, 450_00, true),
+			env("Groceries", 2025, 2, 500_00, 400_00, true),
+		}, 3, map[string]int64{"groceries": 150_00}},
+		{"across a year end, ignoring case", []EnvelopeMonth{
+			env("Gifts", 2024, 11, 100_00, 0, true),
+			env(" gifts ", 2024, 12, 100_00, 50_00, true),
+		}, 1, map[string]int64{"gifts": 150_00}},
+		{"overspending carries nothing", []EnvelopeMonth{
+			env("Dining", 2025, 1, 200_00, 100_00, true),
+			env("Dining", 2025, 2, 200_00, 450_00, true),
+		}, 3, map[string]int64{}},
+		{"overspending resets the next month", []EnvelopeMonth{
+			env("Dining", 2024, 12, 200_00, 450_00, true),
+			env("Dining", 2025, 1, 200_00, 150_00, true),
+		}, 2, map[string]int64{"dining": 50_00}},
+		{"a month without rollover breaks the chain", []EnvelopeMonth{
+			env("Car", 2024, 11, 100_00, 0, true),
+			env("Car", 2024, 12, 100_00, 0, false),
+			env("Car", 2025, 1, 100_00, 0, true),
+		}, 2, map[string]int64{"car": 100_00}},
+		{"a missing month breaks the chain", []EnvelopeMonth{
+			env("Car", 2024, 11, 100_00, 0, true),
+			env("Car", 2025, 1, 100_00, 0, true),
+		}, 2, map[string]int64{"car": 100_00}},
+		{"nothing carries from an older month", []EnvelopeMonth{
+			env("Car", 2024, 12, 100_00, 0, true),
+		}, 2, map[string]int64{}},
+		{"the first duplicate in a month carries", []EnvelopeMonth{
+			env("Pets", 2025, 1, 80_00, 30_00, true),
+			env("PETS", 2025, 1, 500_00, 0, true),
+		}, 2, map[string]int64{"pets": 50_00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := carriedBalances(tt.history, 2025, tt.month)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for key, cents := range tt.want {
+				if got[key] != cents {
+					t.Errorf("%s: got %d, want %d", key, got[key], cents)
+				}
+			}
+		})
+	}
+}
+
+func TestSinkingContribution(t *testing.T) {
+	due := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		target, save int64
+		year, month  int
+		wantMonthly  int64
+		wantMonths   int
+	}{
+		{"spread to the due month", 1200_00, 0, 2025, 1, 100_00, 12},
+		{"rounds up", 1000_00, 0, 2025, 10, 333_34, 3},
+		{"due this month", 500_00, 200_00, 2025, 12, 300_00, 1},
+		{"past due", 500_00, 200_00, 2026, 2, 300_00, 1},
+		{"already saved", 500_00, 600_00, 2025, 6, 0, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monthly, months := sinkingContribution(tt.target, tt.save, tt.year, tt.month, due)
+			if monthly != tt.wantMonthly || months != tt.wantMonths {
+				t.Errorf("got %d over %d months, want %d over %d", monthly, months, tt.wantMonthly, tt.wantMonths)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-module main
+module debt-manager
 
 go 1.24.0
 
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -78,6 +80,17 @@ func (a *App) handleBudgetView(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", 500)
 		return
 	}
+	// Income that follows the salary is kept up to date when the salary is saved and by the
+	// scheduler; past and closed months keep the income they had
+	var salaryIncome int64
+	salary, err := getSalaryProfile(a.db, userID)
+	hasSalary := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getSalaryProfile: %v", err)
+	}
+	if hasSalary {
+		salaryIncome = salaryNetPay(salary, budget.Year).MonthlyCents
+	}
 	categories, err := listCategoriesForBudget(a.db, budget.ID, userID)
 	if err != nil {
 		log.Printf("Error listCategoriesForBudget: %v", err)
@@ -89,14 +102,35 @@ func (a *App) handleBudgetView(w http.ResponseWriter, r *http.Request) {
 		BudgetCategory
 		SpentCents       int64
 		SuggestedPayoffCents int64 // only for is_debt_payoff: plan suggestion (extra from plan)
+		CarriedInCents     int64 // rollover from last month's same-named category
+		AvailableCents     int64 // limit + carried in
+		SinkingNeededCents int64 // monthly contribution to reach the sinking fund target
+		SinkingMonthsLeft  int
+		DebtPaymentsCents  int64 // debt payments made outside the budget, counted in the first debt payoff category
 	}
 	catWithSpent := make([]CatWithSpent, 0, len(categories))
+	history, err := listEnvelopeHistory(a.db, userID, budget.Year, budget.Month)
+	if err != nil {
+		log.Printf("Error listEnvelopeHistory: %v", err)
+	}
+	carried := carriedBalances(history, budget.Year, budget.Month)
 	minSum, _ := SumOfMinPaymentsForUser(a.db, userID)
 	debts, _ := listDebts(a.db, userID)
 	var suggestedExtra int64
 	for _, c := range categories {
-		spent, _ := totalSpentForCategory(a.db, c.ID)
+		expenseTotal, _ := totalSpentForCategory(a.db, c.ID)
+		spent, err := categorySpent(a.db, userID, c.ID)
+		if err != nil {
+			log.Printf("Error categorySpent: %v", err)
+			spent = expenseTotal
+		}
 		entry := CatWithSpent{BudgetCategory: c, SpentCents: spent, SuggestedPayoffCents: 0}
+		entry.CarriedInCents = carried[envelopeKey(c.Name)]
+		entry.DebtPaymentsCents = spent - expenseTotal
+		entry.AvailableCents = c.LimitCents + entry.CarriedInCents
+		if c.SinkingTargetCents > 0 && c.SinkingDueOn.Valid {
+			entry.SinkingNeededCents, entry.SinkingMonthsLeft = sinkingContribution(c.SinkingTargetCents, entry.CarriedInCents, budget.Year, budget.Month, c.SinkingDueOn.Time)
+		}
 		if c.IsDebtPayoff {
 			// Suggested extra = (income - sum of other category limits) - min payments, or use plan's "monthly budget" concept
 			// We use: total income - sum of all category limits = "leftover"; plan suggests "monthly budget" - minSum = extra.
@@ -128,10 +162,66 @@ func (a *App) handleBudgetView(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	// Earlier budgets to start this month from
+	var priorBudgets []Budget
+	if all, err := listBudgets(a.db, userID, 0); err == nil {
+		for _, b := range all {
+			if b.Year < budget.Year || (b.Year == budget.Year && b.Month < budget.Month) {
+				priorBudgets = append(priorBudgets, b)
+			}
+		}
+	}
+	templates, err := listBudgetTemplates(a.db, userID)
+	if err != nil {
+		log.Printf("Error listBudgetTemplates: %v", err)
+	}
+	// Expected pay from income sources and what has actually arrived this month
+	var expectedIncome, receivedIncome int64
+	monthStart := time.Date(budget.Year, time.Month(budget.Month), 1, 0, 0, 0, 0, time.UTC)
+	sources, err := listIncomeSources(a.db, userID)
+	if err != nil {
+		log.Printf("Error listIncomeSources: %v", err)
+	}
+	_, expectedIncome = projectIncome(sources, budget.Year, budget.Month)
+	receipts, err := listIncomeReceipts(a.db, userID, monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		log.Printf("Error listIncomeReceipts: %v", err)
+	}
+	for _, rc := range receipts {
+		receivedIncome += rc.AmountCents
+	}
+	splits, err := listExpenseSplits(a.db, userID, budget.ID)
+	if err != nil {
+		log.Printf("Error listExpenseSplits: %v", err)
+	}
+	var alerts []BudgetAlert
+	if all, err := listBudgetAlerts(a.db, userID); err == nil {
+		for _, al := range all {
+			if al.Year == budget.Year && al.Month == budget.Month {
+				alerts = append(alerts, al)
+			}
+		}
+	} else {
+		log.Printf("Error listBudgetAlerts: %v", err)
+	}
 	flash, flashType := a.getFlash(r)
 	a.render(w, http.StatusOK, "budget_view.html", map[string]any{
 		"Budget":          budget,
+		"PriorBudgets":    priorBudgets,
+		"Templates":       templates,
+		"HasIncomeSources": len(sources) > 0,
+		"HasSalary":       hasSalary,
+		"Salary":          salary,
+		"SalaryIncome":    salaryIncome,
+		"FollowsSalary":   followsSalary(budget, now),
+		"LeftToAssign":    leftToAssign(budget, categories),
+		"ExpectedIncome":  expectedIncome,
+		"ReceivedIncome":  receivedIncome,
+		"BudgetAlerts":    alerts,
+		"Splits":          splits,
+		"TransactionsURL": registerURL(RegisterFilter{From: monthStart, To: monthStart.AddDate(0, 1, -1)}, "", 1),
 		"Categories":      catWithSpent,
+		"QuickSpentOn":    defaultSpentOn(budget.Year, budget.Month, time.Now()).Format("2006-01-02"),
 		"Debts":           debts,
 		"MinPaymentsSum":  minSum,
 		"SuggestedExtra":  suggestedExtra,
@@ -142,6 +232,179 @@ func (a *App) handleBudgetView(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleBudgetCopy starts a budget from an earlier one ("Start this month from…").
+func (a *App) handleBudgetCopy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	toID, _ := strconv.ParseInt(r.FormValue("budget_id"), 10, 64)
+	fromID, _ := strconv.ParseInt(r.FormValue("from_budget_id"), 10, 64)
+	to, err := getBudget(a.db, userID, toID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", to.Year, to.Month)
+	from, err := getBudget(a.db, userID, fromID)
+	if err != nil || from.ID == to.ID {
+		a.setFlash(w, "Choose a budget to copy from.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	added, err := copyBudget(a.db, userID, from.ID, to.ID)
+	if err != nil {
+		log.Printf("Error copying budget: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error copying budget."), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, fmt.Sprintf("Copied income and %d categories from %s %d. Existing categories were kept.", added, time.Month(from.Month), from.Year), false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// leftToAssign is income not yet given to a category; negative when limits exceed income.
+func leftToAssign(b Budget, categories []BudgetCategory) int64 {
+	left := b.IncomeCents
+	for _, c := range categories {
+		left -= c.LimitCents
+	}
+	return left
+}
+
+// budgetFromForm loads the budget named by the budget_id form value, answering 404 if missing.
+func (a *App) budgetFromForm(w http.ResponseWriter, r *http.Request) (Budget, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return Budget{}, false
+	}
+	id, _ := strconv.ParseInt(r.FormValue("budget_id"), 10, 64)
+	b, err := getBudget(a.db, getUserID(r), id)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return Budget{}, false
+	}
+	return b, true
+}
+
+func (a *App) handleBudgetZeroBased(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	budget, ok := a.budgetFromForm(w, r)
+	if !ok {
+		return
+	}
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month)
+	enabled := r.FormValue("zero_based") == "1"
+	if err := setBudgetZeroBased(a.db, getUserID(r), budget.ID, enabled); err != nil {
+		log.Printf("Error setBudgetZeroBased: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error saving budget."), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if enabled {
+		a.setFlash(w, "Zero-based budgeting is on: give every dollar of income a category before closing the month.", false)
+	} else {
+		a.setFlash(w, "Zero-based budgeting is off for this month.", false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// handleBudgetAssignRemainder sends whatever income is left to assign to the debt payoff category.
+func (a *App) handleBudgetAssignRemainder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	budget, ok := a.budgetFromForm(w, r)
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month)
+	categories, err := listCategoriesForBudget(a.db, budget.ID, userID)
+	if err != nil {
+		log.Printf("Error listCategoriesForBudget: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	left := leftToAssign(budget, categories)
+	if left <= 0 {
+		a.setFlash(w, "There is nothing left to assign.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	name, err := assignRemainderToDebt(a.db, userID, budget.ID, left)
+	if err != nil {
+		log.Printf("Error assignRemainderToDebt: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error assigning the remainder."), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, fmt.Sprintf("Sent %s to %s. Every dollar now has a job.", money(left), name), false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// handleBudgetClose closes or reopens a month. A zero-based month only closes with nothing left to assign.
+func (a *App) handleBudgetClose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	budget, ok := a.budgetFromForm(w, r)
+	if !ok {
+		return
+	}
+	userID := getUserID(r)
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month)
+	closing := r.FormValue("reopen") != "1"
+	if closing && budget.ZeroBased {
+		categories, err := listCategoriesForBudget(a.db, budget.ID, userID)
+		if err != nil {
+			log.Printf("Error listCategoriesForBudget: %v", err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		if left := leftToAssign(budget, categories); left > 0 {
+			a.setFlash(w, fmt.Sprintf("Assign the remaining %s before closing the month.", money(left)), true)
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		} else if left < 0 {
+			a.setFlash(w, fmt.Sprintf("Category limits are %s more than income. Lower them before closing the month.", money(-left)), true)
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+	if err := setBudgetClosed(a.db, userID, budget.ID, closing); err != nil {
+		log.Printf("Error setBudgetClosed: %v", err)
+		a.setFlash(w, "Error saving budget.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if closing {
+		a.setFlash(w, fmt.Sprintf("%s %d is closed.", time.Month(budget.Month), budget.Year), false)
+	} else {
+		a.setFlash(w, fmt.Sprintf("%s %d is open again.", time.Month(budget.Month), budget.Year), false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// budgetChangeFailed returns the flash for a budget change that failed with err: closed months
+// get a reopen hint, anything else the generic message.
+func budgetChangeFailed(err error, generic string) string {
+	if errors.Is(err, errBudgetClosed) {
+		return "This month is closed. Reopen it to make changes."
+	}
+	return generic
+}
+
 func (a *App) handleBudgetUpdate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", 405)
@@ -182,7 +445,7 @@ func (a *App) handleBudgetUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := updateBudget(a.db, userID, budget.ID, incomeCents); err != nil {
 		log.Printf("Error updateBudget: %v", err)
-		a.setFlash(w, "Error saving budget.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error saving budget."), true)
 		http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", year, month), http.StatusSeeOther)
 		return
 	}
@@ -190,6 +453,47 @@ func (a *App) handleBudgetUpdate(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", year, month), http.StatusSeeOther)
 }
 
+// handleBudgetUseSalary makes a budget's income the after-tax monthly pay on the saved salary,
+// following it from then on.
+func (a *App) handleBudgetUseSalary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	budgetID, _ := strconv.ParseInt(r.FormValue("budget_id"), 10, 64)
+	budget, err := getBudget(a.db, userID, budgetID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month)
+	salary, err := getSalaryProfile(a.db, userID)
+	if err == sql.ErrNoRows {
+		a.setFlash(w, "Save your salary in Settings first.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getSalaryProfile: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	income := salaryNetPay(salary, budget.Year).MonthlyCents
+	if err := setBudgetSalaryIncome(a.db, userID, budget.ID, income); err != nil {
+		log.Printf("Error setBudgetSalaryIncome: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error saving budget."), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, fmt.Sprintf("Income set to %s, your salary after tax. It will follow your salary until you enter income by hand.", money(income)), false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
 func (a *App) handleBudgetCategoryAdd(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", 405)
@@ -246,10 +550,22 @@ func (a *App) handleBudgetCategoryCreate(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Budget not found", 404)
 		return
 	}
-	_, err = createBudgetCategory(a.db, userID, budget.ID, name, limitCents, isDebtPayoff, sortOrder)
+	settings, err := parseEnvelopeForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the category: %v.", err), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/category/add?budget_id=%d", budgetID), http.StatusSeeOther)
+		return
+	}
+	thresholds, err := parseAlertThresholds(r.FormValue("alert_thresholds"))
 	if err != nil {
+		a.setFlash(w, alertThresholdsHelp, true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/category/add?budget_id=%d", budgetID), http.StatusSeeOther)
+		return
+	}
+	settings.AlertThresholds = formatAlertThresholds(thresholds)
+	if _, err := createBudgetCategory(a.db, userID, budget.ID, name, limitCents, isDebtPayoff, sortOrder, settings); err != nil {
 		log.Printf("Error createBudgetCategory: %v", err)
-		a.setFlash(w, "Error creating category.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error creating category."), true)
 		http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
 		return
 	}
@@ -312,9 +628,22 @@ func (a *App) handleBudgetCategoryUpdate(w http.ResponseWriter, r *http.Request)
 		http.Redirect(w, r, fmt.Sprintf("/budget/category/edit?id=%d", id), http.StatusSeeOther)
 		return
 	}
-	if err := updateBudgetCategory(a.db, userID, id, name, limitCents, isDebtPayoff, sortOrder); err != nil {
+	settings, err := parseEnvelopeForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the category: %v.", err), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/category/edit?id=%d", id), http.StatusSeeOther)
+		return
+	}
+	thresholds, err := parseAlertThresholds(r.FormValue("alert_thresholds"))
+	if err != nil {
+		a.setFlash(w, alertThresholdsHelp, true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/category/edit?id=%d", id), http.StatusSeeOther)
+		return
+	}
+	settings.AlertThresholds = formatAlertThresholds(thresholds)
+	if err := updateBudgetCategory(a.db, userID, id, name, limitCents, isDebtPayoff, sortOrder, settings); err != nil {
 		log.Printf("Error updateBudgetCategory: %v", err)
-		a.setFlash(w, "Error updating category.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error updating category."), true)
 		http.Redirect(w, r, fmt.Sprintf("/budget/category/edit?id=%d", id), http.StatusSeeOther)
 		return
 	}
@@ -323,6 +652,26 @@ func (a *App) handleBudgetCategoryUpdate(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
 }
 
+// alertThresholdsHelp is shown when the alert thresholds on a category form can't be read.
+var alertThresholdsHelp = fmt.Sprintf("Alert thresholds must be percents between 1 and %d, such as 80, 100.", maxAlertThreshold)
+
+// parseEnvelopeForm reads the rollover and sinking-fund fields of the category forms.
+func parseEnvelopeForm(r *http.Request) (CategorySettings, error) {
+	s := CategorySettings{Rollover: r.FormValue("rollover") == "1"}
+	target, err := parseOptionalCents(r.FormValue("sinking_target_dollars"))
+	if err != nil {
+		return CategorySettings{}, errors.New("invalid sinking fund target")
+	}
+	if !target.Valid || target.Int64 == 0 {
+		return s, nil
+	}
+	due, err := time.Parse("2006-01-02", r.FormValue("sinking_due_on"))
+	if err != nil {
+		return CategorySettings{}, errors.New("a sinking fund needs a due date")
+	}
+	return CategorySettings{Rollover: true, SinkingTargetCents: target.Int64, SinkingDueOn: sql.NullTime{Time: due, Valid: true}}, nil
+}
+
 func (a *App) handleBudgetCategoryDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", 405)
@@ -339,9 +688,11 @@ func (a *App) handleBudgetCategoryDelete(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Category not found", 404)
 		return
 	}
-	if err := deleteBudgetCategory(a.db, userID, id); err != nil {
+	if err := deleteBudgetCategory(a.db, userID, id); errors.Is(err, errCategoryHasSplits) {
+		a.setFlash(w, fmt.Sprintf("%s is part of a split expense. Edit the split to move its amount to other categories, then delete the category.", cat.Name), true)
+	} else if err != nil {
 		log.Printf("Error deleteBudgetCategory: %v", err)
-		a.setFlash(w, "Error deleting category.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error deleting category."), true)
 	} else {
 		a.setFlash(w, "Category deleted.", false)
 	}
@@ -373,9 +724,24 @@ func (a *App) handleBudgetCategoryExpenses(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Internal server error", 500)
 		return
 	}
-	spent, _ := totalSpentForCategory(a.db, catID)
+	expenseTotal, _ := totalSpentForCategory(a.db, catID)
+	spent, err := categorySpent(a.db, userID, catID)
+	if err != nil {
+		log.Printf("Error categorySpent: %v", err)
+		spent = expenseTotal
+	}
+	// Spending beyond the expenses is debt payments made outside the budget; list them
+	var debtPayments []PaymentWithDebt
+	if spent > expenseTotal {
+		from := time.Date(budget.Year, time.Month(budget.Month), 1, 0, 0, 0, 0, time.UTC)
+		debtPayments, err = listUnlinkedDebtPayments(a.db, userID, from, from.AddDate(0, 1, 0))
+		if err != nil {
+			log.Printf("Error listUnlinkedDebtPayments: %v", err)
+		}
+	}
 	flash, flashType := a.getFlash(r)
 	a.render(w, http.StatusOK, "budget_category_expenses.html", map[string]any{
+		"DebtPayments":    debtPayments,
 		"Category":        cat,
 		"Budget":          budget,
 		"Expenses":        expenses,
@@ -405,15 +771,26 @@ func (a *App) handleBudgetExpenseAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	budget, _ := getBudget(a.db, userID, cat.BudgetID)
-	spent, _ := totalSpentForCategory(a.db, catID)
+	spent, _ := categorySpent(a.db, userID, catID)
 	remainingCents := cat.LimitCents - spent
 	if remainingCents < 0 {
 		remainingCents = 0
 	}
+	var debts []Debt
+	if cat.IsDebtPayoff {
+		debts, _ = listDebts(a.db, userID)
+	}
+	rules, err := listCategoryRules(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing category rules: %v", err)
+	}
 	a.render(w, http.StatusOK, "budget_expense_add.html", map[string]any{
 		"Category":        cat,
 		"Budget":          budget,
+		"Debts":           debts,
 		"RemainingCents":  remainingCents,
+		"SpentOn":         defaultSpentOn(budget.Year, budget.Month, time.Now()).Format("2006-01-02"),
+		"HasRules":        len(rules) > 0,
 		"CSRFToken":       a.getCSRFToken(r),
 		"ContentTemplate": "budget_expense_add_content",
 	})
@@ -430,16 +807,8 @@ func (a *App) handleBudgetExpenseCreate(w http.ResponseWriter, r *http.Request)
 	}
 	userID := getUserID(r)
 	catID, _ := strconv.ParseInt(r.FormValue("category_id"), 10, 64)
-	spentOnStr := r.FormValue("spent_on")
 	amountDollars := r.FormValue("amount_dollars")
 	note := strings.TrimSpace(r.FormValue("note"))
-	var spentOn time.Time
-	if spentOnStr != "" {
-		spentOn, _ = time.Parse("2006-01-02", spentOnStr)
-	}
-	if spentOn.IsZero() {
-		spentOn = time.Now()
-	}
 	var amountCents int64
 	if d, err := strconv.ParseFloat(amountDollars, 64); err == nil && d > 0 {
 		amountCents = int64(d * 100)
@@ -454,15 +823,68 @@ func (a *App) handleBudgetExpenseCreate(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Category not found", 404)
 		return
 	}
-	if err := addBudgetExpense(a.db, userID, catID, spentOn, amountCents, note); err != nil {
+	budget, _ := getBudget(a.db, userID, cat.BudgetID)
+	spentOn, err := time.Parse("2006-01-02", r.FormValue("spent_on"))
+	if err != nil {
+		spentOn = defaultSpentOn(budget.Year, budget.Month, time.Now())
+	}
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month)
+	debtID, _ := strconv.ParseInt(r.FormValue("debt_id"), 10, 64)
+
+	// Rules only choose the category when the user asked them to; the category the expense was
+	// added from is the fallback, never something a rule overrides on its own.
+	ruleMissed := false
+	if debtID == 0 && r.FormValue("use_rules") == "1" {
+		rules, err := listCategoryRules(a.db, userID)
+		if err != nil {
+			log.Printf("Error listing category rules: %v", err)
+		}
+		cr, ok := matchCategoryRule(rules, note, amountCents)
+		ruleMissed = !ok
+		if ok && envelopeKey(cr.CategoryName) != envelopeKey(cat.Name) {
+			ruleCatID, err := addExpenseToNamedCategory(a.db, userID, budget.Year, budget.Month, cr.CategoryName, spentOn, amountCents, note)
+			if err != nil {
+				log.Printf("Error adding expense: %v", err)
+				a.setFlash(w, budgetChangeFailed(err, "Error adding expense."), true)
+				http.Redirect(w, r, fmt.Sprintf("/budget/expense/add?category_id=%d", catID), http.StatusSeeOther)
+				return
+			}
+			a.checkBudgetAlerts(userID, ruleCatID)
+			a.setFlash(w, fmt.Sprintf("Expense recorded in %s (rule \"%s\").", cr.CategoryName, cr.Pattern), false)
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+
+	if err := addBudgetExpense(a.db, userID, catID, spentOn, amountCents, note, debtID); err != nil {
 		log.Printf("Error addBudgetExpense: %v", err)
-		a.setFlash(w, "Error adding expense.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error adding expense."), true)
 		http.Redirect(w, r, fmt.Sprintf("/budget/expense/add?category_id=%d", catID), http.StatusSeeOther)
 		return
 	}
-	budget, _ := getBudget(a.db, userID, cat.BudgetID)
-	a.setFlash(w, "Expense recorded. Category spending has been updated.", false)
-	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+	a.checkBudgetAlerts(userID, catID)
+	if debtID > 0 {
+		a.setFlash(w, "Expense recorded and the payment posted to your debt.", false)
+	} else if ruleMissed {
+		a.setFlash(w, fmt.Sprintf("No rule matches that expense, so it was recorded in %s.", cat.Name), false)
+	} else {
+		a.setFlash(w, "Expense recorded. Category spending has been updated.", false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// defaultSpentOn is the date for an expense entered without one: today when it falls in the
+// budget's month, otherwise the nearest day of that month.
+func defaultSpentOn(year, month int, now time.Time) time.Time {
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.Before(first) {
+		return first
+	}
+	if last := first.AddDate(0, 1, -1); today.After(last) {
+		return last
+	}
+	return today
 }
 
 func (a *App) handleBudgetExpenseEdit(w http.ResponseWriter, r *http.Request) {
@@ -482,11 +904,20 @@ func (a *App) handleBudgetExpenseEdit(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Expense not found", 404)
 		return
 	}
+	if exp.SplitID.Valid {
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/edit?id=%d", exp.SplitID.Int64), http.StatusSeeOther)
+		return
+	}
 	cat, _ := getBudgetCategory(a.db, userID, exp.BudgetCategoryID)
 	budget, _ := getBudget(a.db, userID, cat.BudgetID)
+	var debts []Debt
+	if cat.IsDebtPayoff {
+		debts, _ = listDebts(a.db, userID)
+	}
 	a.render(w, http.StatusOK, "budget_expense_edit.html", map[string]any{
 		"Expense":         exp,
 		"Category":        cat,
+		"Debts":           debts,
 		"Budget":          budget,
 		"CSRFToken":       a.getCSRFToken(r),
 		"ContentTemplate": "budget_expense_edit_content",
@@ -520,6 +951,11 @@ func (a *App) handleBudgetExpenseUpdate(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Expense not found", 404)
 		return
 	}
+	if exp.SplitID.Valid {
+		a.setFlash(w, "This expense is part of a split. Change it here so the allocations still add up.", true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/edit?id=%d", exp.SplitID.Int64), http.StatusSeeOther)
+		return
+	}
 	if spentOn.IsZero() {
 		spentOn = exp.SpentOn
 	}
@@ -528,12 +964,18 @@ func (a *App) handleBudgetExpenseUpdate(w http.ResponseWriter, r *http.Request)
 		http.Redirect(w, r, fmt.Sprintf("/budget/expense/edit?id=%d", id), http.StatusSeeOther)
 		return
 	}
-	if err := updateBudgetExpense(a.db, userID, id, spentOn, amountCents, note); err != nil {
+	debtID, _ := strconv.ParseInt(r.FormValue("debt_id"), 10, 64)
+	if err := updateBudgetExpense(a.db, userID, id, spentOn, amountCents, note, debtID); errors.Is(err, errOutsideBudgetMonth) {
+		a.setFlash(w, "This expense is a debt payment, so its date must stay in the budget's month.", true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/expense/edit?id=%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
 		log.Printf("Error updateBudgetExpense: %v", err)
-		a.setFlash(w, "Error updating expense.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error updating expense."), true)
 		http.Redirect(w, r, fmt.Sprintf("/budget/expense/edit?id=%d", id), http.StatusSeeOther)
 		return
 	}
+	a.checkBudgetAlerts(userID, exp.BudgetCategoryID)
 	cat, _ := getBudgetCategory(a.db, userID, exp.BudgetCategoryID)
 	budget, _ := getBudget(a.db, userID, cat.BudgetID)
 	a.setFlash(w, "Expense updated. Your changes are saved.", false)
@@ -556,9 +998,14 @@ func (a *App) handleBudgetExpenseDelete(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Expense not found", 404)
 		return
 	}
+	if exp.SplitID.Valid {
+		a.setFlash(w, "This expense is part of a split. Change the allocations or delete the whole split here.", true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/edit?id=%d", exp.SplitID.Int64), http.StatusSeeOther)
+		return
+	}
 	if err := deleteBudgetExpense(a.db, userID, id); err != nil {
 		log.Printf("Error deleteBudgetExpense: %v", err)
-		a.setFlash(w, "Error deleting expense.", true)
+		a.setFlash(w, budgetChangeFailed(err, "Error deleting expense."), true)
 	} else {
 		a.setFlash(w, "Expense deleted.", false)
 	}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// --- Over-budget alerts ---
+
+// handleBudgetAlertDismiss hides one alert (id) or all of them (no id). It returns to the
+// budget for year/month when given, otherwise to the dashboard.
+func (a *App) handleBudgetAlertDismiss(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := dismissBudgetAlert(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error dismissBudgetAlert: %v", err)
+		a.setFlash(w, "Error dismissing alert.", true)
+	}
+	back := "/"
+	year, _ := strconv.Atoi(r.FormValue("year"))
+	month, _ := strconv.Atoi(r.FormValue("month"))
+	if year >= 2000 && year <= 2100 && month >= 1 && month <= 12 {
+		back = fmt.Sprintf("/budget/view?year=%d&month=%d", year, month)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --- Budget template handlers (named, reusable category lists) ---
+
+func (a *App) handleBudgetTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	templates, err := listBudgetTemplates(a.db, userID)
+	if err != nil {
+		log.Printf("Error listBudgetTemplates: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	budgets, err := listBudgets(a.db, userID, 0)
+	if err != nil {
+		log.Printf("Error listing budgets: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "budget_templates.html", map[string]any{
+		"Templates":       templates,
+		"Budgets":         budgets,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_templates_content",
+	})
+}
+
+func (a *App) handleBudgetTemplateCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	name := strings.TrimSpace(r.FormValue("name"))
+	fromBudgetID, _ := strconv.ParseInt(r.FormValue("from_budget_id"), 10, 64)
+	if name == "" {
+		a.setFlash(w, "Template name is required.", true)
+		http.Redirect(w, r, "/budget/templates", http.StatusSeeOther)
+		return
+	}
+	id, err := createBudgetTemplate(a.db, userID, name, fromBudgetID)
+	if err != nil {
+		log.Printf("Error createBudgetTemplate: %v", err)
+		a.setFlash(w, "Error creating template.", true)
+		http.Redirect(w, r, "/budget/templates", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Template created.", false)
+	http.Redirect(w, r, fmt.Sprintf("/budget/templates/view?id=%d", id), http.StatusSeeOther)
+}
+
+func (a *App) handleBudgetTemplateView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	tpl, err := getBudgetTemplate(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Template not found", 404)
+		return
+	}
+	categories, err := listTemplateCategories(a.db, userID, id)
+	if err != nil {
+		log.Printf("Error listTemplateCategories: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	var total int64
+	nextSort := 0
+	for _, c := range categories {
+		total += c.LimitCents
+		if c.SortOrder >= nextSort {
+			nextSort = c.SortOrder + 1
+		}
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "budget_template_view.html", map[string]any{
+		"Template":        tpl,
+		"Categories":      categories,
+		"TotalCents":      total,
+		"NextSortOrder":   nextSort,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_template_view_content",
+	})
+}
+
+func (a *App) handleBudgetTemplateRename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	name := strings.TrimSpace(r.FormValue("name"))
+	back := fmt.Sprintf("/budget/templates/view?id=%d", id)
+	if name == "" {
+		a.setFlash(w, "Template name is required.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if err := renameBudgetTemplate(a.db, userID, id, name); err != nil {
+		log.Printf("Error renameBudgetTemplate: %v", err)
+		a.setFlash(w, "Error renaming template.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Template renamed.", false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (a *App) handleBudgetTemplateDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := deleteBudgetTemplate(a.db, userID, id); err != nil {
+		log.Printf("Error deleteBudgetTemplate: %v", err)
+		a.setFlash(w, "Error deleting template.", true)
+	} else {
+		a.setFlash(w, "Template deleted. Budgets it was applied to are unchanged.", false)
+	}
+	http.Redirect(w, r, "/budget/templates", http.StatusSeeOther)
+}
+
+// parseTemplateCategoryForm reads the shared fields of the add and update category forms.
+func parseTemplateCategoryForm(r *http.Request) (BudgetTemplateCategory, error) {
+	c := BudgetTemplateCategory{
+		Name:         strings.TrimSpace(r.FormValue("name")),
+		IsDebtPayoff: r.FormValue("is_debt_payoff") == "1",
+	}
+	c.SortOrder, _ = strconv.Atoi(r.FormValue("sort_order"))
+	if c.Name == "" {
+		return c, errors.New("a name is required")
+	}
+	if s := r.FormValue("limit_dollars"); s != "" {
+		d, err := strconv.ParseFloat(s, 64)
+		if err != nil || d < 0 {
+			return c, errors.New("the limit must be zero or more")
+		}
+		c.LimitCents = int64(d * 100)
+	}
+	return c, nil
+}
+
+func (a *App) handleBudgetTemplateCategoryAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	templateID, _ := strconv.ParseInt(r.FormValue("template_id"), 10, 64)
+	back := fmt.Sprintf("/budget/templates/view?id=%d", templateID)
+	c, err := parseTemplateCategoryForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the category: %v.", err), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	c.TemplateID = templateID
+	if err := addTemplateCategory(a.db, userID, c); err != nil {
+		log.Printf("Error addTemplateCategory: %v", err)
+		a.setFlash(w, "Error adding category.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Category added to template.", false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (a *App) handleBudgetTemplateCategoryUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	templateID, _ := strconv.ParseInt(r.FormValue("template_id"), 10, 64)
+	back := fmt.Sprintf("/budget/templates/view?id=%d", templateID)
+	c, err := parseTemplateCategoryForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the category: %v.", err), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	c.ID, _ = strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := updateTemplateCategory(a.db, userID, c); err != nil {
+		log.Printf("Error updateTemplateCategory: %v", err)
+		a.setFlash(w, "Error updating category.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Category updated.", false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (a *App) handleBudgetTemplateCategoryDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	templateID, _ := strconv.ParseInt(r.FormValue("template_id"), 10, 64)
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := deleteTemplateCategory(a.db, userID, id); err != nil {
+		log.Printf("Error deleteTemplateCategory: %v", err)
+		a.setFlash(w, "Error deleting category.", true)
+	} else {
+		a.setFlash(w, "Category removed from template.", false)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/budget/templates/view?id=%d", templateID), http.StatusSeeOther)
+}
+
+// handleBudgetTemplateApply merges a template into a budget and shows what changed.
+func (a *App) handleBudgetTemplateApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	budgetID, _ := strconv.ParseInt(r.FormValue("budget_id"), 10, 64)
+	templateID, _ := strconv.ParseInt(r.FormValue("template_id"), 10, 64)
+	budget, err := getBudget(a.db, userID, budgetID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	tpl, err := getBudgetTemplate(a.db, userID, templateID)
+	if err != nil {
+		a.setFlash(w, "Choose a template to apply.", true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+		return
+	}
+	changes, kept, err := applyBudgetTemplate(a.db, userID, tpl.ID, budget.ID)
+	if err != nil {
+		log.Printf("Error applyBudgetTemplate: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error applying template."), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+		return
+	}
+	var added, updated int
+	for _, c := range changes {
+		switch c.Action {
+		case "added":
+			added++
+		case "updated":
+			updated++
+		}
+	}
+	a.render(w, http.StatusOK, "budget_template_applied.html", map[string]any{
+		"Budget":          budget,
+		"Template":        tpl,
+		"Changes":         changes,
+		"Kept":            kept,
+		"AddedCount":      added,
+		"UpdatedCount":    updated,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_template_applied_content",
+	})
+}
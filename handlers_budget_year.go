@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Annual budget view and plan ---
+
+func (a *App) handleBudgetYear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	now := time.Now()
+	year := now.Year()
+	if y, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil && y >= 2000 && y <= 2100 {
+		year = y
+	}
+	report, err := a.loadBudgetReport(userID, year*12+1, year*12+12)
+	if err != nil {
+		log.Printf("Error loading budget report: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	plan, err := listAnnualPlanItems(a.db, userID, year)
+	if err != nil {
+		log.Printf("Error listAnnualPlanItems: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	names, err := listBudgetCategoryNames(a.db, userID)
+	if err != nil {
+		log.Printf("Error listBudgetCategoryNames: %v", err)
+	}
+	var totals ReportMonth
+	for _, m := range report.Months {
+		totals.IncomeCents += m.IncomeCents
+		totals.SpentCents += m.SpentCents
+		totals.DebtPaymentsCents += m.DebtPaymentsCents
+		totals.LeftoverCents += m.LeftoverCents
+	}
+	var limitCents int64
+	for _, c := range report.Categories {
+		limitCents += c.TotalLimitCents
+	}
+	fromMonth := 1
+	if year == now.Year() {
+		fromMonth = int(now.Month())
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "budget_year.html", map[string]any{
+		"Year":            year,
+		"PrevYear":        year - 1,
+		"NextYear":        year + 1,
+		"Report":          report,
+		"Totals":          totals,
+		"LimitCents":      limitCents,
+		"Categories":      annualCategories(report, plan),
+		"Plan":            plan,
+		"CategoryNames":   names,
+		"Months":          []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		"FromMonth":       fromMonth,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_year_content",
+	})
+}
+
+// handleAnnualPlanSave sets a category's yearly amount and spreads it into the monthly limits.
+func (a *App) handleAnnualPlanSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	year, _ := strconv.Atoi(r.FormValue("year"))
+	if year < 2000 || year > 2100 {
+		http.Error(w, "bad year", 400)
+		return
+	}
+	back := fmt.Sprintf("/budget/year?year=%d", year)
+	p := AnnualPlanItem{Year: year, CategoryName: strings.TrimSpace(r.FormValue("category_name"))}
+	if p.CategoryName == "" {
+		a.setFlash(w, "Category name is required.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	amount, err := parseOptionalCents(r.FormValue("annual_dollars"))
+	if err != nil || !amount.Valid {
+		a.setFlash(w, "Annual amount must be zero or more.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	p.AnnualCents = amount.Int64
+	p.FromMonth, _ = strconv.Atoi(r.FormValue("from_month"))
+	if p.FromMonth < 1 || p.FromMonth > 12 {
+		p.FromMonth = 1
+	}
+	months, spread, err := saveAnnualPlanItem(a.db, getUserID(r), p)
+	if err != nil {
+		log.Printf("Error saveAnnualPlanItem: %v", err)
+		a.setFlash(w, "Error saving annual plan.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if months == 0 {
+		a.setFlash(w, fmt.Sprintf("Plan for %s saved, but every month from %s is closed, so no limits changed.", p.CategoryName, time.Month(p.FromMonth)), false)
+	} else {
+		msg := fmt.Sprintf("%s: %s spread over %d months (about %s a month).", p.CategoryName, money(spread), months, money(spread/int64(months)))
+		if spread < p.AnnualCents {
+			msg += fmt.Sprintf(" Closed months already hold %s of the %s plan.", money(p.AnnualCents-spread), money(p.AnnualCents))
+		}
+		a.setFlash(w, msg, false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (a *App) handleAnnualPlanDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deleteAnnualPlanItem(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleteAnnualPlanItem: %v", err)
+		a.setFlash(w, "Error removing annual plan.", true)
+	} else {
+		a.setFlash(w, "Annual plan removed. Monthly limits it set are unchanged.", false)
+	}
+	back := "/budget/year"
+	if year, _ := strconv.Atoi(r.FormValue("year")); year >= 2000 && year <= 2100 {
+		back = fmt.Sprintf("/budget/year?year=%d", year)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleCalendarFeed serves /calendar/<token>.ics. The token is the only credential, so no
+// session is required (calendar apps can't log in).
+func (a *App) handleCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/calendar/"), ".ics")
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := getUserIDByCalendarToken(a.db, token)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	debts, err := listDebts(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing debts: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	// Payoff milestones only when the user has saved a plan
+	var plan PlanResult
+	if prefs, err := getPlanPreferences(a.db, userID); err == nil {
+		windfalls, err := listWindfalls(a.db, userID)
+		if err != nil {
+			log.Printf("Error listing windfalls: %v", err)
+		}
+		plan = GeneratePlanWithOptions(debts, prefs.MonthlyBudgetCents, prefs.Strategy, 240, PlanOptions{
+			UtilizationTargetPct: prefs.UtilTargetPct,
+			Windfalls:            planWindfalls(windfalls, time.Now()),
+		})
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="debt-payments.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write([]byte(buildCalendarFeed(debts, plan, time.Now())))
+}
+
+func (a *App) handleCalendarFeedReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	if _, err := resetCalendarFeedToken(a.db, userID); err != nil {
+		log.Printf("Error resetting calendar feed: %v", err)
+		a.setFlash(w, "Failed to create calendar feed", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "New calendar feed URL created. Any previous URL no longer works.", false)
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+func (a *App) handleCalendarFeedRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	if err := deleteCalendarFeed(a.db, userID); err != nil {
+		log.Printf("Error revoking calendar feed: %v", err)
+		a.setFlash(w, "Failed to revoke calendar feed", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Calendar feed revoked.", false)
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -34,22 +34,59 @@ func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var total int64
+	// Overall utilization: active revolving debts that have a credit limit recorded
+	var revolvingBalance, revolvingLimit int64
 	activeDebts := make([]Debt, 0)
 	for _, d := range debts {
 		total += d.BalanceCents
 		if d.Active && d.BalanceCents > 0 {
 			activeDebts = append(activeDebts, d)
 		}
+		if d.Active && isRevolvingKind(d.Kind) && d.CreditLimitCents > 0 {
+			revolvingBalance += d.BalanceCents
+			revolvingLimit += d.CreditLimitCents
+		}
 	}
 	paymentsThisMonthCount, paymentsThisMonthTotal, _ := PaymentsThisMonth(a.db, userID)
 
+	// Overdue / due-soon badges from the last two months of payments
+	today := time.Now().UTC()
+	recentPayments, err := listPaymentsSince(a.db, userID, today.AddDate(0, -2, -1))
+	if err != nil {
+		log.Printf("Error listing recent payments: %v", err)
+	}
+	dueStatuses := make(map[int64]DueStatus)
+	var overdueCount, dueSoonCount int
+	for _, d := range debts {
+		st := computeDueStatus(d, recentPayments, today)
+		dueStatuses[d.ID] = st
+		switch st.Status {
+		case DueStatusOverdue:
+			overdueCount++
+		case DueStatusDueSoon:
+			dueSoonCount++
+		}
+	}
+
+	budgetAlerts, err := listBudgetAlerts(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing budget alerts: %v", err)
+	}
+
 	flash, flashType := a.getFlash(r)
 	a.render(w, http.StatusOK, "index.html", map[string]any{
+		"DueStatuses":           dueStatuses,
+		"OverdueCount":          overdueCount,
+		"DueSoonCount":          dueSoonCount,
+		"BudgetAlerts":          budgetAlerts,
 		"Debts":                 debts,
 		"ActiveDebts":           activeDebts,
 		"Total":                 total,
 		"PaymentsThisMonthCount": paymentsThisMonthCount,
 		"PaymentsThisMonthTotal": paymentsThisMonthTotal,
+		"RevolvingBalanceCents": revolvingBalance,
+		"RevolvingLimitCents":   revolvingLimit,
+		"UtilizationPct":        utilizationPct(revolvingBalance, revolvingLimit),
 		"SearchQuery":           searchQuery,
 		"KindFilter":            kindFilter,
 		"StatusFilter":          statusFilter,
@@ -89,6 +126,8 @@ func (a *App) handleDebtCreate(w http.ResponseWriter, r *http.Request) {
 	minPayDollars := r.FormValue("min_payment_dollars")
 	paymentDollars := r.FormValue("payment_dollars")
 	dueDayStr := r.FormValue("due_day")
+	creditLimitDollars := r.FormValue("credit_limit_dollars")
+	lateFeeDollars := r.FormValue("late_fee_dollars")
 
 	// Very basic validation
 	validKinds := map[string]bool{
@@ -169,9 +208,28 @@ func (a *App) handleDebtCreate(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/debts/new", http.StatusSeeOther)
 		return
 	}
+	limitD := 0.0
+	if creditLimitDollars != "" && isRevolvingKind(kind) {
+		limitD, err = strconv.ParseFloat(creditLimitDollars, 64)
+		if err != nil || limitD < 0 {
+			a.setFlash(w, "Invalid credit limit. Please enter a valid amount or leave it blank.", true)
+			http.Redirect(w, r, "/debts/new", http.StatusSeeOther)
+			return
+		}
+	}
+	feeD := 0.0
+	if lateFeeDollars != "" {
+		feeD, err = strconv.ParseFloat(lateFeeDollars, 64)
+		if err != nil || feeD < 0 {
+			a.setFlash(w, "Invalid late fee. Please enter a valid amount or leave it blank.", true)
+			http.Redirect(w, r, "/debts/new", http.StatusSeeOther)
+			return
+		}
+	}
 
 	notes := html.EscapeString(strings.TrimSpace(r.FormValue("notes")))
 	name = html.EscapeString(strings.TrimSpace(name))
+	accountRef := strings.Join(strings.Fields(r.FormValue("account_ref")), "")
 	d := Debt{
 		Name:            name,
 		Kind:            kind,
@@ -181,6 +239,9 @@ func (a *App) handleDebtCreate(w http.ResponseWriter, r *http.Request) {
 		PaymentCents:    int64(payD * 100.0),
 		DueDay:          dueDay,
 		Notes:           notes,
+		CreditLimitCents: int64(limitD * 100.0),
+		LateFeeCents:    int64(feeD * 100.0),
+		AccountRef:      accountRef,
 	}
 	userID := getUserID(r)
 	_, err = createDebt(a.db, userID, d)
@@ -217,7 +278,20 @@ func (a *App) handleDebtView(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", 500)
 		return
 	}
+	recurring, err := listRecurringPaymentsForDebt(a.db, userID, id)
+	if err != nil {
+		log.Printf("Error listing recurring payments: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	missed, err := listMissedPaymentsForDebt(a.db, userID, id)
+	if err != nil {
+		log.Printf("Error listing missed payments: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
 	now := time.Now().UTC()
+	dueStatus := computeDueStatus(debt, payments, now)
 	var thisMonthCount int
 	var thisMonthTotal int64
 	for _, p := range payments {
@@ -230,6 +304,9 @@ func (a *App) handleDebtView(w http.ResponseWriter, r *http.Request) {
 	a.render(w, http.StatusOK, "debt_view.html", map[string]any{
 		"Debt":               debt,
 		"Payments":           payments,
+		"Recurring":          recurring,
+		"Missed":             missed,
+		"DueStatus":          dueStatus,
 		"ThisMonthCount":     thisMonthCount,
 		"ThisMonthTotal":     thisMonthTotal,
 		"Flash":              flash,
@@ -289,6 +366,8 @@ func (a *App) handleDebtUpdate(w http.ResponseWriter, r *http.Request) {
 	minPayDollars := r.FormValue("min_payment_dollars")
 	paymentDollars := r.FormValue("payment_dollars")
 	dueDayStr := r.FormValue("due_day")
+	creditLimitDollars := r.FormValue("credit_limit_dollars")
+	lateFeeDollars := r.FormValue("late_fee_dollars")
 
 	validKinds := map[string]bool{
 		"card":           true,
@@ -368,9 +447,28 @@ func (a *App) handleDebtUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/debts/edit?id=%d", id), http.StatusSeeOther)
 		return
 	}
+	limitD := 0.0
+	if creditLimitDollars != "" && isRevolvingKind(kind) {
+		limitD, err = strconv.ParseFloat(creditLimitDollars, 64)
+		if err != nil || limitD < 0 {
+			a.setFlash(w, "Invalid credit limit. Please enter a valid amount or leave it blank.", true)
+			http.Redirect(w, r, fmt.Sprintf("/debts/edit?id=%d", id), http.StatusSeeOther)
+			return
+		}
+	}
+	feeD := 0.0
+	if lateFeeDollars != "" {
+		feeD, err = strconv.ParseFloat(lateFeeDollars, 64)
+		if err != nil || feeD < 0 {
+			a.setFlash(w, "Invalid late fee. Please enter a valid amount or leave it blank.", true)
+			http.Redirect(w, r, fmt.Sprintf("/debts/edit?id=%d", id), http.StatusSeeOther)
+			return
+		}
+	}
 
 	notes := html.EscapeString(strings.TrimSpace(r.FormValue("notes")))
 	name = html.EscapeString(strings.TrimSpace(name))
+	accountRef := strings.Join(strings.Fields(r.FormValue("account_ref")), "")
 	d := Debt{
 		ID:              id,
 		Name:            name,
@@ -381,6 +479,9 @@ func (a *App) handleDebtUpdate(w http.ResponseWriter, r *http.Request) {
 		PaymentCents:    int64(payD * 100.0),
 		DueDay:          dueDay,
 		Notes:           notes,
+		CreditLimitCents: int64(limitD * 100.0),
+		LateFeeCents:    int64(feeD * 100.0),
+		AccountRef:      accountRef,
 	}
 	userID := getUserID(r)
 	if err := updateDebt(a.db, userID, d); err != nil {
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// All exports share one layout so they line up in a spreadsheet: ISO dates (YYYY-MM-DD),
+// amounts as plain decimal dollars without symbols or separators (1234.56), APR as a
+// percentage number (19.99), booleans as yes/no. Files start with a UTF-8 byte order mark
+// so Excel opens them with the right encoding.
+
+// csvAmount formats cents as a plain decimal for spreadsheets.
+func csvAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// csvText unescapes stored text (names and notes are HTML-escaped on input) and defuses
+// values a spreadsheet would treat as a formula.
+func csvText(s string) string {
+	s = html.UnescapeString(s)
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// parseExportRange reads optional from/to query params (YYYY-MM-DD).
+func parseExportRange(r *http.Request) (from, to time.Time, err error) {
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, fmt.Errorf("bad from date")
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, fmt.Errorf("bad to date")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, fmt.Errorf("to date is before from date")
+	}
+	return from, to, nil
+}
+
+// writeCSV sends rows as a CSV download named <name>[_from][_to].csv.
+func writeCSV(w http.ResponseWriter, name string, from, to time.Time, header []string, rows [][]string) {
+	filename := name
+	if !from.IsZero() {
+		filename += "_from-" + from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		filename += "_to-" + to.Format("2006-01-02")
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	w.Write([]byte("\ufeff"))
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	if err := cw.Error(); err != nil {
+		log.Printf("Error writing %s export: %v", name, err)
+	}
+}
+
+// handleExport shows the export page with the date-range form.
+func (a *App) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "export.html", map[string]any{
+		"From":            r.URL.Query().Get("from"),
+		"To":              r.URL.Query().Get("to"),
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "export_content",
+	})
+}
+
+// handleExportDebts exports every debt as it stands today. The date range filters on the
+// date each debt was added.
+func (a *App) handleExportDebts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	from, to, err := parseExportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	debts, err := listDebts(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing debts: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	header := []string{"id", "name", "kind", "balance", "apr_percent", "min_payment", "planned_payment",
+		"due_day", "credit_limit", "late_fee", "active", "notes", "created_on"}
+	var rows [][]string
+	for _, d := range debts {
+		created := time.Date(d.CreatedAt.Year(), d.CreatedAt.Month(), d.CreatedAt.Day(), 0, 0, 0, 0, time.UTC)
+		if (!from.IsZero() && created.Before(from)) || (!to.IsZero() && created.After(to)) {
+			continue
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(d.ID, 10),
+			csvText(d.Name),
+			formatDebtKind(d.Kind),
+			csvAmount(d.BalanceCents),
+			fmt.Sprintf("%.2f", float64(d.APRBps)/100.0),
+			csvAmount(d.MinPaymentCents),
+			csvAmount(d.PaymentCents),
+			strconv.Itoa(d.DueDay),
+			csvAmount(d.CreditLimitCents),
+			csvAmount(d.LateFeeCents),
+			csvBool(d.Active),
+			csvText(d.Notes),
+			created.Format("2006-01-02"),
+		})
+	}
+	writeCSV(w, "debts", from, to, header, rows)
+}
+
+func (a *App) handleExportPayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	from, to, err := parseExportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	payments, err := listPaymentsInRange(a.db, userID, from, to)
+	if err != nil {
+		log.Printf("Error listing payments: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	header := []string{"id", "date", "debt_id", "debt_name", "amount", "note", "auto_posted", "confirmed"}
+	var rows [][]string
+	for _, p := range payments {
+		rows = append(rows, []string{
+			strconv.FormatInt(p.ID, 10),
+			p.PaidOn.Format("2006-01-02"),
+			strconv.FormatInt(p.DebtID, 10),
+			csvText(p.DebtName),
+			csvAmount(p.AmountCents),
+			csvText(p.Note),
+			csvBool(p.AutoPosted),
+			csvBool(p.Confirmed),
+		})
+	}
+	writeCSV(w, "payments", from, to, header, rows)
+}
+
+// handleExportBudget exports one row per budget category per month, with the limit and
+// spent total. The date range selects the months that overlap it.
+func (a *App) handleExportBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	from, to, err := parseExportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	categories, err := listCategorySpendInRange(a.db, userID, from, to)
+	if err != nil {
+		log.Printf("Error listing budget categories: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	header := []string{"month", "category_id", "category", "limit", "spent", "remaining", "debt_payoff", "month_income"}
+	var rows [][]string
+	for _, c := range categories {
+		rows = append(rows, []string{
+			fmt.Sprintf("%04d-%02d", c.Year, c.Month),
+			strconv.FormatInt(c.ID, 10),
+			csvText(c.Name),
+			csvAmount(c.LimitCents),
+			csvAmount(c.SpentCents),
+			csvAmount(c.LimitCents - c.SpentCents),
+			csvBool(c.IsDebtPayoff),
+			csvAmount(c.IncomeCents),
+		})
+	}
+	writeCSV(w, "budget", from, to, header, rows)
+}
+
+func (a *App) handleExportExpenses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	from, to, err := parseExportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	expenses, err := listExpensesInRange(a.db, userID, from, to)
+	if err != nil {
+		log.Printf("Error listing expenses: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	header := []string{"id", "date", "month", "category_id", "category", "amount", "note", "split_id"}
+	var rows [][]string
+	for _, e := range expenses {
+		splitID := ""
+		if e.SplitID.Valid {
+			splitID = strconv.FormatInt(e.SplitID.Int64, 10)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(e.ID, 10),
+			e.SpentOn.Format("2006-01-02"),
+			fmt.Sprintf("%04d-%02d", e.Year, e.Month),
+			strconv.FormatInt(e.BudgetCategoryID, 10),
+			csvText(e.CategoryName),
+			csvAmount(e.AmountCents),
+			csvText(e.Note),
+			splitID,
+		})
+	}
+	writeCSV(w, "expenses", from, to, header, rows)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// The CSV import wizard is stateless: the uploaded file travels between the map, preview and
+// commit steps in a hidden form field, and rows are re-parsed at each step.
+
+func (a *App) handlePaymentImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	debts, err := listDebts(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing debts: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	var debtID int64
+	if s := r.URL.Query().Get("debt_id"); s != "" {
+		debtID, _ = parseInt64(s)
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "payment_import.html", map[string]any{
+		"Step":            "upload",
+		"Debts":           debts,
+		"DebtID":          debtID,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "payment_import_content",
+	})
+}
+
+// importFlashRedirect reports a wizard error and sends the user back to the upload step.
+func (a *App) importFlashRedirect(w http.ResponseWriter, r *http.Request, debtID int64, msg string) {
+	a.setFlash(w, msg, true)
+	http.Redirect(w, r, fmt.Sprintf("/payments/import?debt_id=%d", debtID), http.StatusSeeOther)
+}
+
+// handlePaymentImportMap reads the uploaded file and shows the column mapping step.
+func (a *App) handlePaymentImportMap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	debtID, err := parseInt64(r.FormValue("debt_id"))
+	if err != nil {
+		http.Error(w, "bad debt id", 400)
+		return
+	}
+	userID := getUserID(r)
+	debt, err := getDebt(a.db, userID, debtID)
+	if err != nil {
+		http.Error(w, "Debt not found", 404)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		a.importFlashRedirect(w, r, debtID, "Please choose a CSV file.")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxImportBytes {
+		a.importFlashRedirect(w, r, debtID, "That file is too large (2 MB maximum).")
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "could not read file", 400)
+		return
+	}
+	records, err := readCSV(data)
+	if err != nil {
+		a.importFlashRedirect(w, r, debtID, "That file could not be read as CSV: "+err.Error())
+		return
+	}
+	a.renderImportMapping(w, r, debt, string(data), records, guessCSVMapping(records), "")
+}
+
+func (a *App) renderImportMapping(w http.ResponseWriter, r *http.Request, debt Debt, raw string, records [][]string, m CSVMapping, errMsg string) {
+	// Column labels come from the first row, which is the header when there is one
+	width := 0
+	for _, rec := range records {
+		if len(rec) > width {
+			width = len(rec)
+		}
+	}
+	columns := make([]string, width)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("Column %d", i+1)
+		if i < len(records[0]) && strings.TrimSpace(records[0][i]) != "" {
+			columns[i] += ": " + strings.TrimSpace(records[0][i])
+		}
+	}
+	sample := records
+	if len(sample) > 6 {
+		sample = sample[:6]
+	}
+	a.render(w, http.StatusOK, "payment_import.html", map[string]any{
+		"Step":            "map",
+		"Debt":            debt,
+		"CSV":             raw,
+		"Columns":         columns,
+		"Sample":          sample,
+		"RowCount":        len(records),
+		"Mapping":         m,
+		"DateFormats":     importDateFormats,
+		"Flash":           errMsg,
+		"FlashType":       "error",
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "payment_import_content",
+	})
+}
+
+// parseImportForm reads the debt, the carried CSV and the column mapping from a wizard step.
+func (a *App) parseImportForm(r *http.Request) (Debt, string, [][]string, CSVMapping, error) {
+	var m CSVMapping
+	debtID, err := parseInt64(r.FormValue("debt_id"))
+	if err != nil {
+		return Debt{}, "", nil, m, fmt.Errorf("bad debt id")
+	}
+	debt, err := getDebt(a.db, getUserID(r), debtID)
+	if err != nil {
+		return Debt{}, "", nil, m, fmt.Errorf("debt not found")
+	}
+	raw := r.FormValue("csv_data")
+	records, err := readCSV([]byte(raw))
+	if err != nil {
+		return debt, "", nil, m, err
+	}
+	if m.DateCol, err = parseInt(r.FormValue("date_col")); err != nil {
+		return debt, "", nil, m, fmt.Errorf("choose the date column")
+	}
+	if m.AmountCol, err = parseInt(r.FormValue("amount_col")); err != nil {
+		return debt, "", nil, m, fmt.Errorf("choose the amount column")
+	}
+	if m.NoteCol, err = parseInt(r.FormValue("note_col")); err != nil {
+		m.NoteCol = -1
+	}
+	m.DateFormat = r.FormValue("date_format")
+	if !validImportDateFormat(m.DateFormat) {
+		return debt, "", nil, m, fmt.Errorf("choose a date format")
+	}
+	m.HasHeader = r.FormValue("has_header") == "1"
+	return debt, raw, records, m, nil
+}
+
+func (a *App) handlePaymentImportPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	debt, raw, records, m, err := a.parseImportForm(r)
+	if err != nil {
+		if debt.ID == 0 {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		a.importFlashRedirect(w, r, debt.ID, "Import failed: "+err.Error())
+		return
+	}
+	if m.DateCol == m.AmountCol {
+		a.renderImportMapping(w, r, debt, raw, records, m, "Date and amount must be different columns.")
+		return
+	}
+	userID := getUserID(r)
+	existing, err := listPaymentsForDebt(a.db, userID, debt.ID)
+	if err != nil {
+		log.Printf("Error listing payments: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	rows := buildImportRows(records, m, existing)
+	var ready, duplicates, invalid int
+	var readyCents int64
+	for _, row := range rows {
+		switch {
+		case row.Err != "":
+			invalid++
+		case row.Duplicate:
+			duplicates++
+		default:
+			ready++
+			readyCents += row.AmountCents
+		}
+	}
+	a.render(w, http.StatusOK, "payment_import.html", map[string]any{
+		"Step":            "preview",
+		"Debt":            debt,
+		"CSV":             raw,
+		"Mapping":         m,
+		"Rows":            rows,
+		"ReadyCount":      ready,
+		"ReadyCents":      readyCents,
+		"DuplicateCount":  duplicates,
+		"InvalidCount":    invalid,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "payment_import_content",
+	})
+}
+
+// handlePaymentImportCommit records the rows ticked on the preview step in one transaction.
+func (a *App) handlePaymentImportCommit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	debt, _, records, m, err := a.parseImportForm(r)
+	if err != nil {
+		if debt.ID == 0 {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		a.importFlashRedirect(w, r, debt.ID, "Import failed: "+err.Error())
+		return
+	}
+	selected := map[string]bool{}
+	for _, v := range r.Form["row"] {
+		selected[v] = true
+	}
+	userID := getUserID(r)
+	// Duplicates are not re-checked here: the user may have ticked one on purpose
+	rows := buildImportRows(records, m, nil)
+	var payments []Payment
+	var total int64
+	for _, row := range rows {
+		if row.Err != "" || !selected[fmt.Sprint(row.Index)] {
+			continue
+		}
+		payments = append(payments, Payment{
+			PaidOn:      row.Date,
+			AmountCents: row.AmountCents,
+			Note:        html.EscapeString(row.Note),
+		})
+		total += row.AmountCents
+	}
+	if len(payments) == 0 {
+		a.importFlashRedirect(w, r, debt.ID, "No rows were selected, so nothing was imported.")
+		return
+	}
+	if err := importPayments(a.db, userID, debt.ID, payments); err != nil {
+		log.Printf("Error importing payments: %v", err)
+		a.importFlashRedirect(w, r, debt.ID, "Import failed; no payments were recorded.")
+		return
+	}
+	a.setFlash(w, fmt.Sprintf("Imported %d payments totalling %s. The debt balance has been updated.", len(payments), money(total)), false)
+	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", debt.ID), http.StatusSeeOther)
+}
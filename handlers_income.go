@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Income sources (expected pay) and receipts (actual pay) ---
+
+func (a *App) handleIncome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+	if y, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil && y >= 2000 && y <= 2100 {
+		year = y
+	}
+	if m, err := strconv.Atoi(r.URL.Query().Get("month")); err == nil && m >= 1 && m <= 12 {
+		month = m
+	}
+	sources, err := listIncomeSources(a.db, userID)
+	if err != nil {
+		log.Printf("Error listIncomeSources: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	receipts, err := listIncomeReceipts(a.db, userID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		log.Printf("Error listIncomeReceipts: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	projections, expected := projectIncome(sources, year, month)
+	var received int64
+	for _, rc := range receipts {
+		received += rc.AmountCents
+	}
+	prev, next := from.AddDate(0, -1, 0), from.AddDate(0, 1, 0)
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "income.html", map[string]any{
+		"Year":            year,
+		"Month":           month,
+		"PrevYear":        prev.Year(),
+		"PrevMonth":       int(prev.Month()),
+		"NextYear":        next.Year(),
+		"NextMonth":       int(next.Month()),
+		"Sources":         sources,
+		"Frequencies":     incomeFrequencies,
+		"Projections":     projections,
+		"ExpectedCents":   expected,
+		"Receipts":        receipts,
+		"ReceivedCents":   received,
+		"ExtraMonths":     extraPaycheckMonths(sources, year, month, 12),
+		"Today":           now.Format("2006-01-02"),
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "income_content",
+	})
+}
+
+// parseIncomeSourceForm reads the fields shared by the add and edit source forms.
+func parseIncomeSourceForm(r *http.Request) (IncomeSource, error) {
+	s := IncomeSource{
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Frequency: r.FormValue("frequency"),
+		Active:    r.FormValue("active") == "1",
+	}
+	if s.Name == "" {
+		return s, errors.New("a name is required")
+	}
+	if !validIncomeFrequency(s.Frequency) {
+		return s, errors.New("choose how often this income is paid")
+	}
+	d, err := strconv.ParseFloat(r.FormValue("amount_dollars"), 64)
+	if err != nil || d < 0 {
+		return s, errors.New("the amount must be zero or more")
+	}
+	s.AmountCents = int64(d * 100)
+	if s.NextPayDate, err = time.Parse("2006-01-02", r.FormValue("next_pay_date")); err != nil {
+		return s, errors.New("the next pay date is required")
+	}
+	return s, nil
+}
+
+func (a *App) handleIncomeSourceCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	s, err := parseIncomeSourceForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the income source: %v.", err), true)
+		http.Redirect(w, r, "/income", http.StatusSeeOther)
+		return
+	}
+	if _, err := createIncomeSource(a.db, getUserID(r), s); err != nil {
+		log.Printf("Error createIncomeSource: %v", err)
+		a.setFlash(w, "Error adding income source.", true)
+		http.Redirect(w, r, "/income", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Income source added.", false)
+	http.Redirect(w, r, "/income", http.StatusSeeOther)
+}
+
+func (a *App) handleIncomeSourceUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	s, err := parseIncomeSourceForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the income source: %v.", err), true)
+		http.Redirect(w, r, "/income", http.StatusSeeOther)
+		return
+	}
+	s.ID, _ = strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := updateIncomeSource(a.db, getUserID(r), s); err != nil {
+		log.Printf("Error updateIncomeSource: %v", err)
+		a.setFlash(w, "Error updating income source.", true)
+		http.Redirect(w, r, "/income", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Income source updated.", false)
+	http.Redirect(w, r, "/income", http.StatusSeeOther)
+}
+
+func (a *App) handleIncomeSourceDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deleteIncomeSource(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleteIncomeSource: %v", err)
+		a.setFlash(w, "Error deleting income source.", true)
+	} else {
+		a.setFlash(w, "Income source deleted. Income already recorded is kept.", false)
+	}
+	http.Redirect(w, r, "/income", http.StatusSeeOther)
+}
+
+func (a *App) handleIncomeReceiptCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rc := IncomeReceipt{Note: strings.TrimSpace(r.FormValue("note"))}
+	receivedOn, err := time.Parse("2006-01-02", r.FormValue("received_on"))
+	if err != nil {
+		receivedOn = time.Now()
+	}
+	rc.ReceivedOn = receivedOn
+	back := fmt.Sprintf("/income?year=%d&month=%d", receivedOn.Year(), int(receivedOn.Month()))
+	if d, err := strconv.ParseFloat(r.FormValue("amount_dollars"), 64); err == nil && d > 0 {
+		rc.AmountCents = int64(d * 100)
+	}
+	if rc.AmountCents <= 0 {
+		a.setFlash(w, "Amount must be greater than zero.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if id, err := strconv.ParseInt(r.FormValue("income_source_id"), 10, 64); err == nil && id > 0 {
+		rc.IncomeSourceID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	if _, err := createIncomeReceipt(a.db, getUserID(r), rc); err != nil {
+		log.Printf("Error createIncomeReceipt: %v", err)
+		a.setFlash(w, "Error recording income.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Income recorded.", false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (a *App) handleIncomeReceiptDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deleteIncomeReceipt(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleteIncomeReceipt: %v", err)
+		a.setFlash(w, "Error deleting income.", true)
+	} else {
+		a.setFlash(w, "Income deleted.", false)
+	}
+	back := "/income"
+	year, _ := strconv.Atoi(r.FormValue("year"))
+	month, _ := strconv.Atoi(r.FormValue("month"))
+	if year >= 2000 && year <= 2100 && month >= 1 && month <= 12 {
+		back = fmt.Sprintf("/income?year=%d&month=%d", year, month)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"html"
 	"log"
@@ -50,6 +51,7 @@ func (a *App) handlePaymentAdd(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	a.checkPaymentAlerts(userID, paidOn)
 	a.setFlash(w, "Payment recorded. The debt balance has been updated.", false)
 	redirectTo := r.FormValue("redirect_to")
 	if redirectTo == "payments" {
@@ -129,12 +131,17 @@ func (a *App) handlePaymentUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := updatePayment(a.db, userID, paymentID, paidOn, int64(amtD*100.0), note); err != nil {
+	if err := updatePayment(a.db, userID, paymentID, paidOn, int64(amtD*100.0), note); errors.Is(err, errOutsideBudgetMonth) {
+		a.setFlash(w, "This payment is recorded in a budget, so its date must stay in that month. To move it, delete it and add it again.", true)
+		http.Redirect(w, r, fmt.Sprintf("/payments/edit?id=%d", paymentID), http.StatusSeeOther)
+		return
+	} else if err != nil {
 		log.Printf("Error updating payment: %v", err)
 		a.setFlash(w, "Failed to update payment", true)
 		http.Redirect(w, r, fmt.Sprintf("/payments/edit?id=%d", paymentID), http.StatusSeeOther)
 		return
 	}
+	a.checkPaymentAlerts(userID, paidOn)
 	a.setFlash(w, "Payment updated. Balance has been recalculated.", false)
 	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", payment.DebtID), http.StatusSeeOther)
 }
@@ -168,6 +175,10 @@ func (a *App) handlePaymentDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	a.setFlash(w, "Payment removed. The debt balance has been adjusted.", false)
+	if r.FormValue("redirect_to") == "payments" {
+		http.Redirect(w, r, "/payments", http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", debtID), http.StatusSeeOther)
 }
 
@@ -213,9 +224,16 @@ func (a *App) handlePayments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	paymentsThisMonthCount, paymentsThisMonthTotal, _ := PaymentsThisMonth(a.db, userID)
+	unconfirmed := 0
+	for _, p := range payments {
+		if p.AutoPosted && !p.Confirmed {
+			unconfirmed++
+		}
+	}
 	flash, flashType := a.getFlash(r)
 	a.render(w, http.StatusOK, "payments.html", map[string]any{
 		"Payments":                payments,
+		"UnconfirmedCount":        unconfirmed,
 		"PaymentsThisMonthCount":  paymentsThisMonthCount,
 		"PaymentsThisMonthTotal":  paymentsThisMonthTotal,
 		"Flash":                   flash,
@@ -224,3 +242,37 @@ func (a *App) handlePayments(w http.ResponseWriter, r *http.Request) {
 		"ContentTemplate":         "payments_content",
 	})
 }
+
+func (a *App) handlePaymentConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	paymentID, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad payment id", 400)
+		return
+	}
+	userID := getUserID(r)
+	payment, err := getPayment(a.db, userID, paymentID)
+	if err != nil {
+		log.Printf("Error getting payment: %v", err)
+		http.Error(w, "Payment not found", 404)
+		return
+	}
+	if err := confirmPayment(a.db, userID, paymentID); err != nil {
+		log.Printf("Error confirming payment: %v", err)
+		a.setFlash(w, "Failed to confirm payment", true)
+	} else {
+		a.setFlash(w, "Automatic payment confirmed.", false)
+	}
+	if r.FormValue("redirect_to") == "payments" {
+		http.Redirect(w, r, "/payments", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", payment.DebtID), http.StatusSeeOther)
+}
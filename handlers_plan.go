@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,23 +24,42 @@ func (a *App) handlePlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	budgetDollarsStr := r.URL.Query().Get("budget_dollars")
-	strategyStr := r.URL.Query().Get("strategy")
-	if strategyStr == "" {
-		strategyStr = string(Avalanche)
+	// Defaults: the user's saved plan, else $500 avalanche
+	prefs, err := getPlanPreferences(a.db, userID)
+	hasPrefs := err == nil
+	if !hasPrefs {
+		prefs = PlanPreferences{MonthlyBudgetCents: 50000, Strategy: Avalanche, UtilTargetPct: 30}
 	}
-	if budgetDollarsStr == "" {
-		budgetDollarsStr = "500"
+
+	monthlyBudgetCents := prefs.MonthlyBudgetCents
+	if budgetDollarsStr := r.URL.Query().Get("budget_dollars"); budgetDollarsStr != "" {
+		budgetD, _ := strconv.ParseFloat(budgetDollarsStr, 64)
+		monthlyBudgetCents = int64(budgetD * 100.0)
 	}
-	budgetD, _ := strconv.ParseFloat(budgetDollarsStr, 64)
-	monthlyBudgetCents := int64(budgetD * 100.0)
 
-	strategy := Strategy(strategyStr)
-	if strategy != Snowball && strategy != Avalanche {
+	strategy := prefs.Strategy
+	if strategyStr := r.URL.Query().Get("strategy"); strategyStr != "" {
+		strategy = Strategy(strategyStr)
+	}
+	if strategy != Snowball && strategy != Avalanche && strategy != Utilization {
 		strategy = Avalanche
 	}
 
-	plan := GeneratePlan(debts, monthlyBudgetCents, strategy, 240) // up to 20 years
+	// Utilization strategy: per-debt target (percent of credit limit), default 30%
+	utilTargetPct := prefs.UtilTargetPct
+	if v, err := strconv.ParseFloat(r.URL.Query().Get("util_target"), 64); err == nil && v >= 0 && v <= 100 {
+		utilTargetPct = v
+	}
+
+	windfalls, err := listWindfalls(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing windfalls: %v", err)
+	}
+	plan := GeneratePlanWithOptions(debts, monthlyBudgetCents, strategy, 240, PlanOptions{
+		UtilizationTargetPct: utilTargetPct,
+		Windfalls:            planWindfalls(windfalls, time.Now()),
+	}) // up to 20 years
+	utilCurve := ProjectedUtilization(debts, plan)
 
 	// Create a map of debt ID to debt for easy lookup in template
 	debtMap := make(map[int64]Debt)
@@ -66,6 +88,7 @@ func (a *App) handlePlan(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	flash, flashType := a.getFlash(r)
 	a.render(w, http.StatusOK, "plan.html", map[string]any{
 		"Debts":                debts,
 		"DebtMap":              debtMap,
@@ -73,9 +96,117 @@ func (a *App) handlePlan(w http.ResponseWriter, r *http.Request) {
 		"MonthlyBudgetCents":    monthlyBudgetCents,
 		"Strategy":             strategy,
 		"Plan":                 plan,
+		"UtilTargetPct":        utilTargetPct,
+		"UtilCurve":            utilCurve,
+		"HasSavedPlan":         hasPrefs,
+		"SavedPlan":            prefs,
 		"BudgetSuggestedCents": budgetSuggestedCents,
+		"Windfalls":            windfalls,
+		"ThisMonth":            time.Now().Format("2006-01"),
+		"Flash":                flash,
+		"FlashType":            flashType,
 		"CSRFToken":            a.getCSRFToken(r),
 		"ContentTemplate":      "plan_content",
 	})
 }
 
+
+// handlePlanSave stores the current plan inputs as the user's preferred plan.
+func (a *App) handlePlanSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	budgetD, err := strconv.ParseFloat(r.FormValue("budget_dollars"), 64)
+	if err != nil || budgetD < 0 {
+		a.setFlash(w, "Invalid monthly budget.", true)
+		http.Redirect(w, r, "/plan", http.StatusSeeOther)
+		return
+	}
+	strategy := Strategy(r.FormValue("strategy"))
+	if strategy != Snowball && strategy != Avalanche && strategy != Utilization {
+		strategy = Avalanche
+	}
+	utilTargetPct := 30.0
+	if v, err := strconv.ParseFloat(r.FormValue("util_target"), 64); err == nil && v >= 0 && v <= 100 {
+		utilTargetPct = v
+	}
+	userID := getUserID(r)
+	prefs := PlanPreferences{
+		MonthlyBudgetCents: int64(budgetD * 100.0),
+		Strategy:           strategy,
+		UtilTargetPct:      utilTargetPct,
+	}
+	if err := savePlanPreferences(a.db, userID, prefs); err != nil {
+		log.Printf("Error saving plan preferences: %v", err)
+		a.setFlash(w, "Failed to save plan", true)
+		http.Redirect(w, r, "/plan", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Saved as your plan. It's now the default here and in your calendar feed.", false)
+	http.Redirect(w, r, "/plan", http.StatusSeeOther)
+}
+
+// handleWindfallCreate adds a one-time lump sum to the payoff plan. The tax calculator posts
+// here with an expected refund.
+func (a *App) handleWindfallCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	amount, err := strconv.ParseFloat(r.FormValue("amount_dollars"), 64)
+	if err != nil || amount <= 0 {
+		a.setFlash(w, "Windfall amount must be greater than zero.", true)
+		http.Redirect(w, r, "/plan", http.StatusSeeOther)
+		return
+	}
+	expected, err := time.Parse("2006-01", r.FormValue("expected_month"))
+	if err != nil {
+		a.setFlash(w, "Choose the month you expect the money.", true)
+		http.Redirect(w, r, "/plan", http.StatusSeeOther)
+		return
+	}
+	wf := Windfall{
+		Label:       strings.TrimSpace(r.FormValue("label")),
+		AmountCents: int64(math.Round(amount * 100)),
+		ExpectedOn:  expected,
+	}
+	if wf.Label == "" {
+		wf.Label = "Windfall"
+	}
+	if _, err := createWindfall(a.db, getUserID(r), wf); err != nil {
+		log.Printf("Error creating windfall: %v", err)
+		a.setFlash(w, "Failed to add windfall", true)
+		http.Redirect(w, r, "/plan", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, fmt.Sprintf("%s of %s added to your plan for %s %d.", wf.Label, money(wf.AmountCents), expected.Month(), expected.Year()), false)
+	http.Redirect(w, r, "/plan", http.StatusSeeOther)
+}
+
+func (a *App) handleWindfallDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deleteWindfall(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleting windfall: %v", err)
+		a.setFlash(w, "Failed to remove windfall", true)
+	} else {
+		a.setFlash(w, "Windfall removed from your plan.", false)
+	}
+	http.Redirect(w, r, "/plan", http.StatusSeeOther)
+}
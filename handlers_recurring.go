@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (a *App) handleRecurringCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	debtID, err := parseInt64(r.FormValue("debt_id"))
+	if err != nil {
+		http.Error(w, "bad debt id", 400)
+		return
+	}
+	redirect := fmt.Sprintf("/debts/view?id=%d", debtID)
+
+	amountType := r.FormValue("amount_type")
+	if amountType != "fixed" && amountType != "minimum" && amountType != "statement" {
+		a.setFlash(w, "Please choose how the amount is determined.", true)
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	var amountCents int64
+	if amountType == "fixed" {
+		amtD, err := strconv.ParseFloat(r.FormValue("amount_dollars"), 64)
+		if err != nil || amtD <= 0 {
+			a.setFlash(w, "Enter a fixed amount greater than zero.", true)
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+			return
+		}
+		amountCents = int64(amtD * 100.0)
+	}
+	day, err := parseInt(r.FormValue("day_of_month"))
+	if err != nil || day < 1 || day > 28 {
+		a.setFlash(w, "Day of month must be between 1 and 28.", true)
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	startDate := today
+	if s := r.FormValue("start_date"); s != "" {
+		if startDate, err = time.Parse("2006-01-02", s); err != nil {
+			a.setFlash(w, "Invalid start date.", true)
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+			return
+		}
+	}
+	// Past payments are recorded on the payments page; a rule only posts from today on.
+	if startDate.Before(today) {
+		a.setFlash(w, "Start date can't be in the past. Add earlier payments from the payments page.", true)
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	var endDate sql.NullTime
+	if s := r.FormValue("end_date"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil || t.Before(startDate) {
+			a.setFlash(w, "End date must be a valid date after the start date.", true)
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+			return
+		}
+		endDate = sql.NullTime{Time: t, Valid: true}
+	}
+	note := html.EscapeString(strings.TrimSpace(r.FormValue("note")))
+
+	userID := getUserID(r)
+	rp := RecurringPayment{
+		DebtID:      debtID,
+		AmountType:  amountType,
+		AmountCents: amountCents,
+		DayOfMonth:  day,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Note:        note,
+	}
+	if _, err := createRecurringPayment(a.db, userID, rp); err != nil {
+		log.Printf("Error creating recurring payment: %v", err)
+		a.setFlash(w, "Failed to create recurring payment", true)
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Recurring payment scheduled. It will be posted automatically on its date.", false)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (a *App) handleRecurringToggle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	userID := getUserID(r)
+	rp, err := getRecurringPayment(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Recurring payment not found", 404)
+		return
+	}
+	active := r.FormValue("active") == "1"
+	if err := setRecurringPaymentActive(a.db, userID, id, active); err != nil {
+		log.Printf("Error toggling recurring payment: %v", err)
+		a.setFlash(w, "Failed to update recurring payment", true)
+	} else if active {
+		a.setFlash(w, "Recurring payment resumed.", false)
+	} else {
+		a.setFlash(w, "Recurring payment paused.", false)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", rp.DebtID), http.StatusSeeOther)
+}
+
+func (a *App) handleRecurringDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	userID := getUserID(r)
+	rp, err := getRecurringPayment(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Recurring payment not found", 404)
+		return
+	}
+	if err := deleteRecurringPayment(a.db, userID, id); err != nil {
+		log.Printf("Error deleting recurring payment: %v", err)
+		a.setFlash(w, "Failed to delete recurring payment", true)
+	} else {
+		a.setFlash(w, "Recurring payment removed. Payments already posted were kept.", false)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/debts/view?id=%d", rp.DebtID), http.StatusSeeOther)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Transactions register (every expense across categories) ---
+
+// parseRegisterFilter reads the register's query string. The range defaults to the current
+// month; a bad date or amount is ignored rather than rejected.
+func parseRegisterFilter(r *http.Request) RegisterFilter {
+	q := r.URL.Query()
+	now := time.Now()
+	f := RegisterFilter{
+		From:     time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Search:   strings.TrimSpace(q.Get("q")),
+		Category: strings.TrimSpace(q.Get("category")),
+		Sort:     q.Get("sort"),
+	}
+	f.To = f.From.AddDate(0, 1, -1)
+	if t, err := time.Parse("2006-01-02", q.Get("from")); err == nil {
+		f.From = t
+	}
+	if t, err := time.Parse("2006-01-02", q.Get("to")); err == nil {
+		f.To = t
+	}
+	f.MinCents, _ = parseOptionalCents(q.Get("min"))
+	f.MaxCents, _ = parseOptionalCents(q.Get("max"))
+	f.Page, _ = strconv.Atoi(q.Get("page"))
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	return f
+}
+
+// registerURL links to the register with f's filters, the given sort and page.
+func registerURL(f RegisterFilter, sort string, page int) string {
+	params := url.Values{}
+	params.Set("from", f.From.Format("2006-01-02"))
+	params.Set("to", f.To.Format("2006-01-02"))
+	if f.Search != "" {
+		params.Set("q", f.Search)
+	}
+	if f.Category != "" {
+		params.Set("category", f.Category)
+	}
+	if f.MinCents.Valid {
+		params.Set("min", csvAmount(f.MinCents.Int64))
+	}
+	if f.MaxCents.Valid {
+		params.Set("max", csvAmount(f.MaxCents.Int64))
+	}
+	if sort != "" && sort != "date_desc" {
+		params.Set("sort", sort)
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	return "/budget/transactions?" + params.Encode()
+}
+
+func (a *App) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	f := parseRegisterFilter(r)
+	all, err := listRegister(a.db, userID, f)
+	if err != nil {
+		log.Printf("Error listRegister: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	sortRegister(all, f.Sort)
+	rows := registerPage(all, f.Page)
+	count := len(all)
+	var totalCents int64
+	if count > 0 {
+		totalCents = all[count-1].RunningCents
+	}
+	categoryNames, err := listBudgetCategoryNames(a.db, userID)
+	if err != nil {
+		log.Printf("Error listBudgetCategoryNames: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	// Each column header toggles between its two directions, starting with the more useful one
+	sortURLs := map[string]string{}
+	for _, col := range []struct{ name, first, second string }{
+		{"date", "date_desc", "date_asc"},
+		{"amount", "amount_desc", "amount_asc"},
+		{"category", "category_asc", "category_desc"},
+		{"note", "note_asc", "note_desc"},
+	} {
+		next := col.first
+		if f.Sort == col.first || (f.Sort == "" && col.first == "date_desc") {
+			next = col.second
+		}
+		sortURLs[col.name] = registerURL(f, next, 1)
+	}
+	pages := (count + registerPageSize - 1) / registerPageSize
+	var prevURL, nextURL string
+	if f.Page > 1 {
+		prevURL = registerURL(f, f.Sort, f.Page-1)
+	}
+	if f.Page < pages {
+		nextURL = registerURL(f, f.Sort, f.Page+1)
+	}
+	sortBy := f.Sort
+	if sortBy == "" {
+		sortBy = "date_desc"
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "transactions.html", map[string]any{
+		"Rows":            rows,
+		"Count":           count,
+		"TotalCents":      totalCents,
+		"Filter":          f,
+		"From":            f.From.Format("2006-01-02"),
+		"To":              f.To.Format("2006-01-02"),
+		"Min":             optionalDollars(f.MinCents),
+		"Max":             optionalDollars(f.MaxCents),
+		"SortBy":          sortBy,
+		"SortURLs":        sortURLs,
+		"Page":            f.Page,
+		"Pages":           pages,
+		"PrevURL":         prevURL,
+		"NextURL":         nextURL,
+		"Back":            registerURL(f, f.Sort, f.Page),
+		"CategoryNames":   categoryNames,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "transactions_content",
+	})
+}
+
+func optionalDollars(c sql.NullInt64) string {
+	if !c.Valid {
+		return ""
+	}
+	return csvAmount(c.Int64)
+}
+
+// registerBack returns where to send the user after a register action: the register page they
+// came from, or the current month's register.
+func registerBack(r *http.Request) string {
+	back := r.FormValue("back")
+	if strings.HasPrefix(back, "/budget/transactions") {
+		return back
+	}
+	return "/budget/transactions"
+}
+
+// handleTransactionRecategorize moves one expense to another category of the same month.
+func (a *App) handleTransactionRecategorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	back := registerBack(r)
+	id, _ := strconv.ParseInt(r.FormValue("expense_id"), 10, 64)
+	name := strings.TrimSpace(r.FormValue("category_name"))
+	exp, err := getBudgetExpense(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Expense not found", 404)
+		return
+	}
+	target, err := moveBudgetExpense(a.db, userID, id, name)
+	switch {
+	case errors.Is(err, errBudgetClosed):
+		a.setFlash(w, "That month is closed. Reopen it to change categories.", true)
+	case err == sql.ErrNoRows:
+		a.setFlash(w, fmt.Sprintf("That month's budget has no category named %s.", name), true)
+	case exp.SplitID.Valid:
+		a.setFlash(w, "That expense is split across categories. Edit the split instead.", true)
+	case err != nil && exp.PaymentID.Valid:
+		a.setFlash(w, "That expense is a debt payment, so it can only move to a debt payoff category.", true)
+	case err != nil:
+		log.Printf("Error moveBudgetExpense: %v", err)
+		a.setFlash(w, "Error changing category.", true)
+	default:
+		a.checkBudgetAlerts(userID, target.ID)
+		a.setFlash(w, fmt.Sprintf("Moved to %s.", target.Name), false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// handleTransactionsBulkDelete deletes the checked expenses and split expenses.
+func (a *App) handleTransactionsBulkDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	back := registerBack(r)
+	deleted, failed := 0, 0
+	for _, s := range r.Form["expense_id"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		if exp, err := getBudgetExpense(a.db, userID, id); err == nil && exp.SplitID.Valid {
+			failed++
+			continue
+		}
+		if err := deleteBudgetExpense(a.db, userID, id); err != nil {
+			log.Printf("Error deleteBudgetExpense: %v", err)
+			failed++
+			continue
+		}
+		deleted++
+	}
+	for _, s := range r.Form["split_id"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		if err := deleteExpenseSplit(a.db, userID, id); err != nil {
+			log.Printf("Error deleteExpenseSplit: %v", err)
+			failed++
+			continue
+		}
+		deleted++
+	}
+	switch {
+	case deleted == 0 && failed == 0:
+		a.setFlash(w, "Nothing was selected, so nothing was deleted.", true)
+	case failed > 0:
+		a.setFlash(w, fmt.Sprintf("Deleted %d; %d could not be deleted.", deleted, failed), true)
+	default:
+		a.setFlash(w, fmt.Sprintf("Deleted %d.", deleted), false)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
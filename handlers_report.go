@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// --- Budget vs actual reports ---
+
+// parseReportRange reads from/to as YYYY-MM (the value of a month input) and returns them as
+// year*12+month. It defaults to the last six months and caps the range at maxReportMonths.
+func parseReportRange(r *http.Request) (fromYM, toYM int, err error) {
+	now := time.Now()
+	toYM = now.Year()*12 + int(now.Month())
+	if s := r.URL.Query().Get("to"); s != "" {
+		t, err := time.Parse("2006-01", s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad to month")
+		}
+		toYM = t.Year()*12 + int(t.Month())
+	}
+	fromYM = toYM - 5
+	if s := r.URL.Query().Get("from"); s != "" {
+		t, err := time.Parse("2006-01", s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad from month")
+		}
+		fromYM = t.Year()*12 + int(t.Month())
+	}
+	if fromYM > toYM {
+		return 0, 0, fmt.Errorf("from month is after to month")
+	}
+	if toYM-fromYM+1 > maxReportMonths {
+		fromYM = toYM - maxReportMonths + 1
+	}
+	return fromYM, toYM, nil
+}
+
+func (a *App) loadBudgetReport(userID int64, fromYM, toYM int) (BudgetReport, error) {
+	actuals, err := listCategoryActuals(a.db, userID, fromYM, toYM)
+	if err != nil {
+		return BudgetReport{}, err
+	}
+	totals, err := listMonthTotals(a.db, userID, fromYM, toYM)
+	if err != nil {
+		return BudgetReport{}, err
+	}
+	return buildBudgetReport(fromYM, toYM, actuals, totals), nil
+}
+
+// ymParam formats year*12+month as YYYY-MM for month inputs and links.
+func ymParam(ym int) string {
+	year, month := ymSplit(ym)
+	return fmt.Sprintf("%04d-%02d", year, month)
+}
+
+func (a *App) handleBudgetReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	fromYM, toYM, err := parseReportRange(r)
+	if err != nil {
+		a.setFlash(w, "Choose a valid range of months.", true)
+		http.Redirect(w, r, "/budget/reports", http.StatusSeeOther)
+		return
+	}
+	report, err := a.loadBudgetReport(getUserID(r), fromYM, toYM)
+	if err != nil {
+		log.Printf("Error loading budget report: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	var totals ReportMonth
+	for _, m := range report.Months {
+		totals.IncomeCents += m.IncomeCents
+		totals.SpentCents += m.SpentCents
+		totals.DebtPaymentsCents += m.DebtPaymentsCents
+		totals.LeftoverCents += m.LeftoverCents
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "budget_report.html", map[string]any{
+		"Report":          report,
+		"Totals":          totals,
+		"From":            ymParam(fromYM),
+		"To":              ymParam(toYM),
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_report_content",
+	})
+}
+
+// handleBudgetReportJSON serves the report for charting.
+func (a *App) handleBudgetReportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	fromYM, toYM, err := parseReportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	report, err := a.loadBudgetReport(getUserID(r), fromYM, toYM)
+	if err != nil {
+		log.Printf("Error loading budget report: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error writing budget report JSON: %v", err)
+	}
+}
+
+// handleBudgetReportCSV exports the report: one row per category per month, or with
+// kind=months one row per month of totals.
+func (a *App) handleBudgetReportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	fromYM, toYM, err := parseReportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	report, err := a.loadBudgetReport(getUserID(r), fromYM, toYM)
+	if err != nil {
+		log.Printf("Error loading budget report: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	fromYear, fromMonth := ymSplit(fromYM)
+	toYear, toMonth := ymSplit(toYM)
+	from := time.Date(fromYear, time.Month(fromMonth), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(toYear, time.Month(toMonth)+1, 0, 0, 0, 0, 0, time.UTC)
+	var rows [][]string
+	if r.URL.Query().Get("kind") == "months" {
+		for _, m := range report.Months {
+			rows = append(rows, []string{
+				fmt.Sprintf("%04d-%02d", m.Year, m.Month),
+				csvAmount(m.IncomeCents),
+				csvAmount(m.SpentCents),
+				csvAmount(m.DebtPaymentsCents),
+				csvAmount(m.LeftoverCents),
+			})
+		}
+		writeCSV(w, "budget_report_months", from, to, []string{"month", "income", "spending", "debt_payments", "leftover"}, rows)
+		return
+	}
+	for _, c := range report.Categories {
+		for i, cell := range c.Cells {
+			if !cell.Present {
+				continue
+			}
+			m := report.Months[i]
+			rows = append(rows, []string{
+				fmt.Sprintf("%04d-%02d", m.Year, m.Month),
+				csvText(c.Name),
+				csvAmount(cell.LimitCents),
+				csvAmount(cell.SpentCents),
+				csvAmount(cell.LimitCents - cell.SpentCents),
+			})
+		}
+	}
+	writeCSV(w, "budget_report_categories", from, to, []string{"month", "category", "limit", "spent", "variance"}, rows)
+}
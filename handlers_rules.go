@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Expense categorization rules ---
+
+func (a *App) handleCategoryRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	rules, err := listCategoryRules(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing category rules: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	names, err := listBudgetCategoryNames(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing category names: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	history, err := listCategorizedNotes(a.db, userID, 2000)
+	if err != nil {
+		log.Printf("Error listing expense notes: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	suggestions := suggestCategoryRules(history, rules)
+	if len(suggestions) > 10 {
+		suggestions = suggestions[:10]
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "category_rules.html", map[string]any{
+		"Rules":           rules,
+		"CategoryNames":   names,
+		"Suggestions":     suggestions,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "category_rules_content",
+	})
+}
+
+// parseOptionalCents reads an optional dollar amount into a nullable cents value.
+func parseOptionalCents(s string) (sql.NullInt64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return sql.NullInt64{}, nil
+	}
+	d, err := strconv.ParseFloat(s, 64)
+	if err != nil || d < 0 {
+		return sql.NullInt64{}, fmt.Errorf("bad amount")
+	}
+	return sql.NullInt64{Int64: int64(d * 100), Valid: true}, nil
+}
+
+func (a *App) handleCategoryRuleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	cr := CategoryRule{
+		MatchType:    r.FormValue("match_type"),
+		Pattern:      strings.TrimSpace(r.FormValue("pattern")),
+		CategoryName: strings.TrimSpace(r.FormValue("category_name")),
+	}
+	if cr.MatchType != "regex" {
+		cr.MatchType = "contains"
+	}
+	if cr.Pattern == "" || cr.CategoryName == "" {
+		a.setFlash(w, "A rule needs text to match and a category name.", true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	if err := cr.compile(); err != nil {
+		a.setFlash(w, "That regular expression is not valid: "+err.Error(), true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	var err error
+	if cr.MinAmountCents, err = parseOptionalCents(r.FormValue("min_dollars")); err != nil {
+		a.setFlash(w, "Invalid minimum amount.", true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	if cr.MaxAmountCents, err = parseOptionalCents(r.FormValue("max_dollars")); err != nil {
+		a.setFlash(w, "Invalid maximum amount.", true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	if cr.MinAmountCents.Valid && cr.MaxAmountCents.Valid && cr.MaxAmountCents.Int64 < cr.MinAmountCents.Int64 {
+		a.setFlash(w, "The maximum amount must not be below the minimum.", true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	if _, err := createCategoryRule(a.db, getUserID(r), cr); err != nil {
+		log.Printf("Error creating category rule: %v", err)
+		a.setFlash(w, "Failed to save rule", true)
+		http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Rule saved. It applies to expenses in every month.", false)
+	http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+}
+
+func (a *App) handleCategoryRuleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deleteCategoryRule(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleting category rule: %v", err)
+		a.setFlash(w, "Failed to delete rule", true)
+	} else {
+		a.setFlash(w, "Rule deleted.", false)
+	}
+	http.Redirect(w, r, "/budget/rules", http.StatusSeeOther)
+}
+
+// handleBudgetExpenseQuick records an expense from the budget page. With category "auto" the
+// category comes from the first matching rule; the category is created in this month if needed.
+func (a *App) handleBudgetExpenseQuick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	year, _ := strconv.Atoi(r.FormValue("year"))
+	month, _ := strconv.Atoi(r.FormValue("month"))
+	if year < 2000 || year > 2100 || month < 1 || month > 12 {
+		http.Error(w, "bad month", 400)
+		return
+	}
+	back := fmt.Sprintf("/budget/view?year=%d&month=%d", year, month)
+	note := strings.TrimSpace(r.FormValue("note"))
+	spentOn, err := time.Parse("2006-01-02", r.FormValue("spent_on"))
+	if err != nil {
+		spentOn = defaultSpentOn(year, month, time.Now())
+	}
+	var amountCents int64
+	if d, err := strconv.ParseFloat(r.FormValue("amount_dollars"), 64); err == nil && d > 0 {
+		amountCents = int64(d * 100)
+	}
+	if amountCents <= 0 {
+		a.setFlash(w, "Amount must be greater than zero.", true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	category := strings.TrimSpace(r.FormValue("category_name"))
+	ruleUsed := ""
+	if category == "" || category == "auto" {
+		rules, err := listCategoryRules(a.db, userID)
+		if err != nil {
+			log.Printf("Error listing category rules: %v", err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		cr, ok := matchCategoryRule(rules, note, amountCents)
+		if !ok {
+			a.setFlash(w, "No rule matches that expense. Choose a category, or add a rule under Budget → Rules.", true)
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+		category, ruleUsed = cr.CategoryName, cr.Pattern
+	}
+	categoryID, err := addExpenseToNamedCategory(a.db, userID, year, month, category, spentOn, amountCents, note)
+	if err != nil {
+		log.Printf("Error adding expense: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error adding expense."), true)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	a.checkBudgetAlerts(userID, categoryID)
+	msg := fmt.Sprintf("Expense recorded in %s.", category)
+	if ruleUsed != "" {
+		msg = fmt.Sprintf("Expense recorded in %s (rule \"%s\").", category, ruleUsed)
+	}
+	a.setFlash(w, msg, false)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (a *App) handleSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	user, err := getUserByID(a.db, userID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	prefs, err := getReminderPreferences(a.db, userID)
+	if err != nil {
+		log.Printf("Error getting reminder preferences: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	feedToken, err := getCalendarFeedToken(a.db, userID)
+	if err != nil {
+		log.Printf("Error getting calendar feed: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	feedURL := ""
+	if feedToken != "" {
+		feedURL = getBaseURL(r) + "/calendar/" + feedToken + ".ics"
+	}
+	_, planErr := getPlanPreferences(a.db, userID)
+	budgetPrefs, err := getBudgetPreferences(a.db, userID)
+	if err != nil {
+		log.Printf("Error getting budget preferences: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	salary, err := getSalaryProfile(a.db, userID)
+	hasSalary := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting salary: %v", err)
+	}
+	if !hasSalary {
+		salary = SalaryProfile{Province: "ON", PayFrequency: "biweekly"}
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "settings.html", map[string]any{
+		"User":            user,
+		"Reminders":       prefs,
+		"CalendarFeedURL": feedURL,
+		"HasSavedPlan":    planErr == nil,
+		"BudgetPrefs":     budgetPrefs,
+		"SMTPConfigured":  smtpConfigured(),
+		"HasSalary":       hasSalary,
+		"Salary":          salary,
+		"SalaryNet":       salaryNetPay(salary, time.Now().Year()),
+		"Provinces":       provinceOptions(),
+		"PayFrequencies":  regularPayFrequencies,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "settings_content",
+	})
+}
+
+func (a *App) handleReminderSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	enabled := r.FormValue("enabled") == "1"
+	daysBefore, err := strconv.Atoi(r.FormValue("days_before"))
+	if err != nil || daysBefore < 0 || daysBefore > 14 {
+		a.setFlash(w, "Days before must be between 0 and 14.", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	userID := getUserID(r)
+	if err := updateReminderPreferences(a.db, userID, enabled, daysBefore); err != nil {
+		log.Printf("Error updating reminder preferences: %v", err)
+		a.setFlash(w, "Failed to save reminder settings", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	if enabled {
+		a.setFlash(w, "Reminders on. You'll get an email before payments are due.", false)
+	} else {
+		a.setFlash(w, "Reminders off.", false)
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// handleReminderUnsubscribe turns reminders off from the link in a reminder email (no login
+// needed). The link only shows a confirmation page; mail scanners and link prefetchers follow
+// links with GET, so reminders are turned off on the POST from that page.
+func (a *App) handleReminderUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		token := r.URL.Query().Get("token")
+		if token == "" || !reminderTokenExists(a.db, token) {
+			http.Error(w, "Invalid unsubscribe link", 404)
+			return
+		}
+		a.render(w, http.StatusOK, "reminders_unsubscribe.html", map[string]any{
+			"Token":           token,
+			"ContentTemplate": "reminders_unsubscribe_content",
+		})
+	case http.MethodPost:
+		token := r.FormValue("token")
+		if token == "" {
+			http.Error(w, "Invalid unsubscribe link", 400)
+			return
+		}
+		if err := unsubscribeReminders(a.db, token); err != nil {
+			http.Error(w, "Invalid unsubscribe link", 404)
+			return
+		}
+		a.setFlash(w, "You've been unsubscribed from payment reminders. You can turn them back on in Settings.", false)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (a *App) handleBudgetSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	prefs, err := getBudgetPreferences(a.db, userID)
+	if err != nil {
+		log.Printf("Error getting budget preferences: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	prefs.AutoCopyPrevious = r.FormValue("auto_copy_previous") == "1"
+	prefs.AlertEmail = r.FormValue("alert_email") == "1"
+	if err := saveBudgetPreferences(a.db, userID, prefs); err != nil {
+		log.Printf("Error saving budget preferences: %v", err)
+		a.setFlash(w, "Failed to save budget settings", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	a.setFlash(w, "Budget settings saved.", false)
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// handleSalarySettingsUpdate saves the salary used for after-tax budget income. A blank salary
+// removes it.
+func (a *App) handleSalarySettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	if r.FormValue("gross_dollars") == "" {
+		if err := deleteSalaryProfile(a.db, userID); err != nil {
+			log.Printf("Error deleting salary: %v", err)
+			a.setFlash(w, "Failed to remove salary", true)
+		} else {
+			a.setFlash(w, "Salary removed. Budget income stays as it was.", false)
+		}
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	gross, err := strconv.ParseFloat(r.FormValue("gross_dollars"), 64)
+	if err != nil || gross < 0 {
+		a.setFlash(w, "Salary must be zero or more.", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	p := SalaryProfile{
+		GrossCents:   int64(math.Round(gross * 100)),
+		Province:     r.FormValue("province"),
+		PayFrequency: r.FormValue("pay_frequency"),
+	}
+	if _, ok := provinceNames[p.Province]; !ok {
+		a.setFlash(w, "Choose a province or territory.", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	if _, ok := payPeriodsPerYear[p.PayFrequency]; !ok {
+		a.setFlash(w, "Choose how often you're paid.", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	if err := saveSalaryProfile(a.db, userID, p); err != nil {
+		log.Printf("Error saving salary: %v", err)
+		a.setFlash(w, "Failed to save salary", true)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	p.UserID = userID
+	if _, err := a.refreshSalaryIncome(p, time.Now()); err != nil {
+		log.Printf("Error refreshing salary incomes: %v", err)
+	}
+	a.setFlash(w, fmt.Sprintf("Salary saved: about %s a month after tax.", money(salaryNetPay(p, time.Now().Year()).MonthlyCents)), false)
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Split expenses (one receipt across several categories) ---
+
+// parseSplitForm reads the split form: a total plus parallel category_id / amount_dollars rows.
+// Amounts are rounded to the cent so the allocations can match the total exactly.
+func parseSplitForm(r *http.Request) (spentOn time.Time, note string, allocs []SplitAllocation, err error) {
+	spentOn, err = time.Parse("2006-01-02", r.FormValue("spent_on"))
+	if err != nil {
+		spentOn = time.Now()
+	}
+	note = strings.TrimSpace(r.FormValue("note"))
+	total, err := strconv.ParseFloat(r.FormValue("total_dollars"), 64)
+	if err != nil || total <= 0 {
+		return spentOn, note, nil, errors.New("the total must be greater than zero")
+	}
+	categoryIDs, amounts := r.Form["category_id"], r.Form["amount_dollars"]
+	for i := 0; i < len(categoryIDs) && i < len(amounts); i++ {
+		s := strings.TrimSpace(amounts[i])
+		if s == "" {
+			continue
+		}
+		d, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return spentOn, note, nil, fmt.Errorf("invalid amount %q", s)
+		}
+		id, _ := strconv.ParseInt(categoryIDs[i], 10, 64)
+		allocs = append(allocs, SplitAllocation{CategoryID: id, AmountCents: int64(math.Round(d * 100))})
+	}
+	allocs, err = normalizeSplit(int64(math.Round(total*100)), allocs)
+	return spentOn, note, allocs, err
+}
+
+// renderSplitForm shows the add or edit form for a split in budget, with blank rows to fill in.
+func (a *App) renderSplitForm(w http.ResponseWriter, r *http.Request, budget Budget, split ExpenseSplit) {
+	categories, err := listCategoriesForBudget(a.db, budget.ID, getUserID(r))
+	if err != nil {
+		log.Printf("Error listCategoriesForBudget: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	rows := append([]SplitAllocation(nil), split.Allocations...)
+	for len(rows) < 2 || len(rows) < len(split.Allocations)+splitFormRows {
+		rows = append(rows, SplitAllocation{})
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "budget_split.html", map[string]any{
+		"Budget":          budget,
+		"Split":           split,
+		"Rows":            rows,
+		"Categories":      categories,
+		"Today":           time.Now().Format("2006-01-02"),
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "budget_split_content",
+	})
+}
+
+func (a *App) handleExpenseSplitNew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	budgetID, _ := strconv.ParseInt(r.URL.Query().Get("budget_id"), 10, 64)
+	budget, err := getBudget(a.db, getUserID(r), budgetID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	a.renderSplitForm(w, r, budget, ExpenseSplit{})
+}
+
+func (a *App) handleExpenseSplitCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	budgetID, _ := strconv.ParseInt(r.FormValue("budget_id"), 10, 64)
+	budget, err := getBudget(a.db, userID, budgetID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	spentOn, note, allocs, err := parseSplitForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the split expense: %v.", err), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/new?budget_id=%d", budget.ID), http.StatusSeeOther)
+		return
+	}
+	if _, err := createExpenseSplit(a.db, userID, budget.ID, spentOn, note, allocs); err != nil {
+		log.Printf("Error createExpenseSplit: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error recording split expense."), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/new?budget_id=%d", budget.ID), http.StatusSeeOther)
+		return
+	}
+	a.checkBudgetAlerts(userID, splitCategoryIDs(allocs)...)
+	a.setFlash(w, fmt.Sprintf("Split expense recorded across %d categories.", len(allocs)), false)
+	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+}
+
+func (a *App) handleExpenseSplitEdit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	split, err := getExpenseSplit(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Split expense not found", 404)
+		return
+	}
+	budget, err := getBudget(a.db, userID, split.BudgetID)
+	if err != nil {
+		http.Error(w, "Budget not found", 404)
+		return
+	}
+	a.renderSplitForm(w, r, budget, split)
+}
+
+func (a *App) handleExpenseSplitUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	split, err := getExpenseSplit(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Split expense not found", 404)
+		return
+	}
+	spentOn, note, allocs, err := parseSplitForm(r)
+	if err != nil {
+		a.setFlash(w, fmt.Sprintf("Couldn't save the split expense: %v.", err), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/edit?id=%d", id), http.StatusSeeOther)
+		return
+	}
+	if err := updateExpenseSplit(a.db, userID, id, spentOn, note, allocs); err != nil {
+		log.Printf("Error updateExpenseSplit: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error updating split expense."), true)
+		http.Redirect(w, r, fmt.Sprintf("/budget/split/edit?id=%d", id), http.StatusSeeOther)
+		return
+	}
+	a.checkBudgetAlerts(userID, splitCategoryIDs(allocs)...)
+	budget, _ := getBudget(a.db, userID, split.BudgetID)
+	a.setFlash(w, "Split expense updated.", false)
+	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+}
+
+func (a *App) handleExpenseSplitDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	split, err := getExpenseSplit(a.db, userID, id)
+	if err != nil {
+		http.Error(w, "Split expense not found", 404)
+		return
+	}
+	budget, _ := getBudget(a.db, userID, split.BudgetID)
+	if err := deleteExpenseSplit(a.db, userID, id); err != nil {
+		log.Printf("Error deleteExpenseSplit: %v", err)
+		a.setFlash(w, budgetChangeFailed(err, "Error deleting split expense."), true)
+	} else {
+		a.setFlash(w, "Split expense deleted from every category.", false)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/budget/view?year=%d&month=%d", budget.Year, budget.Month), http.StatusSeeOther)
+}
+
+func splitCategoryIDs(allocs []SplitAllocation) []int64 {
+	ids := make([]int64, len(allocs))
+	for i, a := range allocs {
+		ids[i] = a.CategoryID
+	}
+	return ids
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Statement (OFX/QFX) import. Like the CSV wizard it is stateless: the file is carried from the
+// review step to the commit step in a hidden field and parsed again.
+
+func (a *App) handleStatementImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	userID := getUserID(r)
+	rules, err := listPayeeRules(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing payee rules: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	debts, err := listDebts(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing debts: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	debtNames := map[int64]string{}
+	linked := 0
+	for _, d := range debts {
+		debtNames[d.ID] = d.Name
+		if d.AccountRef != "" {
+			linked++
+		}
+	}
+	flash, flashType := a.getFlash(r)
+	a.render(w, http.StatusOK, "statement_import.html", map[string]any{
+		"Step":            "upload",
+		"Rules":           rules,
+		"DebtNames":       debtNames,
+		"LinkedDebts":     linked,
+		"Flash":           flash,
+		"FlashType":       flashType,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "statement_import_content",
+	})
+}
+
+// loadStatement parses the statement and builds review candidates with suggestions and
+// already-imported flags.
+func (a *App) loadStatement(userID int64, data []byte) ([]StatementCandidate, []Debt, error) {
+	txns, err := parseOFX(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	debts, err := listDebts(a.db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := listPayeeRules(a.db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	catRules, err := listCategoryRules(a.db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	imported := map[string]map[string]bool{}
+	candidates := make([]StatementCandidate, 0, len(txns))
+	for i, t := range txns {
+		if imported[t.AccountID] == nil {
+			if imported[t.AccountID], err = listImportedFITIDs(a.db, userID, t.AccountID); err != nil {
+				return nil, nil, err
+			}
+		}
+		c := StatementCandidate{Index: i, Txn: t, Imported: imported[t.AccountID][t.FITID]}
+		c.Target, c.Reason = suggestStatementTarget(t, debts, rules, catRules)
+		candidates = append(candidates, c)
+	}
+	return candidates, debts, nil
+}
+
+func (a *App) handleStatementReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		a.setFlash(w, "Please choose an OFX or QFX file.", true)
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxImportBytes {
+		a.setFlash(w, "That file is too large (2 MB maximum).", true)
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "could not read file", 400)
+		return
+	}
+	userID := getUserID(r)
+	candidates, debts, err := a.loadStatement(userID, data)
+	if err != nil {
+		a.setFlash(w, "That file could not be read: "+err.Error(), true)
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	categories, err := listBudgetCategoryNames(a.db, userID)
+	if err != nil {
+		log.Printf("Error listing category names: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	// Rules may name a category no budget has yet; offer it anyway
+	known := map[string]bool{}
+	for _, c := range categories {
+		known[c] = true
+	}
+	alreadyImported := 0
+	for _, c := range candidates {
+		if name, ok := strings.CutPrefix(c.Target, "cat:"); ok && !known[name] {
+			known[name] = true
+			categories = append(categories, name)
+		}
+		if c.Imported {
+			alreadyImported++
+		}
+	}
+	var activeDebts []Debt
+	for _, d := range debts {
+		if d.Active {
+			activeDebts = append(activeDebts, d)
+		}
+	}
+	a.render(w, http.StatusOK, "statement_import.html", map[string]any{
+		"Step":            "review",
+		"File":            string(data),
+		"Candidates":      candidates,
+		"Debts":           activeDebts,
+		"Categories":      categories,
+		"AlreadyImported": alreadyImported,
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "statement_import_content",
+	})
+}
+
+func (a *App) handleStatementCommit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	userID := getUserID(r)
+	candidates, debts, err := a.loadStatement(userID, []byte(r.FormValue("file_data")))
+	if err != nil {
+		a.setFlash(w, "Import failed: "+err.Error(), true)
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	var items []StatementImportItem
+	type ruleToSave struct {
+		pattern  string
+		debtID   int64
+		category string
+	}
+	var rules []ruleToSave
+	debtsByID := map[int64]Debt{}
+	for _, d := range debts {
+		debtsByID[d.ID] = d
+	}
+	wrongSign := 0
+	for _, c := range candidates {
+		if c.Imported {
+			continue
+		}
+		key := strconv.Itoa(c.Index)
+		target := r.FormValue("target_" + key)
+		if name := strings.TrimSpace(r.FormValue("new_category_" + key)); name != "" {
+			target = "cat:" + name
+		}
+		item := StatementImportItem{Txn: c.Txn}
+		if idStr, ok := strings.CutPrefix(target, "debt:"); ok {
+			if item.DebtID, err = parseInt64(idStr); err != nil {
+				http.Error(w, "bad debt id", 400)
+				return
+			}
+			debt, ok := debtsByID[item.DebtID]
+			if !ok {
+				http.Error(w, "Debt not found", 404)
+				return
+			}
+			item.AmountCents = c.Txn.PaymentCents(debt)
+			// Payment notes are stored escaped, like payments recorded by hand
+			item.Note = html.EscapeString(c.Txn.Description())
+		} else if name, ok := strings.CutPrefix(target, "cat:"); ok && name != "" {
+			item.CategoryName = name
+			item.AmountCents = c.Txn.ExpenseCents()
+			item.Note = c.Txn.Description()
+		} else {
+			continue
+		}
+		if item.AmountCents <= 0 {
+			wrongSign++
+			continue
+		}
+		items = append(items, item)
+		if r.FormValue("remember_"+key) == "1" && c.Txn.Payee() != "" {
+			rules = append(rules, ruleToSave{c.Txn.Payee(), item.DebtID, item.CategoryName})
+		}
+	}
+	skipped := ""
+	if wrongSign > 0 {
+		skipped = fmt.Sprintf(" Skipped %d that were not payments or purchases (refunds, deposits, charges or zero amounts).", wrongSign)
+	}
+	if len(items) == 0 {
+		a.setFlash(w, "Nothing was selected, so nothing was imported."+skipped, true)
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	payments, expenses, err := importStatementTransactions(a.db, userID, items)
+	if err != nil {
+		log.Printf("Error importing statement: %v", err)
+		if errors.Is(err, errBudgetClosed) {
+			a.setFlash(w, "Some expenses fall in a closed month. Reopen it or leave them out; nothing was recorded.", true)
+		} else {
+			a.setFlash(w, "Import failed; nothing was recorded.", true)
+		}
+		http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+		return
+	}
+	a.checkImportedAlerts(userID, items)
+	for _, rule := range rules {
+		if err := savePayeeRule(a.db, userID, rule.pattern, rule.debtID, rule.category); err != nil {
+			log.Printf("Error saving payee rule: %v", err)
+		}
+	}
+	a.setFlash(w, fmt.Sprintf("Imported %d payments and %d expenses.", payments, expenses)+skipped, false)
+	http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+}
+
+func (a *App) handlePayeeRuleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := parseInt64(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "bad id", 400)
+		return
+	}
+	if err := deletePayeeRule(a.db, getUserID(r), id); err != nil {
+		log.Printf("Error deleting payee rule: %v", err)
+		a.setFlash(w, "Failed to delete rule", true)
+	} else {
+		a.setFlash(w, "Rule deleted.", false)
+	}
+	http.Redirect(w, r, "/import/statement", http.StatusSeeOther)
+}
+
+// checkImportedAlerts checks budget alerts for every category in the months that imported
+// expenses and debt payments landed in.
+func (a *App) checkImportedAlerts(userID int64, items []StatementImportItem) {
+	seen := map[int]bool{}
+	var categoryIDs []int64
+	for _, it := range items {
+		year, month := it.Txn.PostedOn.Year(), int(it.Txn.PostedOn.Month())
+		if seen[year*12+month] {
+			continue
+		}
+		seen[year*12+month] = true
+		budget, err := getBudgetByYearMonth(a.db, userID, year, month)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			log.Printf("Budget alerts: budget %d-%02d: %v", year, month, err)
+			continue
+		}
+		cats, err := listCategoriesForBudget(a.db, budget.ID, userID)
+		if err != nil {
+			log.Printf("Budget alerts: categories for budget %d: %v", budget.ID, err)
+			continue
+		}
+		for _, c := range cats {
+			categoryIDs = append(categoryIDs, c.ID)
+		}
+	}
+	a.checkBudgetAlerts(userID, categoryIDs...)
+}
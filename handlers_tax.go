@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func (a *App) handleTaxBrackets(w http.ResponseWriter, r *http.Request) {
@@ -15,9 +18,10 @@ func (a *App) handleTaxBrackets(w http.ResponseWriter, r *http.Request) {
 	if province == "" {
 		province = "ON"
 	}
-	if _, ok := provinceBrackets[province]; !ok {
+	if _, ok := provinceNames[province]; !ok {
 		province = "ON"
 	}
+	tables := taxTablesParam(r)
 
 	incomeStr := r.URL.Query().Get("income")
 	incomeFilled := r.URL.Query().Has("income")
@@ -28,20 +32,22 @@ func (a *App) handleTaxBrackets(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	fills, totalTaxCents := ComputeBracketFills(province, incomeCents)
+	brackets, _ := tables.combinedBrackets(province)
+	fills, totalTaxCents := ComputeBracketFills(brackets, incomeCents)
+
+	// Deduction what-if: tax saved by an RRSP/FHSA contribution
+	var deduction DeductionWhatIf
+	deductionFilled := false
+	if f, err := strconv.ParseFloat(r.URL.Query().Get("deduction"), 64); err == nil && f > 0 {
+		deduction, _ = ComputeDeduction(tables, province, incomeCents, int64(math.Round(f*100)))
+		deductionFilled = true
+	}
 	if fills == nil {
 		fills = []BracketFill{}
 	}
 
-	provincesList := make([]struct{ Code, Name string }, 0, len(provinceNames))
-	for _, code := range []string{"ON", "BC", "AB", "QC", "SK", "MB", "NS", "NB", "NL", "PE", "NT", "NU", "YT"} {
-		if name, ok := provinceNames[code]; ok {
-			provincesList = append(provincesList, struct{ Code, Name string }{code, name})
-		}
-	}
-
 	a.render(w, http.StatusOK, "tax_brackets.html", map[string]any{
-		"Provinces":      provincesList,
+		"Provinces":      provinceOptions(),
 		"Province":       province,
 		"ProvinceName":   provinceNames[province],
 		"IncomeCents":    incomeCents,
@@ -49,8 +55,149 @@ func (a *App) handleTaxBrackets(w http.ResponseWriter, r *http.Request) {
 		"IncomeFilled":   incomeFilled,
 		"Fills":          fills,
 		"TotalTaxCents":  totalTaxCents,
-		"TaxYear":        "2025",
+		"TaxYear":        tables.Year,
+		"TaxYears":       taxYearList(),
+		"Deduction":      deduction,
+		"DeductionFilled": deductionFilled,
+		"DeductionDollars": r.URL.Query().Get("deduction"),
+		"RefundMonth":    fmt.Sprintf("%d-04", tables.Year+1),
 		"CSRFToken":      a.getCSRFToken(r),
 		"ContentTemplate": "tax_brackets_content",
 	})
 }
+
+// taxTablesParam returns the tax year chosen in the query string, defaulting to the tables for
+// the current year.
+func taxTablesParam(r *http.Request) TaxTables {
+	if y, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil {
+		if t, ok := taxYears[y]; ok {
+			return t
+		}
+	}
+	return taxTablesFor(time.Now().Year())
+}
+
+// provinceOptions lists the provinces and territories in form order.
+func provinceOptions() []struct{ Code, Name string } {
+	out := make([]struct{ Code, Name string }, 0, len(provinceNames))
+	for _, code := range []string{"ON", "BC", "AB", "QC", "SK", "MB", "NS", "NB", "NL", "PE", "NT", "NU", "YT"} {
+		if name, ok := provinceNames[code]; ok {
+			out = append(out, struct{ Code, Name string }{code, name})
+		}
+	}
+	return out
+}
+
+// handleNetPay estimates take-home pay from a salary: payroll deductions, then federal and
+// provincial tax after the common credits.
+func (a *App) handleNetPay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	q := r.URL.Query()
+	province := q.Get("province")
+	if _, ok := provinceNames[province]; !ok {
+		province = "ON"
+	}
+	frequency := q.Get("frequency")
+	if _, ok := payPeriodsPerYear[frequency]; !ok {
+		frequency = "biweekly"
+	}
+	grossFilled := q.Get("gross") != ""
+	var grossCents int64
+	if f, err := strconv.ParseFloat(q.Get("gross"), 64); err == nil && f >= 0 {
+		grossCents = int64(math.Round(f * 100))
+	} else {
+		grossFilled = false
+	}
+	tables := taxTablesParam(r)
+	pay, _ := ComputeNetPay(tables, province, grossCents, frequency)
+
+	a.render(w, http.StatusOK, "net_pay.html", map[string]any{
+		"Provinces":       provinceOptions(),
+		"Province":        province,
+		"ProvinceName":    provinceNames[province],
+		"Frequencies":     regularPayFrequencies,
+		"Frequency":       frequency,
+		"GrossFilled":     grossFilled,
+		"GrossDollars":    grossCents / 100,
+		"Pay":             pay,
+		"TaxYear":         tables.Year,
+		"TaxYears":        taxYearList(),
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "net_pay_content",
+	})
+}
+
+// taxByTypeFields are the income amounts on the tax-by-type form, in form order.
+var taxByTypeFields = []struct{ Name, Label, Help string }{
+	{"employment", "Employment income ($)", "Salary, wages and bonuses."},
+	{"interest", "Interest ($)", "Savings, GICs and bonds outside registered accounts; taxed like employment income."},
+	{"capital_gains", "Capital gains ($)", "Net gains on investments sold this year."},
+	{"eligible_dividends", "Eligible dividends ($)", "Most dividends from public Canadian companies, as received (before the gross-up)."},
+	{"other_dividends", "Non-eligible dividends ($)", "Usually from a small business corporation, as received."},
+}
+
+// handleTaxByType compares the tax on employment, interest, capital gains and dividends, in the
+// chosen province and across all of them.
+func (a *App) handleTaxByType(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	q := r.URL.Query()
+	province := q.Get("province")
+	if _, ok := provinceNames[province]; !ok {
+		province = "ON"
+	}
+	tables := taxTablesParam(r)
+
+	values := map[string]string{}
+	cents := map[string]int64{}
+	filled := false
+	for _, f := range taxByTypeFields {
+		values[f.Name] = q.Get(f.Name)
+		if v, err := strconv.ParseFloat(values[f.Name], 64); err == nil && v > 0 {
+			cents[f.Name] = int64(math.Round(v * 100))
+			filled = true
+		}
+	}
+	mix := IncomeMix{
+		EmploymentCents:           cents["employment"],
+		InterestCents:             cents["interest"],
+		CapitalGainsCents:         cents["capital_gains"],
+		EligibleDividendsCents:    cents["eligible_dividends"],
+		NonEligibleDividendsCents: cents["other_dividends"],
+	}
+	result, _ := ComputeTaxByType(tables, province, mix)
+
+	type provinceRow struct {
+		Code, Name string
+		Tax        TaxByType
+	}
+	var compare []provinceRow
+	for _, p := range provinceOptions() {
+		if b, ok := ComputeTaxByType(tables, p.Code, mix); ok {
+			compare = append(compare, provinceRow{p.Code, p.Name, b})
+		}
+	}
+
+	a.render(w, http.StatusOK, "tax_by_type.html", map[string]any{
+		"Provinces":       provinceOptions(),
+		"Province":        province,
+		"ProvinceName":    provinceNames[province],
+		"Fields":          taxByTypeFields,
+		"Values":          values,
+		"Filled":          filled,
+		"Result":          result,
+		"Compare":         compare,
+		"InclusionPct":    tables.CapitalGainsInclusionPct,
+		"EligibleGrossUp": tables.EligibleGrossUpPct,
+		"OtherGrossUp":    tables.NonEligibleGrossUpPct,
+		"TaxYear":         tables.Year,
+		"TaxYears":        taxYearList(),
+		"CSRFToken":       a.getCSRFToken(r),
+		"ContentTemplate": "tax_by_type_content",
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// icsEscape escapes text for an iCalendar TEXT value (RFC 5545 §3.3.11).
+func icsEscape(s string) string {
+	s = html.UnescapeString(s) // names and notes are stored HTML-escaped
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icsFold writes one content line, folding it at 75 octets as RFC 5545 requires.
+func icsFold(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 { // don't split a UTF-8 sequence
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// buildCalendarFeed returns an iCalendar document with a monthly recurring event for each active
+// debt's due day and all-day events for projected payoff dates from plan (may be empty).
+func buildCalendarFeed(debts []Debt, plan PlanResult, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	stamp := now.UTC().Format("20060102T150405Z")
+	var b strings.Builder
+	icsFold(&b, "BEGIN:VCALENDAR")
+	icsFold(&b, "VERSION:2.0")
+	icsFold(&b, "PRODID:-//Debt Manager//Payment calendar//EN")
+	icsFold(&b, "CALSCALE:GREGORIAN")
+	icsFold(&b, "METHOD:PUBLISH")
+	icsFold(&b, "X-WR-CALNAME:Debt payments")
+
+	event := func(uid string, day time.Time, rrule, summary, description string) {
+		icsFold(&b, "BEGIN:VEVENT")
+		icsFold(&b, "UID:"+uid)
+		icsFold(&b, "DTSTAMP:"+stamp)
+		icsFold(&b, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+		icsFold(&b, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		if rrule != "" {
+			icsFold(&b, "RRULE:"+rrule)
+		}
+		icsFold(&b, "SUMMARY:"+icsEscape(summary))
+		if description != "" {
+			icsFold(&b, "DESCRIPTION:"+icsEscape(description))
+		}
+		icsFold(&b, "TRANSP:TRANSPARENT")
+		icsFold(&b, "END:VEVENT")
+	}
+
+	payoffMonths := PayoffMonthByDebt(plan)
+	var debtFree time.Time
+	activeCount := 0
+	for _, d := range debts {
+		if !d.Active || d.BalanceCents <= 0 {
+			continue
+		}
+		activeCount++
+		first := nextDueDate(d.DueDay, today)
+		amount := expectedPaymentCents(d)
+		rrule := fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d", d.DueDay)
+		// Plan month 1 is the next due date; stop the series once the debt is projected paid off
+		if m, ok := payoffMonths[d.ID]; ok {
+			payoff := first.AddDate(0, m-1, 0)
+			rrule += ";UNTIL=" + payoff.Format("20060102")
+			event(fmt.Sprintf("payoff-%d@debt-manager", d.ID), payoff,
+				"", fmt.Sprintf("Projected payoff: %s", d.Name),
+				fmt.Sprintf("%s is projected to be paid off this month with your saved payoff plan.", d.Name))
+			if payoff.After(debtFree) {
+				debtFree = payoff
+			}
+		}
+		event(fmt.Sprintf("due-%d@debt-manager", d.ID), first, rrule,
+			fmt.Sprintf("%s payment due (%s)", d.Name, money(amount)),
+			fmt.Sprintf("Expected payment: %s (minimum %s). Balance when this feed was generated: %s.",
+				money(amount), money(d.MinPaymentCents), money(d.BalanceCents)))
+	}
+	if activeCount > 0 && len(payoffMonths) == activeCount {
+		event("debt-free@debt-manager", debtFree, "", "Projected debt-free date",
+			"All active debts are projected to be paid off with your saved payoff plan.")
+	}
+	icsFold(&b, "END:VCALENDAR")
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportBytes caps uploaded statement files.
+const maxImportBytes = 2 << 20
+
+// importDateFormats are the date layouts offered when mapping a CSV. Single-digit layouts
+// also accept zero-padded values.
+var importDateFormats = []struct {
+	Layout string
+	Label  string
+}{
+	{"2006-01-02", "YYYY-MM-DD"},
+	{"1/2/2006", "MM/DD/YYYY"},
+	{"2/1/2006", "DD/MM/YYYY"},
+	{"2006/1/2", "YYYY/MM/DD"},
+	{"1/2/06", "MM/DD/YY"},
+	{"2/1/06", "DD/MM/YY"},
+	{"Jan 2, 2006", "Mon D, YYYY"},
+	{"2 Jan 2006", "D Mon YYYY"},
+	{"2-Jan-2006", "D-Mon-YYYY"},
+}
+
+func validImportDateFormat(layout string) bool {
+	for _, f := range importDateFormats {
+		if f.Layout == layout {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVMapping says which columns hold what. Column indexes are 0-based; NoteCol is -1 when unused.
+type CSVMapping struct {
+	DateCol    int
+	AmountCol  int
+	NoteCol    int
+	DateFormat string
+	HasHeader  bool
+}
+
+// ImportRow is one parsed CSV line ready for preview. Err is set when the line can't be imported.
+type ImportRow struct {
+	Index       int // position among data rows, used as the form value for selection
+	Line        int // 1-based line in the file
+	Date        time.Time
+	AmountCents int64
+	Note        string
+	Err         string
+	Duplicate   bool // matches an existing payment (same date and amount)
+}
+
+// readCSV parses an uploaded statement, accepting a UTF-8 BOM and ';' as the delimiter
+// when it is more common than ',' on the first line.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	cr := csv.NewReader(bytes.NewReader(data))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	// Drop blank lines (some exports pad the end)
+	out := records[:0]
+	for _, rec := range records {
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		out = append(out, rec)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("the file has no rows")
+	}
+	return out, nil
+}
+
+// guessCSVMapping picks likely columns from header names so the mapping form starts filled in.
+func guessCSVMapping(records [][]string) CSVMapping {
+	m := CSVMapping{DateCol: 0, AmountCol: 1, NoteCol: -1, DateFormat: "2006-01-02"}
+	header := records[0]
+	found := false
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case strings.Contains(h, "date"):
+			m.DateCol, found = i, true
+		case strings.Contains(h, "amount") || strings.Contains(h, "payment") || strings.Contains(h, "credit"):
+			m.AmountCol, found = i, true
+		case strings.Contains(h, "desc") || strings.Contains(h, "memo") || strings.Contains(h, "note") || strings.Contains(h, "details"):
+			m.NoteCol, found = i, true
+		}
+	}
+	m.HasHeader = found
+	// Guess the date format from the first data row
+	if len(records) > 1 && m.DateCol < len(records[1]) {
+		v := strings.TrimSpace(records[1][m.DateCol])
+		for _, f := range importDateFormats {
+			if _, err := time.Parse(f.Layout, v); err == nil {
+				m.DateFormat = f.Layout
+				break
+			}
+		}
+	}
+	return m
+}
+
+// parseImportAmount reads amounts like "$1,234.56", "-45.00", "(45.00)" or "45.00 CAD".
+// Lenders export payments as credits or debits, so the sign is dropped.
+func parseImportAmount(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimSuffix(s, ")"), "(")
+	s = strings.NewReplacer("$", "", ",", "", " ", "", "CAD", "", "USD", "").Replace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad amount %q", s)
+	}
+	return int64(math.Round(math.Abs(v) * 100)), nil
+}
+
+// buildImportRows applies the mapping to every data row and flags duplicates against existing
+// payments. Each existing payment can only match one imported row, so two identical payments in
+// the file against one existing payment flags only the first.
+func buildImportRows(records [][]string, m CSVMapping, existing []Payment) []ImportRow {
+	type key struct {
+		day    string
+		amount int64
+	}
+	seen := map[key]int{}
+	for _, p := range existing {
+		seen[key{p.PaidOn.Format("2006-01-02"), p.AmountCents}]++
+	}
+	start := 0
+	if m.HasHeader {
+		start = 1
+	}
+	var rows []ImportRow
+	for i := start; i < len(records); i++ {
+		rec := records[i]
+		row := ImportRow{Index: len(rows), Line: i + 1}
+		field := func(col int) string {
+			if col < 0 || col >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[col])
+		}
+		row.Note = field(m.NoteCol)
+		if d, err := time.Parse(m.DateFormat, field(m.DateCol)); err != nil {
+			row.Err = fmt.Sprintf("bad date %q", field(m.DateCol))
+		} else {
+			row.Date = d
+		}
+		if row.Err == "" {
+			if amt, err := parseImportAmount(field(m.AmountCol)); err != nil {
+				row.Err = err.Error()
+			} else if amt == 0 {
+				row.Err = "zero amount"
+			} else {
+				row.AmountCents = amt
+			}
+		}
+		if row.Err == "" {
+			k := key{row.Date.Format("2006-01-02"), row.AmountCents}
+			if seen[k] > 0 {
+				row.Duplicate = true
+				seen[k]--
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
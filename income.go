@@ -0,0 +1,136 @@
+package main
+
+import "time"
+
+// incomeFrequencies lists the supported pay schedules in form order.
+var incomeFrequencies = []string{"weekly", "biweekly", "semimonthly", "monthly", "irregular"}
+
+func validIncomeFrequency(f string) bool {
+	for _, v := range incomeFrequencies {
+		if v == f {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPayFrequency(f string) string {
+	labels := map[string]string{
+		"weekly":      "Weekly",
+		"biweekly":    "Every 2 weeks",
+		"semimonthly": "Twice a month",
+		"monthly":     "Monthly",
+		"irregular":   "Irregular",
+	}
+	if l, ok := labels[f]; ok {
+		return l
+	}
+	return f
+}
+
+// dayInMonth returns the given day of year/month, clamped to the month's last day.
+func dayInMonth(year, month, day int) time.Time {
+	last := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// payDatesInMonth projects a source's pay dates in year/month from its next pay date, both
+// forward and backward. Semi-monthly pay falls on the anchor's day and 15 days before or after
+// it; irregular income is expected only on the next pay date.
+func payDatesInMonth(s IncomeSource, year, month int) []time.Time {
+	anchor := time.Date(s.NextPayDate.Year(), s.NextPayDate.Month(), s.NextPayDate.Day(), 0, 0, 0, 0, time.UTC)
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	next := first.AddDate(0, 1, 0)
+	var out []time.Time
+	switch s.Frequency {
+	case "weekly", "biweekly":
+		step := 7
+		if s.Frequency == "biweekly" {
+			step = 14
+		}
+		diff := int(first.Sub(anchor).Hours() / 24)
+		k := diff / step
+		if diff > 0 && diff%step != 0 {
+			k++
+		}
+		for d := anchor.AddDate(0, 0, k*step); d.Before(next); d = d.AddDate(0, 0, step) {
+			if !d.Before(first) {
+				out = append(out, d)
+			}
+		}
+	case "semimonthly":
+		d1, d2 := anchor.Day(), anchor.Day()+15
+		if anchor.Day() > 15 {
+			d1, d2 = anchor.Day()-15, anchor.Day()
+		}
+		out = append(out, dayInMonth(year, month, d1), dayInMonth(year, month, d2))
+	case "monthly":
+		out = append(out, dayInMonth(year, month, anchor.Day()))
+	default:
+		if !anchor.Before(first) && anchor.Before(next) {
+			out = append(out, anchor)
+		}
+	}
+	return out
+}
+
+// IncomeProjection is one source's expected pay in a month.
+type IncomeProjection struct {
+	Source        IncomeSource
+	PayDates      []time.Time
+	ExpectedCents int64
+	ExtraPaycheck bool // a third bi-weekly (or fifth weekly) paycheque this month
+}
+
+// projectIncome returns expected income per active source for year/month and the total.
+func projectIncome(sources []IncomeSource, year, month int) ([]IncomeProjection, int64) {
+	var out []IncomeProjection
+	var total int64
+	for _, s := range sources {
+		if !s.Active {
+			continue
+		}
+		dates := payDatesInMonth(s, year, month)
+		p := IncomeProjection{Source: s, PayDates: dates, ExpectedCents: s.AmountCents * int64(len(dates))}
+		p.ExtraPaycheck = (s.Frequency == "biweekly" && len(dates) > 2) || (s.Frequency == "weekly" && len(dates) > 4)
+		total += p.ExpectedCents
+		out = append(out, p)
+	}
+	return out, total
+}
+
+// ExtraPaycheckMonth is a month in which at least one source pays an extra cheque.
+type ExtraPaycheckMonth struct {
+	Year       int
+	Month      int
+	Sources    []string
+	ExtraCents int64 // pay beyond the usual number of cheques
+}
+
+// extraPaycheckMonths lists the "three-paycheck months" in the n months starting at year/month.
+func extraPaycheckMonths(sources []IncomeSource, year, month, n int) []ExtraPaycheckMonth {
+	var out []ExtraPaycheckMonth
+	for i := 0; i < n; i++ {
+		t := time.Date(year, time.Month(month+i), 1, 0, 0, 0, 0, time.UTC)
+		projections, _ := projectIncome(sources, t.Year(), int(t.Month()))
+		m := ExtraPaycheckMonth{Year: t.Year(), Month: int(t.Month())}
+		for _, p := range projections {
+			if !p.ExtraPaycheck {
+				continue
+			}
+			usual := 2
+			if p.Source.Frequency == "weekly" {
+				usual = 4
+			}
+			m.Sources = append(m.Sources, p.Source.Name)
+			m.ExtraCents += p.Source.AmountCents * int64(len(p.PayDates)-usual)
+		}
+		if len(m.Sources) > 0 {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPayDatesInMonth(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequency   string
+		next        string
+		year, month int
+		want        []string
+	}{
+		{"biweekly forward, three cheques", "biweekly", "2025-01-10", 2025, 5, []string{"2025-05-02", "2025-05-16", "2025-05-30"}},
+		{"biweekly backward", "biweekly", "2025-01-10", 2024, 11, []string{"2024-11-01", "2024-11-15", "2024-11-29"}},
+		{"biweekly in the anchor's month", "biweekly", "2025-01-10", 2025, 1, []string{"2025-01-10", "2025-01-24"}},
+		{"weekly, five cheques", "weekly", "2025-01-03", 2025, 1, []string{"2025-01-03", "2025-01-10", "2025-01-17", "2025-01-24", "2025-01-31"}},
+		{"weekly on the first", "weekly", "2025-01-03", 2025, 2, []string{"2025-02-07", "2025-02-14", "2025-02-21", "2025-02-28"}},
+		{"semimonthly 15th and last", "semimonthly", "2025-01-15", 2025, 2, []string{"2025-02-15", "2025-02-28"}},
+		{"semimonthly 1st and 16th", "semimonthly", "2025-01-01", 2025, 2, []string{"2025-02-01", "2025-02-16"}},
+		{"semimonthly from the later day", "semimonthly", "2025-01-31", 2025, 4, []string{"2025-04-16", "2025-04-30"}},
+		{"monthly clamps to a short month", "monthly", "2025-01-31", 2025, 2, []string{"2025-02-28"}},
+		{"monthly in a leap year", "monthly", "2025-01-31", 2024, 2, []string{"2024-02-29"}},
+		{"irregular in its month", "irregular", "2025-03-10", 2025, 3, []string{"2025-03-10"}},
+		{"irregular in another month", "irregular", "2025-03-10", 2025, 4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := IncomeSource{Frequency: tt.frequency, NextPayDate: date(t, tt.next)}
+			got := payDatesInMonth(s, tt.year, tt.month)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, d := range got {
+				if d.Format("2006-01-02") != tt.want[i] {
+					t.Errorf("date %d: got %s, want %s", i, d.Format("2006-01-02"), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtraPaycheckMonths(t *testing.T) {
+	sources := []IncomeSource{
+		{Name: "Job", Frequency: "biweekly", AmountCents: 2000_00, NextPayDate: date(t, "2025-01-10"), Active: true},
+		{Name: "Rent", Frequency: "monthly", AmountCents: 900_00, NextPayDate: date(t, "2025-01-01"), Active: true},
+		{Name: "Old job", Frequency: "weekly", AmountCents: 500_00, NextPayDate: date(t, "2025-01-03")},
+	}
+	got := extraPaycheckMonths(sources, 2025, 1, 12)
+	want := []time.Month{time.May, time.October}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want months %v", got, want)
+	}
+	for i, m := range got {
+		if m.Year != 2025 || time.Month(m.Month) != want[i] || m.ExtraCents != 2000_00 || len(m.Sources) != 1 || m.Sources[0] != "Job" {
+			t.Errorf("got %+v, want one extra Job cheque in %v 2025", m, want[i])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+// IncomeMix is a year's income split by how it is taxed. Dividends are the amounts actually
+// received, before the gross-up.
+type IncomeMix struct {
+	EmploymentCents           int64
+	InterestCents             int64
+	CapitalGainsCents         int64
+	EligibleDividendsCents    int64
+	NonEligibleDividendsCents int64
+}
+
+func (m IncomeMix) totalCents() int64 {
+	return m.EmploymentCents + m.InterestCents + m.CapitalGainsCents + m.EligibleDividendsCents + m.NonEligibleDividendsCents
+}
+
+// IncomeTypeTax is the combined federal and provincial tax on one kind of income. Tax on a
+// dividend can be negative: its credit also covers tax on other income.
+type IncomeTypeTax struct {
+	Label        string
+	AmountCents  int64
+	TaxableCents int64 // after the capital gains inclusion rate or the dividend gross-up
+	TaxCents     int64
+}
+
+// EffectiveRatePct is the tax as a share of the amount received.
+func (t IncomeTypeTax) EffectiveRatePct() float64 {
+	if t.AmountCents <= 0 {
+		return 0
+	}
+	return float64(t.TaxCents) / float64(t.AmountCents) * 100
+}
+
+// TaxByType is the income tax on an IncomeMix in one province, split by income type.
+type TaxByType struct {
+	TaxYear            int
+	Province           string
+	Types              []IncomeTypeTax
+	AmountCents        int64
+	TaxableCents       int64
+	FederalTaxCents    int64
+	ProvincialTaxCents int64 // including surtax
+	TaxCents           int64
+}
+
+// EffectiveRatePct is the total tax as a share of all income received.
+func (b TaxByType) EffectiveRatePct() float64 {
+	if b.AmountCents <= 0 {
+		return 0
+	}
+	return float64(b.TaxCents) / float64(b.AmountCents) * 100
+}
+
+// ComputeTaxByType works out income tax under t on mix in province. Each type's share is the
+// tax it adds on top of the types before it, stacked employment, interest, capital gains,
+// non-eligible dividends, then eligible dividends, so the shares add up to the total. Credits
+// are the basic personal amount, the Canada employment amount and the dividend tax credits;
+// payroll deductions and health premiums are left out. ok is false for an unknown province.
+func ComputeTaxByType(t TaxTables, province string, mix IncomeMix) (b TaxByType, ok bool) {
+	if _, ok := t.Provinces[province]; !ok {
+		return TaxByType{}, false
+	}
+	b.TaxYear = t.Year
+	b.Province = province
+	b.AmountCents = mix.totalCents()
+
+	var stacked IncomeMix
+	var prevTax int64
+	add := func(label string, amount int64, set func(*IncomeMix)) {
+		set(&stacked)
+		fed, prov, taxable := mixTax(t, province, stacked)
+		b.Types = append(b.Types, IncomeTypeTax{
+			Label:        label,
+			AmountCents:  amount,
+			TaxableCents: taxable - b.TaxableCents,
+			TaxCents:     fed + prov - prevTax,
+		})
+		b.FederalTaxCents, b.ProvincialTaxCents, b.TaxableCents = fed, prov, taxable
+		prevTax = fed + prov
+	}
+	add("Employment", mix.EmploymentCents, func(m *IncomeMix) { m.EmploymentCents = mix.EmploymentCents })
+	add("Interest", mix.InterestCents, func(m *IncomeMix) { m.InterestCents = mix.InterestCents })
+	add("Capital gains", mix.CapitalGainsCents, func(m *IncomeMix) { m.CapitalGainsCents = mix.CapitalGainsCents })
+	add("Non-eligible dividends", mix.NonEligibleDividendsCents, func(m *IncomeMix) { m.NonEligibleDividendsCents = mix.NonEligibleDividendsCents })
+	add("Eligible dividends", mix.EligibleDividendsCents, func(m *IncomeMix) { m.EligibleDividendsCents = mix.EligibleDividendsCents })
+	b.TaxCents = b.FederalTaxCents + b.ProvincialTaxCents
+	return b, true
+}
+
+// mixTax is the federal and provincial tax on mix, and its taxable income.
+func mixTax(t TaxTables, province string, mix IncomeMix) (federal, provincial, taxable int64) {
+	eligible := mix.EligibleDividendsCents + percentOf(mix.EligibleDividendsCents, t.EligibleGrossUpPct)
+	nonEligible := mix.NonEligibleDividendsCents + percentOf(mix.NonEligibleDividendsCents, t.NonEligibleGrossUpPct)
+	taxable = mix.EmploymentCents + mix.InterestCents + percentOf(mix.CapitalGainsCents, t.CapitalGainsInclusionPct) + eligible + nonEligible
+
+	dividendCredit := func(s TaxSchedule) int64 {
+		return percentOf(eligible, s.EligibleDividendCreditPct) + percentOf(nonEligible, s.NonEligibleDividendCreditPct)
+	}
+
+	tax, credits := scheduleTax(t.Federal, taxable, mix.EmploymentCents, 0)
+	federal = max(tax-credits-dividendCredit(t.Federal), 0)
+	if province == "QC" {
+		federal -= percentOf(federal, t.Federal.AbatementPct)
+	}
+
+	// Surtax is charged on provincial tax after the dividend credit (Ontario).
+	prov := t.Provinces[province]
+	tax, credits = scheduleTax(prov, taxable, mix.EmploymentCents, 0)
+	basic := max(tax-credits-dividendCredit(prov), 0)
+	provincial = basic + prov.surtax(basic)
+	return federal, provincial, taxable
+}
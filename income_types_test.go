@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 2025 tax on income mixes, worked by hand: each type's share is taxed at the combined marginal
+// rate where it lands, with dividends grossed up and credited.
+func TestComputeTaxByType(t *testing.T) {
+	tables := taxTables(t, 2025)
+	tests := []struct {
+		name     string
+		province string
+		mix      IncomeMix
+		want     []int64 // tax by type: employment, interest, capital gains, non-eligible, eligible
+		taxable  int64
+	}{
+		// Federal $8,857.50 - $2,552.00 credits, Ontario $3,321.67 - $643.72.
+		{"ON salary", "ON", IncomeMix{EmploymentCents: 60000_00},
+			[]int64{8983_46, 0, 0, 0, 0}, 60000_00},
+		// Interest and half the gains stack at 29.65%.
+		{"ON interest and gains", "ON", IncomeMix{EmploymentCents: 60000_00, InterestCents: 1000_00, CapitalGainsCents: 10000_00},
+			[]int64{8983_46, 296_50, 1482_50, 0, 0}, 66000_00},
+		// $13,800 grossed up at 19.55% less 25.02% in credits.
+		{"ON eligible dividends credit other income", "ON", IncomeMix{EmploymentCents: 30000_00, EligibleDividendsCents: 10000_00},
+			[]int64{2669_28, 0, 0, 0, -754_83}, 43800_00},
+		// Credits cover the whole $27,600 grossed up.
+		{"ON eligible dividends alone", "ON", IncomeMix{EligibleDividendsCents: 20000_00},
+			[]int64{0, 0, 0, 0, 0}, 27600_00},
+		// 20.5% × (1 − 16.5% abatement) + 19%.
+		{"QC interest", "QC", IncomeMix{EmploymentCents: 100000_00, InterestCents: 5000_00},
+			[]int64{25849_41, 1805_88, 0, 0, 0}, 105000_00},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ok := ComputeTaxByType(tables, tt.province, tt.mix)
+			if !ok {
+				t.Fatal("unknown province")
+			}
+			if len(b.Types) != len(tt.want) {
+				t.Fatalf("got %d types, want %d", len(b.Types), len(tt.want))
+			}
+			var sum int64
+			for i, want := range tt.want {
+				if got := b.Types[i].TaxCents; got != want {
+					t.Errorf("%s: tax %d, want %d", b.Types[i].Label, got, want)
+				}
+				sum += b.Types[i].TaxCents
+			}
+			if sum != b.TaxCents || b.TaxCents != b.FederalTaxCents+b.ProvincialTaxCents {
+				t.Errorf("shares add to %d; total %d = %d + %d", sum, b.TaxCents, b.FederalTaxCents, b.ProvincialTaxCents)
+			}
+			if b.TaxableCents != tt.taxable {
+				t.Errorf("taxable %d, want %d", b.TaxableCents, tt.taxable)
+			}
+		})
+	}
+}
+
+func TestComputeTaxByTypeEdges(t *testing.T) {
+	tables := taxTables(t, 2025)
+	if _, ok := ComputeTaxByType(tables, "XX", IncomeMix{EmploymentCents: 50000_00}); ok {
+		t.Error("ComputeTaxByType(XX) ok, want false")
+	}
+	b, _ := ComputeTaxByType(tables, "ON", IncomeMix{})
+	if b.TaxCents != 0 || b.EffectiveRatePct() != 0 {
+		t.Errorf("no income: tax %d at %.2f%%, want 0", b.TaxCents, b.EffectiveRatePct())
+	}
+	// Ontario $12,445.59 - $643.72 credits, plus 20% over $5,710 and 36% over $7,307 in surtax.
+	b, _ = ComputeTaxByType(tables, "ON", IncomeMix{EmploymentCents: 150000_00})
+	if b.ProvincialTaxCents != 14638_39 {
+		t.Errorf("provincial tax %d, want 1463839", b.ProvincialTaxCents)
+	}
+	if got := b.Types[0].EffectiveRatePct(); math.Abs(got-b.EffectiveRatePct()) > 1e-9 {
+		t.Errorf("employment rate %.4f%%, total %.4f%%", got, b.EffectiveRatePct())
+	}
+}
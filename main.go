@@ -30,6 +30,7 @@ type App struct {
 	tpl           *template.Template
 	sessionKey    string
 	csrfKey       string
+	baseURL       string // public URL for links in emails sent outside a request (BASE_URL)
 	rateLimiter   map[string][]time.Time
 	rateLimiterMu sync.RWMutex
 }
@@ -174,6 +175,19 @@ func formatDebtKind(kind string) string {
 	return kind
 }
 
+// isRevolvingKind reports whether a debt kind has a credit limit (and therefore utilization).
+func isRevolvingKind(kind string) bool {
+	return kind == "card" || kind == "line_of_credit"
+}
+
+// utilizationPct returns balance as a percentage of limit, or 0 when no limit is set.
+func utilizationPct(balanceCents, limitCents int64) float64 {
+	if limitCents <= 0 {
+		return 0
+	}
+	return float64(balanceCents) / float64(limitCents) * 100
+}
+
 func parseInt64(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
 func parseInt(s string) (int, error)     { return strconv.Atoi(s) }
 
@@ -236,6 +250,9 @@ func main() {
 	if err := migrate(db); err != nil {
 		log.Fatal(err)
 	}
+	if err := loadTaxTables(); err != nil {
+		log.Fatalf("Tax tables: %v", err)
+	}
 
 	var tpl *template.Template
 	tpl = template.New("")
@@ -244,10 +261,14 @@ func main() {
 		"apr":      bpsToAPR,
 		"mul":      func(a, b int64) int64 { return a * b },
 		"sub":      func(a, b int64) int64 { return a - b },
+		"add":      func(a, b int) int { return a + b },
 		"div":      func(a, b float64) float64 { return a / b },
 		"pct":      func(spent, limit int64) int64 { if limit == 0 { return 0 }; return spent * 100 / limit },
 		"float":    func(i int64) float64 { return float64(i) },
 		"debtKind": formatDebtKind,
+		"payFrequency": formatPayFrequency,
+		"revolving": isRevolvingKind,
+		"utilization": utilizationPct,
 		"now":      func() time.Time { return time.Now() },
 		"date":     func(format string, t time.Time) string { return t.Format(format) },
 		"getDebt": func(debtMap map[int64]Debt, id int64) Debt {
@@ -321,6 +342,7 @@ func main() {
 		tpl:         tpl,
 		sessionKey:  loadOrCreateKey("SESSION_KEY", env),
 		csrfKey:     loadOrCreateKey("CSRF_KEY", env),
+		baseURL:     strings.TrimRight(getEnv("BASE_URL", env), "/"),
 		rateLimiter: make(map[string][]time.Time),
 	}
 
@@ -337,6 +359,8 @@ func main() {
 	mux.HandleFunc("/forgot-password", app.rateLimit(3, 1*time.Hour)(app.handleForgotPassword))
 	mux.HandleFunc("/reset-password", app.rateLimit(5, 15*time.Minute)(app.handleResetPassword))
 	mux.HandleFunc("/logout", app.handleLogout)
+	mux.HandleFunc("/reminders/unsubscribe", app.handleReminderUnsubscribe)
+	mux.HandleFunc("/calendar/", app.handleCalendarFeed)
 	mux.HandleFunc("/", app.requireAuth(app.handleIndex))
 	mux.HandleFunc("/debts/new", app.requireAuth(app.handleDebtNew))
 	mux.HandleFunc("/debts/create", app.requireAuth(app.requireCSRF(app.handleDebtCreate)))
@@ -350,12 +374,45 @@ func main() {
 	mux.HandleFunc("/payments/edit", app.requireAuth(app.handlePaymentEdit))
 	mux.HandleFunc("/payments/update", app.requireAuth(app.requireCSRF(app.handlePaymentUpdate)))
 	mux.HandleFunc("/payments/delete", app.requireAuth(app.requireCSRF(app.handlePaymentDelete)))
+	mux.HandleFunc("/payments/confirm", app.requireAuth(app.requireCSRF(app.handlePaymentConfirm)))
+	mux.HandleFunc("/payments/recurring/create", app.requireAuth(app.requireCSRF(app.handleRecurringCreate)))
+	mux.HandleFunc("/payments/recurring/toggle", app.requireAuth(app.requireCSRF(app.handleRecurringToggle)))
+	mux.HandleFunc("/payments/recurring/delete", app.requireAuth(app.requireCSRF(app.handleRecurringDelete)))
+	mux.HandleFunc("/payments/import", app.requireAuth(app.handlePaymentImport))
+	mux.HandleFunc("/payments/import/map", app.requireAuth(app.limitUpload(app.requireCSRF(app.handlePaymentImportMap))))
+	mux.HandleFunc("/payments/import/preview", app.requireAuth(app.requireCSRF(app.handlePaymentImportPreview)))
+	mux.HandleFunc("/payments/import/commit", app.requireAuth(app.requireCSRF(app.handlePaymentImportCommit)))
 	mux.HandleFunc("/payments", app.requireAuth(app.handlePayments))
+	mux.HandleFunc("/import/statement", app.requireAuth(app.handleStatementImport))
+	mux.HandleFunc("/import/statement/review", app.requireAuth(app.limitUpload(app.requireCSRF(app.handleStatementReview))))
+	mux.HandleFunc("/import/statement/commit", app.requireAuth(app.requireCSRF(app.handleStatementCommit)))
+	mux.HandleFunc("/import/rules/delete", app.requireAuth(app.requireCSRF(app.handlePayeeRuleDelete)))
 	mux.HandleFunc("/plan", app.requireAuth(app.handlePlan))
+	mux.HandleFunc("/plan/save", app.requireAuth(app.requireCSRF(app.handlePlanSave)))
+	mux.HandleFunc("/plan/windfalls/create", app.requireAuth(app.requireCSRF(app.handleWindfallCreate)))
+	mux.HandleFunc("/plan/windfalls/delete", app.requireAuth(app.requireCSRF(app.handleWindfallDelete)))
 	mux.HandleFunc("/tax-brackets", app.requireAuth(app.handleTaxBrackets))
+	mux.HandleFunc("/net-pay", app.requireAuth(app.handleNetPay))
+	mux.HandleFunc("/tax-by-type", app.requireAuth(app.handleTaxByType))
+	mux.HandleFunc("/settings", app.requireAuth(app.handleSettings))
+	mux.HandleFunc("/settings/reminders", app.requireAuth(app.requireCSRF(app.handleReminderSettingsUpdate)))
+	mux.HandleFunc("/settings/budget", app.requireAuth(app.requireCSRF(app.handleBudgetSettingsUpdate)))
+	mux.HandleFunc("/settings/salary", app.requireAuth(app.requireCSRF(app.handleSalarySettingsUpdate)))
+	mux.HandleFunc("/settings/calendar/reset", app.requireAuth(app.requireCSRF(app.handleCalendarFeedReset)))
+	mux.HandleFunc("/settings/calendar/revoke", app.requireAuth(app.requireCSRF(app.handleCalendarFeedRevoke)))
+	mux.HandleFunc("/export", app.requireAuth(app.handleExport))
+	mux.HandleFunc("/export/debts.csv", app.requireAuth(app.handleExportDebts))
+	mux.HandleFunc("/export/payments.csv", app.requireAuth(app.handleExportPayments))
+	mux.HandleFunc("/export/budget.csv", app.requireAuth(app.handleExportBudget))
+	mux.HandleFunc("/export/expenses.csv", app.requireAuth(app.handleExportExpenses))
 	mux.HandleFunc("/budget", app.requireAuth(app.handleBudgetList))
 	mux.HandleFunc("/budget/view", app.requireAuth(app.handleBudgetView))
 	mux.HandleFunc("/budget/update", app.requireAuth(app.requireCSRF(app.handleBudgetUpdate)))
+	mux.HandleFunc("/budget/income/salary", app.requireAuth(app.requireCSRF(app.handleBudgetUseSalary)))
+	mux.HandleFunc("/budget/copy", app.requireAuth(app.requireCSRF(app.handleBudgetCopy)))
+	mux.HandleFunc("/budget/zero-based", app.requireAuth(app.requireCSRF(app.handleBudgetZeroBased)))
+	mux.HandleFunc("/budget/assign-remainder", app.requireAuth(app.requireCSRF(app.handleBudgetAssignRemainder)))
+	mux.HandleFunc("/budget/close", app.requireAuth(app.requireCSRF(app.handleBudgetClose)))
 	mux.HandleFunc("/budget/category/add", app.requireAuth(app.handleBudgetCategoryAdd))
 	mux.HandleFunc("/budget/category/create", app.requireAuth(app.requireCSRF(app.handleBudgetCategoryCreate)))
 	mux.HandleFunc("/budget/category/edit", app.requireAuth(app.handleBudgetCategoryEdit))
@@ -367,6 +424,40 @@ func main() {
 	mux.HandleFunc("/budget/expense/edit", app.requireAuth(app.handleBudgetExpenseEdit))
 	mux.HandleFunc("/budget/expense/update", app.requireAuth(app.requireCSRF(app.handleBudgetExpenseUpdate)))
 	mux.HandleFunc("/budget/expense/delete", app.requireAuth(app.requireCSRF(app.handleBudgetExpenseDelete)))
+	mux.HandleFunc("/budget/expense/quick", app.requireAuth(app.requireCSRF(app.handleBudgetExpenseQuick)))
+	mux.HandleFunc("/budget/rules", app.requireAuth(app.handleCategoryRules))
+	mux.HandleFunc("/budget/rules/create", app.requireAuth(app.requireCSRF(app.handleCategoryRuleCreate)))
+	mux.HandleFunc("/budget/rules/delete", app.requireAuth(app.requireCSRF(app.handleCategoryRuleDelete)))
+	mux.HandleFunc("/income", app.requireAuth(app.handleIncome))
+	mux.HandleFunc("/income/sources/create", app.requireAuth(app.requireCSRF(app.handleIncomeSourceCreate)))
+	mux.HandleFunc("/income/sources/update", app.requireAuth(app.requireCSRF(app.handleIncomeSourceUpdate)))
+	mux.HandleFunc("/income/sources/delete", app.requireAuth(app.requireCSRF(app.handleIncomeSourceDelete)))
+	mux.HandleFunc("/income/receipts/create", app.requireAuth(app.requireCSRF(app.handleIncomeReceiptCreate)))
+	mux.HandleFunc("/income/receipts/delete", app.requireAuth(app.requireCSRF(app.handleIncomeReceiptDelete)))
+	mux.HandleFunc("/budget/reports", app.requireAuth(app.handleBudgetReport))
+	mux.HandleFunc("/budget/reports.json", app.requireAuth(app.handleBudgetReportJSON))
+	mux.HandleFunc("/budget/reports.csv", app.requireAuth(app.handleBudgetReportCSV))
+	mux.HandleFunc("/budget/alerts/dismiss", app.requireAuth(app.requireCSRF(app.handleBudgetAlertDismiss)))
+	mux.HandleFunc("/budget/split/new", app.requireAuth(app.handleExpenseSplitNew))
+	mux.HandleFunc("/budget/split/create", app.requireAuth(app.requireCSRF(app.handleExpenseSplitCreate)))
+	mux.HandleFunc("/budget/split/edit", app.requireAuth(app.handleExpenseSplitEdit))
+	mux.HandleFunc("/budget/split/update", app.requireAuth(app.requireCSRF(app.handleExpenseSplitUpdate)))
+	mux.HandleFunc("/budget/split/delete", app.requireAuth(app.requireCSRF(app.handleExpenseSplitDelete)))
+	mux.HandleFunc("/budget/transactions", app.requireAuth(app.handleTransactions))
+	mux.HandleFunc("/budget/transactions/recategorize", app.requireAuth(app.requireCSRF(app.handleTransactionRecategorize)))
+	mux.HandleFunc("/budget/transactions/delete", app.requireAuth(app.requireCSRF(app.handleTransactionsBulkDelete)))
+	mux.HandleFunc("/budget/year", app.requireAuth(app.handleBudgetYear))
+	mux.HandleFunc("/budget/year/plan/save", app.requireAuth(app.requireCSRF(app.handleAnnualPlanSave)))
+	mux.HandleFunc("/budget/year/plan/delete", app.requireAuth(app.requireCSRF(app.handleAnnualPlanDelete)))
+	mux.HandleFunc("/budget/templates", app.requireAuth(app.handleBudgetTemplates))
+	mux.HandleFunc("/budget/templates/create", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateCreate)))
+	mux.HandleFunc("/budget/templates/view", app.requireAuth(app.handleBudgetTemplateView))
+	mux.HandleFunc("/budget/templates/rename", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateRename)))
+	mux.HandleFunc("/budget/templates/delete", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateDelete)))
+	mux.HandleFunc("/budget/templates/category/add", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateCategoryAdd)))
+	mux.HandleFunc("/budget/templates/category/update", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateCategoryUpdate)))
+	mux.HandleFunc("/budget/templates/category/delete", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateCategoryDelete)))
+	mux.HandleFunc("/budget/templates/apply", app.requireAuth(app.requireCSRF(app.handleBudgetTemplateApply)))
 
 	// HTTPS support - check for TLS cert files
 	certFile := getEnv("TLS_CERT_FILE", env)
@@ -383,6 +474,13 @@ func main() {
 
 	addr := bind + ":" + port
 
+	if app.baseURL == "" {
+		app.baseURL = "http://localhost:" + port
+	}
+
+	// Background jobs (recurring payments, reminders)
+	app.startScheduler()
+
 	if certFile != "" && keyFile != "" {
 		log.Printf("Starting HTTPS server on :%s", addr)
 		log.Fatal(http.ListenAndServeTLS(addr, certFile, keyFile, mux))
@@ -469,6 +567,22 @@ func (a *App) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// maxUploadRequestBytes leaves room for the other form fields sent with an uploaded file.
+const maxUploadRequestBytes = maxImportBytes + 64<<10
+
+// limitUpload caps an upload's request body before anything parses it (requireCSRF reads the
+// form), so an oversized file is refused instead of being read in full.
+func (a *App) limitUpload(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxUploadRequestBytes {
+			http.Error(w, "That file is too large (2 MB maximum).", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadRequestBytes)
+		next(w, r)
+	}
+}
+
 func (a *App) requireCSRF(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" || r.Method == "HEAD" {
@@ -583,6 +697,41 @@ func getBaseURL(r *http.Request) string {
 }
 
 func (a *App) sendPasswordResetEmail(to, resetURL string) error {
+	subject := "Password Reset - Debt Manager"
+	body := fmt.Sprintf(`Hello,
+
+You requested a password reset for your Debt Manager account.
+
+Click the link below to reset your password:
+%s
+
+This link will expire in 1 hour.
+
+If you didn't request this, please ignore this email.
+
+--
+Debt Manager`, resetURL)
+
+	if !smtpConfigured() {
+		log.Printf("SMTP not configured. Password reset link for %s: %s", to, resetURL)
+		return nil
+	}
+	return sendEmail(to, subject, body)
+}
+
+// smtpConfigured reports whether SMTP_HOST, SMTP_USER and SMTP_PASS (or SMTP_PASSWORD) are set.
+func smtpConfigured() bool {
+	env := loadEnvFile()
+	smtpPass := getEnv("SMTP_PASS", env)
+	if smtpPass == "" {
+		smtpPass = getEnv("SMTP_PASSWORD", env)
+	}
+	return getEnv("SMTP_HOST", env) != "" && getEnv("SMTP_USER", env) != "" && smtpPass != ""
+}
+
+// sendEmail sends a plain-text email using the SMTP settings from .env / environment.
+// If SMTP is not configured, the message is logged instead.
+func sendEmail(to, subject, body string) error {
 	env := loadEnvFile()
 	smtpHost := getEnv("SMTP_HOST", env)
 	smtpPort := getEnv("SMTP_PORT", env)
@@ -600,9 +749,9 @@ func (a *App) sendPasswordResetEmail(to, resetURL string) error {
 	}
 	fromName := strings.Trim(strings.TrimSpace(getEnv("FROM_EMAIL_NAME", env)), `"`)
 
-	// If SMTP not configured, log the link instead
+	// If SMTP not configured, log the message instead
 	if smtpHost == "" || smtpUser == "" || smtpPass == "" {
-		log.Printf("SMTP not configured. Password reset link for %s: %s", to, resetURL)
+		log.Printf("SMTP not configured. Email to %s (%s):\n%s", to, subject, body)
 		return nil
 	}
 
@@ -616,21 +765,6 @@ func (a *App) sendPasswordResetEmail(to, resetURL string) error {
 		fromHeader = fmt.Sprintf("%q <%s>", fromName, fromAddr)
 	}
 
-	subject := "Password Reset - Debt Manager"
-	body := fmt.Sprintf(`Hello,
-
-You requested a password reset for your Debt Manager account.
-
-Click the link below to reset your password:
-%s
-
-This link will expire in 1 hour.
-
-If you didn't request this, please ignore this email.
-
---
-Debt Manager`, resetURL)
-
 	msg := []byte(fmt.Sprintf("To: %s\r\n", to) +
 		fmt.Sprintf("From: %s\r\n", fromHeader) +
 		fmt.Sprintf("Subject: %s\r\n", subject) +
@@ -0,0 +1,263 @@
+package main
+
+import "math"
+
+// TaxSchedule is one jurisdiction's income tax on its own (not combined with another level).
+// Non-refundable credits are worth the lowest bracket rate.
+type TaxSchedule struct {
+	Brackets           []TaxBracket
+	BasicPersonalCents int64
+	// The basic personal amount drops linearly to BasicPersonalMinCents as net income goes
+	// from PhaseOutFromCents to PhaseOutToCents. Zero PhaseOutToCents means no phase-out.
+	BasicPersonalMinCents int64
+	PhaseOutFromCents     int64
+	PhaseOutToCents       int64
+	EmploymentAmountCents int64   // Canada employment amount (federal and Yukon)
+	PayrollCredits        bool    // CPP/QPP base contributions and EI/QPIP premiums are credits
+	AbatementPct          float64 // federal only: the Quebec abatement
+	// Dividend tax credits, as a percent of the grossed-up dividend
+	EligibleDividendCreditPct    float64
+	NonEligibleDividendCreditPct float64
+	Surtax                       []SurtaxTier
+	HealthPremium                []PremiumTier
+}
+
+// SurtaxTier adds RatePct of the basic provincial tax above OverCents (Ontario).
+type SurtaxTier struct {
+	OverCents int64
+	RatePct   float64
+}
+
+// PremiumTier is one step of a health premium (Ontario): above FromCents of taxable income
+// the premium grows at RatePct from the previous tier's maximum, up to MaxCents.
+type PremiumTier struct {
+	FromCents int64
+	RatePct   float64
+	MaxCents  int64
+}
+
+// PayrollRates are the year's CPP/QPP, EI and QPIP parameters.
+type PayrollRates struct {
+	YMPECents                 int64 // maximum pensionable earnings
+	BasicExemptionCents       int64
+	CPPBaseRatePct            float64
+	CPPFirstAdditionalRatePct float64
+	QPPBaseRatePct            float64
+	QPPFirstAdditionalRatePct float64
+	YAMPECents                int64 // second earnings ceiling for CPP2/QPP2
+	SecondAdditionalRatePct   float64
+	EIMaxInsurableCents       int64
+	EIRatePct                 float64
+	EIQuebecRatePct           float64
+	QPIPMaxInsurableCents     int64
+	QPIPRatePct               float64
+}
+
+// regularPayFrequencies lists the pay frequencies with a fixed number of paycheques, in form order.
+var regularPayFrequencies = []string{"weekly", "biweekly", "semimonthly", "monthly"}
+
+// payPeriodsPerYear maps the regular pay frequencies to paycheques a year.
+var payPeriodsPerYear = map[string]int{
+	"weekly":      52,
+	"biweekly":    26,
+	"semimonthly": 24,
+	"monthly":     12,
+}
+
+// NetPay is a year of employment income after payroll deductions and income tax.
+type NetPay struct {
+	TaxYear      int
+	Province     string
+	GrossCents   int64
+	PensionLabel string // "CPP" or "QPP"
+
+	PensionBaseCents       int64 // base contribution, a non-refundable credit
+	PensionAdditionalCents int64 // first additional contribution, a deduction from income
+	PensionSecondCents     int64 // CPP2/QPP2, a deduction from income
+	EICents                int64
+	QPIPCents              int64
+	TaxableCents           int64
+
+	FederalBeforeCreditsCents    int64
+	FederalCreditsCents          int64
+	FederalAbatementCents        int64
+	FederalTaxCents              int64
+	ProvincialBeforeCreditsCents int64
+	ProvincialCreditsCents       int64
+	SurtaxCents                  int64
+	ProvincialTaxCents           int64 // including surtax
+	HealthPremiumCents           int64
+
+	PayrollCents int64 // CPP/QPP, EI and QPIP together
+	TaxCents     int64 // federal, provincial and health premium together
+	NetCents     int64
+	MonthlyCents int64
+	PayPeriods   int
+	PerPayCents  int64
+}
+
+// EffectiveRatePct is the share of gross pay that goes to payroll deductions and tax.
+func (n NetPay) EffectiveRatePct() float64 {
+	if n.GrossCents <= 0 {
+		return 0
+	}
+	return float64(n.GrossCents-n.NetCents) / float64(n.GrossCents) * 100
+}
+
+// percentOf returns pct percent of cents, rounded to the cent.
+func percentOf(cents int64, pct float64) int64 {
+	return int64(math.Round(float64(cents) * pct / 100))
+}
+
+// bracketTax is the tax on incomeCents over marginal brackets.
+func bracketTax(brackets []TaxBracket, incomeCents int64) int64 {
+	var tax, prev int64
+	for _, b := range brackets {
+		if incomeCents <= prev {
+			break
+		}
+		top := b.MaxCents
+		if incomeCents < top {
+			top = incomeCents
+		}
+		tax += percentOf(top-prev, b.RatePct)
+		prev = b.MaxCents
+	}
+	return tax
+}
+
+// basicPersonal is the basic personal amount for the given net income, after any phase-out.
+func (s TaxSchedule) basicPersonal(netIncomeCents int64) int64 {
+	if s.PhaseOutToCents <= s.PhaseOutFromCents || netIncomeCents <= s.PhaseOutFromCents {
+		return s.BasicPersonalCents
+	}
+	if netIncomeCents >= s.PhaseOutToCents {
+		return s.BasicPersonalMinCents
+	}
+	reduction := float64(s.BasicPersonalCents-s.BasicPersonalMinCents) *
+		float64(netIncomeCents-s.PhaseOutFromCents) / float64(s.PhaseOutToCents-s.PhaseOutFromCents)
+	return s.BasicPersonalCents - int64(math.Round(reduction))
+}
+
+// creditRatePct is the rate non-refundable credits are worth: the lowest bracket rate.
+func (s TaxSchedule) creditRatePct() float64 {
+	if len(s.Brackets) == 0 {
+		return 0
+	}
+	return s.Brackets[0].RatePct
+}
+
+func (s TaxSchedule) surtax(basicTaxCents int64) int64 {
+	var out int64
+	for _, t := range s.Surtax {
+		if basicTaxCents > t.OverCents {
+			out += percentOf(basicTaxCents-t.OverCents, t.RatePct)
+		}
+	}
+	return out
+}
+
+func (s TaxSchedule) healthPremium(taxableCents int64) int64 {
+	var premium, prevMax int64
+	for _, t := range s.HealthPremium {
+		if taxableCents <= t.FromCents {
+			break
+		}
+		premium = prevMax + percentOf(taxableCents-t.FromCents, t.RatePct)
+		if premium > t.MaxCents {
+			premium = t.MaxCents
+		}
+		prevMax = t.MaxCents
+	}
+	return premium
+}
+
+// ComputeNetPay estimates take-home pay under t on a year's employment income in province,
+// paid on the given frequency. Credits covered are the basic personal amount, CPP/QPP base
+// contributions, EI/QPIP premiums and the Canada employment amount; other deductions and
+// credits are left out. ok is false for an unknown province.
+func ComputeNetPay(t TaxTables, province string, grossCents int64, frequency string) (n NetPay, ok bool) {
+	return computeNetPay(t, province, grossCents, 0, frequency)
+}
+
+// computeNetPay is ComputeNetPay with deductionCents, such as an RRSP contribution, taken off
+// taxable income.
+func computeNetPay(t TaxTables, province string, grossCents, deductionCents int64, frequency string) (n NetPay, ok bool) {
+	prov, ok := t.Provinces[province]
+	if !ok {
+		return NetPay{}, false
+	}
+	if grossCents < 0 {
+		grossCents = 0
+	}
+	p := t.Payroll
+	n.TaxYear = t.Year
+	quebec := province == "QC"
+	n.Province = province
+	n.GrossCents = grossCents
+
+	// CPP/QPP: base and first additional rates between the basic exemption and the YMPE, then
+	// the second additional rate up to the YAMPE.
+	baseRate, additionalRate, eiRate := p.CPPBaseRatePct, p.CPPFirstAdditionalRatePct, p.EIRatePct
+	n.PensionLabel = "CPP"
+	if quebec {
+		baseRate, additionalRate, eiRate = p.QPPBaseRatePct, p.QPPFirstAdditionalRatePct, p.EIQuebecRatePct
+		n.PensionLabel = "QPP"
+	}
+	pensionable := min(grossCents, p.YMPECents) - p.BasicExemptionCents
+	if pensionable > 0 {
+		n.PensionBaseCents = percentOf(pensionable, baseRate)
+		n.PensionAdditionalCents = percentOf(pensionable, additionalRate)
+	}
+	if grossCents > p.YMPECents {
+		n.PensionSecondCents = percentOf(min(grossCents, p.YAMPECents)-p.YMPECents, p.SecondAdditionalRatePct)
+	}
+	n.EICents = percentOf(min(grossCents, p.EIMaxInsurableCents), eiRate)
+	if quebec {
+		n.QPIPCents = percentOf(min(grossCents, p.QPIPMaxInsurableCents), p.QPIPRatePct)
+	}
+	n.PayrollCents = n.PensionBaseCents + n.PensionAdditionalCents + n.PensionSecondCents + n.EICents + n.QPIPCents
+
+	// Enhanced contributions are deducted from income rather than credited.
+	n.TaxableCents = max(grossCents-n.PensionAdditionalCents-n.PensionSecondCents-max(deductionCents, 0), 0)
+	payrollCredits := n.PensionBaseCents + n.EICents + n.QPIPCents
+
+	n.FederalBeforeCreditsCents, n.FederalCreditsCents = scheduleTax(t.Federal, n.TaxableCents, grossCents, payrollCredits)
+	n.FederalTaxCents = max(n.FederalBeforeCreditsCents-n.FederalCreditsCents, 0)
+	if quebec {
+		n.FederalAbatementCents = percentOf(n.FederalTaxCents, t.Federal.AbatementPct)
+		n.FederalTaxCents -= n.FederalAbatementCents
+	}
+
+	n.ProvincialBeforeCreditsCents, n.ProvincialCreditsCents = scheduleTax(prov, n.TaxableCents, grossCents, payrollCredits)
+	basic := max(n.ProvincialBeforeCreditsCents-n.ProvincialCreditsCents, 0)
+	n.SurtaxCents = prov.surtax(basic)
+	n.ProvincialTaxCents = basic + n.SurtaxCents
+	n.HealthPremiumCents = prov.healthPremium(n.TaxableCents)
+
+	n.TaxCents = n.FederalTaxCents + n.ProvincialTaxCents + n.HealthPremiumCents
+	n.NetCents = grossCents - n.PayrollCents - n.TaxCents
+	n.MonthlyCents = n.NetCents / 12
+	if periods, ok := payPeriodsPerYear[frequency]; ok {
+		n.PayPeriods = periods
+		n.PerPayCents = n.NetCents / int64(periods)
+	}
+	return n, true
+}
+
+// scheduleTax returns the bracket tax on taxable income and the value of the non-refundable
+// credits against it.
+func scheduleTax(s TaxSchedule, taxableCents, employmentCents, payrollCredits int64) (tax, credits int64) {
+	tax = bracketTax(s.Brackets, taxableCents)
+	amounts := s.basicPersonal(taxableCents) + min(employmentCents, s.EmploymentAmountCents)
+	if s.PayrollCredits {
+		amounts += payrollCredits
+	}
+	return tax, percentOf(amounts, s.creditRatePct())
+}
+
+// salaryNetPay is the take-home pay on a saved salary under the tax tables for year.
+func salaryNetPay(p SalaryProfile, year int) NetPay {
+	n, _ := ComputeNetPay(taxTablesFor(year), p.Province, p.GrossCents, p.PayFrequency)
+	return n
+}
@@ -0,0 +1,150 @@
+package main
+
+import "testing"
+
+// taxTables loads the embedded tax data and returns year's tables.
+func taxTables(t *testing.T, year int) TaxTables {
+	t.Helper()
+	if err := loadTaxTables(); err != nil {
+		t.Fatal(err)
+	}
+	tables, ok := taxYears[year]
+	if !ok {
+		t.Fatalf("no tax tables for %d", year)
+	}
+	return tables
+}
+
+// The 2025 maximum contributions and premiums published by the CRA, Retraite Québec and the
+// QPIP: CPP $4,034.10 (base $3,356.10 + first additional $678.00) and CPP2 $396.00, EI $1,077.48
+// ($860.67 in Quebec), QPP $4,339.20 (base $3,661.20 + $678.00) and QPIP $484.12.
+func TestComputeNetPayPayrollMaximums(t *testing.T) {
+	tables := taxTables(t, 2025)
+	tests := []struct {
+		province                         string
+		label                            string
+		base, additional, second, ei, qp int64
+	}{
+		{"ON", "CPP", 3356_10, 678_00, 396_00, 1077_48, 0},
+		{"BC", "CPP", 3356_10, 678_00, 396_00, 1077_48, 0},
+		{"QC", "QPP", 3661_20, 678_00, 396_00, 860_67, 484_12},
+	}
+	for _, tt := range tests {
+		n, ok := ComputeNetPay(tables, tt.province, 150000_00, "monthly")
+		if !ok {
+			t.Fatalf("%s: unknown province", tt.province)
+		}
+		if n.PensionLabel != tt.label || n.PensionBaseCents != tt.base || n.PensionAdditionalCents != tt.additional ||
+			n.PensionSecondCents != tt.second || n.EICents != tt.ei || n.QPIPCents != tt.qp {
+			t.Errorf("%s: got %s %d + %d + %d, EI %d, QPIP %d; want %s %d + %d + %d, EI %d, QPIP %d", tt.province,
+				n.PensionLabel, n.PensionBaseCents, n.PensionAdditionalCents, n.PensionSecondCents, n.EICents, n.QPIPCents,
+				tt.label, tt.base, tt.additional, tt.second, tt.ei, tt.qp)
+		}
+		if n.PayrollCents != tt.base+tt.additional+tt.second+tt.ei+tt.qp {
+			t.Errorf("%s: payroll %d is not the sum of its parts", tt.province, n.PayrollCents)
+		}
+	}
+}
+
+func TestComputeNetPay(t *testing.T) {
+	tables := taxTables(t, 2025)
+	tests := []struct {
+		name     string
+		province string
+		gross    int64
+		want     NetPay
+	}{
+		// $60,000 in Ontario. CPP (60,000 - 3,500) x 4.95% = 2,796.75 and x 1% = 565.00, EI 1.64%
+		// = 984.00; taxable income 59,435.
+		// Federal: 57,375 x 14.5% + 2,060 x 20.5% = 8,741.68, less 14.5% of (16,129 BPA + 1,471
+		// employment + 3,780.75 CPP/EI) = 3,100.21, so 5,641.47.
+		// Ontario: 52,886 x 5.05% + 6,549 x 9.15% = 3,269.97, less 5.05% of (12,747 + 3,780.75) =
+		// 834.65, so 2,435.32 with no surtax; health premium $600.
+		{"Ontario $60,000", "ON", 60000_00, NetPay{
+			TaxableCents:              59435_00,
+			FederalBeforeCreditsCents: 8741_68, FederalCreditsCents: 3100_21, FederalTaxCents: 5641_47,
+			ProvincialBeforeCreditsCents: 3269_97, ProvincialCreditsCents: 834_65, ProvincialTaxCents: 2435_32,
+			HealthPremiumCents: 600_00,
+			PayrollCents:       4345_75, TaxCents: 8676_79, NetCents: 46977_46,
+		}},
+		// $150,000 in Ontario: basic Ontario tax 11,458.11 takes both surtax tiers, 20% over
+		// 5,710 and 36% over 7,307 = 2,644.02; health premium $750.
+		{"Ontario $150,000", "ON", 150000_00, NetPay{
+			TaxableCents:              148926_00,
+			FederalBeforeCreditsCents: 28967_02, FederalCreditsCents: 3194_87, FederalTaxCents: 25772_15,
+			ProvincialBeforeCreditsCents: 12325_73, ProvincialCreditsCents: 867_62, SurtaxCents: 2644_02, ProvincialTaxCents: 14102_13,
+			HealthPremiumCents: 750_00,
+			PayrollCents:       5507_58, TaxCents: 40624_28, NetCents: 103868_14,
+		}},
+		// $150,000 in Quebec: federal tax is cut by the 16.5% abatement (25,689.15 x 16.5% =
+		// 4,238.71); Quebec credits are the basic personal amount only, at 14%.
+		{"Quebec $150,000", "QC", 150000_00, NetPay{
+			TaxableCents:              148926_00,
+			FederalBeforeCreditsCents: 28967_02, FederalCreditsCents: 3277_87, FederalAbatementCents: 4238_71, FederalTaxCents: 21450_44,
+			ProvincialBeforeCreditsCents: 28093_12, ProvincialCreditsCents: 2599_94, ProvincialTaxCents: 25493_18,
+			PayrollCents: 6079_99, TaxCents: 46943_62, NetCents: 96976_39,
+		}},
+		// Below the basic personal amounts there is no income tax, only payroll deductions: CPP
+		// 569.25 + 115.00 and EI 246.00. Credits are 14.5% of 18,415.25 federally and 8% of
+		// 23,138.25 in Alberta, more than the tax on 14,885.
+		{"Alberta $15,000", "AB", 15000_00, NetPay{
+			TaxableCents:              14885_00,
+			FederalBeforeCreditsCents: 2158_33, FederalCreditsCents: 2670_21,
+			ProvincialBeforeCreditsCents: 1190_80, ProvincialCreditsCents: 1851_06,
+			PayrollCents: 930_25, NetCents: 14069_75,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := ComputeNetPay(tables, tt.province, tt.gross, "biweekly")
+			if !ok {
+				t.Fatal("unknown province")
+			}
+			w := tt.want
+			check := func(field string, got, want int64) {
+				if got != want {
+					t.Errorf("%s = %d, want %d", field, got, want)
+				}
+			}
+			check("TaxableCents", n.TaxableCents, w.TaxableCents)
+			check("FederalBeforeCreditsCents", n.FederalBeforeCreditsCents, w.FederalBeforeCreditsCents)
+			check("FederalCreditsCents", n.FederalCreditsCents, w.FederalCreditsCents)
+			check("FederalAbatementCents", n.FederalAbatementCents, w.FederalAbatementCents)
+			check("FederalTaxCents", n.FederalTaxCents, w.FederalTaxCents)
+			check("ProvincialBeforeCreditsCents", n.ProvincialBeforeCreditsCents, w.ProvincialBeforeCreditsCents)
+			check("ProvincialCreditsCents", n.ProvincialCreditsCents, w.ProvincialCreditsCents)
+			check("SurtaxCents", n.SurtaxCents, w.SurtaxCents)
+			check("ProvincialTaxCents", n.ProvincialTaxCents, w.ProvincialTaxCents)
+			check("HealthPremiumCents", n.HealthPremiumCents, w.HealthPremiumCents)
+			check("PayrollCents", n.PayrollCents, w.PayrollCents)
+			check("TaxCents", n.TaxCents, w.TaxCents)
+			check("NetCents", n.NetCents, w.NetCents)
+			check("MonthlyCents", n.MonthlyCents, w.NetCents/12)
+			check("PerPayCents", n.PerPayCents, w.NetCents/26)
+		})
+	}
+}
+
+func TestComputeNetPayEdges(t *testing.T) {
+	tables := taxTables(t, 2025)
+	if _, ok := ComputeNetPay(tables, "XX", 50000_00, "monthly"); ok {
+		t.Error("expected an unknown province to fail")
+	}
+	n, _ := ComputeNetPay(tables, "ON", -100, "irregular")
+	if n.GrossCents != 0 || n.NetCents != 0 || n.TaxCents != 0 || n.PayPeriods != 0 {
+		t.Errorf("negative gross: got %+v, want all zero", n)
+	}
+	// The federal basic personal amount phases out from 16,129 to 14,538 between 177,882 and
+	// 253,414 of net income.
+	fed := tables.Federal
+	for _, tt := range []struct{ net, want int64 }{
+		{177882_00, 16129_00},
+		{215648_00, 15333_50},
+		{253414_00, 14538_00},
+		{400000_00, 14538_00},
+	} {
+		if got := fed.basicPersonal(tt.net); got != tt.want {
+			t.Errorf("basicPersonal(%d) = %d, want %d", tt.net, got, tt.want)
+		}
+	}
+}
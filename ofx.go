@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OFXTransaction is one STMTTRN from an OFX/QFX statement. AmountCents is signed as in the
+// file: negative for money leaving the account (or a charge on a card), positive for money
+// coming in (or a payment or refund on a card).
+type OFXTransaction struct {
+	AccountID   string
+	CreditCard  bool // from a credit card statement rather than a bank account
+	FITID       string
+	Type        string
+	PostedOn    time.Time
+	AmountCents int64
+	Name        string
+	Memo        string
+}
+
+// PaymentCents is the amount the transaction pays toward d. On a statement for the debt's own
+// account, or any card statement, payments are credits (the lender received them); on a bank
+// statement they are debits (money sent to the lender). Charges, draws, deposits and zero
+// amounts pay nothing.
+func (t OFXTransaction) PaymentCents(d Debt) int64 {
+	if t.CreditCard || t.onAccount(d) {
+		return max(t.AmountCents, 0)
+	}
+	return max(-t.AmountCents, 0)
+}
+
+// onAccount reports whether the statement account is d's, going by its account number ending.
+func (t OFXTransaction) onAccount(d Debt) bool {
+	ref := strings.ToUpper(alnum(d.AccountRef))
+	return ref != "" && strings.HasSuffix(strings.ToUpper(alnum(t.AccountID)), ref)
+}
+
+// ExpenseCents is the amount spent: debits only. Refunds, deposits and other credits are not
+// spending.
+func (t OFXTransaction) ExpenseCents() int64 {
+	return max(-t.AmountCents, 0)
+}
+
+// Payee is the text rules match against: the payee name, falling back to the memo.
+func (t OFXTransaction) Payee() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Memo
+}
+
+// Description combines name and memo for payment and expense notes.
+func (t OFXTransaction) Description() string {
+	if t.Memo == "" || strings.EqualFold(t.Memo, t.Name) {
+		return t.Payee()
+	}
+	if t.Name == "" {
+		return t.Memo
+	}
+	return t.Name + " - " + t.Memo
+}
+
+// parseOFX extracts transactions from OFX 1.x (SGML, closing tags optional) or OFX 2.x (XML)
+// data. QFX is OFX with extra Intuit tags, which are ignored. Both formats are read as a flat
+// stream of <TAG>value tokens, which avoids needing a real SGML parser.
+func parseOFX(data []byte) ([]OFXTransaction, error) {
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return nil, fmt.Errorf("not an OFX/QFX file")
+	}
+	var (
+		out        []OFXTransaction
+		account    string
+		creditCard bool
+		cur        *OFXTransaction
+		inTxn      bool
+	)
+	for _, tok := range strings.Split(string(data[start:]), "<")[1:] {
+		tag, value, ok := strings.Cut(tok, ">")
+		if !ok {
+			continue
+		}
+		tag = strings.ToUpper(strings.TrimSpace(tag))
+		value = html.UnescapeString(strings.TrimSpace(value))
+		switch tag {
+		case "STMTRS":
+			creditCard = false
+		case "CCSTMTRS":
+			creditCard = true
+		case "STMTTRN":
+			inTxn = true
+			cur = &OFXTransaction{AccountID: account, CreditCard: creditCard}
+		case "/STMTTRN":
+			if cur != nil {
+				if cur.FITID == "" || cur.PostedOn.IsZero() {
+					return nil, fmt.Errorf("transaction without FITID or date")
+				}
+				out = append(out, *cur)
+			}
+			inTxn, cur = false, nil
+		case "ACCTID":
+			// Transfers carry a BANKACCTTO inside the transaction; only the statement's own account counts
+			if !inTxn {
+				account = value
+			}
+		}
+		if cur == nil || value == "" {
+			continue
+		}
+		switch tag {
+		case "FITID":
+			cur.FITID = value
+		case "TRNTYPE":
+			cur.Type = value
+		case "DTPOSTED":
+			d, err := parseOFXDate(value)
+			if err != nil {
+				return nil, err
+			}
+			cur.PostedOn = d
+		case "TRNAMT":
+			amt, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad amount %q", value)
+			}
+			cur.AmountCents = int64(math.Round(amt * 100))
+		case "NAME", "PAYEE":
+			cur.Name = value
+		case "MEMO":
+			cur.Memo = value
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no transactions found")
+	}
+	return out, nil
+}
+
+// parseOFXDate reads the date part of YYYYMMDD[HHMMSS[.XXX][[offset:TZ]]].
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("bad date %q", s)
+	}
+	d, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date %q", s)
+	}
+	return d, nil
+}
+
+// StatementCandidate is a statement transaction on the review screen. Target is the form value
+// of the suggested destination: "skip", "debt:<id>" or "cat:<category name>".
+type StatementCandidate struct {
+	Index    int
+	Txn      OFXTransaction
+	Target   string
+	Reason   string // why Target was suggested, for display
+	Imported bool   // FITID already imported from this account
+}
+
+// alnum keeps letters and digits so "****-1234" matches "1234".
+func alnum(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// suggestStatementTarget picks where a transaction should go, in order: a payee rule; an expense
+// categorization rule (debits only); a credit to an account linked to a debt (a payment received
+// by the lender); a debit whose payee names a debt (a payment sent from a bank account); otherwise skip.
+func suggestStatementTarget(t OFXTransaction, debts []Debt, rules []PayeeRule, catRules []CategoryRule) (target, reason string) {
+	payee := strings.ToLower(t.Description())
+	for _, rule := range rules {
+		if !strings.Contains(payee, strings.ToLower(rule.Pattern)) {
+			continue
+		}
+		if !rule.DebtID.Valid {
+			if t.ExpenseCents() > 0 {
+				return "cat:" + rule.CategoryName, "Rule: " + rule.Pattern
+			}
+			continue
+		}
+		for _, d := range debts {
+			if d.ID == rule.DebtID.Int64 && t.PaymentCents(d) > 0 {
+				return fmt.Sprintf("debt:%d", d.ID), "Rule: " + rule.Pattern
+			}
+		}
+	}
+	if t.AmountCents < 0 {
+		if cr, ok := matchCategoryRule(catRules, t.Description(), -t.AmountCents); ok {
+			return "cat:" + cr.CategoryName, "Category rule: " + cr.Pattern
+		}
+	}
+	for _, d := range debts {
+		if !d.Active || !t.onAccount(d) {
+			continue
+		}
+		if t.AmountCents > 0 {
+			return fmt.Sprintf("debt:%d", d.ID), "Account " + d.AccountRef
+		}
+		return "skip", "Charge on " + html.UnescapeString(d.Name)
+	}
+	if t.AmountCents < 0 && !t.CreditCard {
+		// Longest name wins so "TD Visa" beats "Visa"
+		var best *Debt
+		for i, d := range debts {
+			name := strings.ToLower(html.UnescapeString(d.Name))
+			if d.Active && len(name) >= 3 && strings.Contains(payee, name) && (best == nil || len(d.Name) > len(best.Name)) {
+				best = &debts[i]
+			}
+		}
+		if best != nil {
+			return fmt.Sprintf("debt:%d", best.ID), "Payee mentions " + html.UnescapeString(best.Name)
+		}
+	}
+	return "skip", ""
+}
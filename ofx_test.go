@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const bankOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>CAD
+<BANKACCTFROM><BANKID>004<ACCTID>0012345678<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250305120000[-5:EST]<TRNAMT>-42.17<FITID>A1<NAME>GROCER &amp; CO<MEMO>POS purchase</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250306<TRNAMT>15.00<FITID>A2<NAME>GROCER &amp; CO<MEMO>Refund</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250307<TRNAMT>0.00<FITID>A3<NAME>Card check</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+const cardOFX = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+    <CCACCTFROM><ACCTID>4500********1234</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250310</DTPOSTED><TRNAMT>-80.00</TRNAMT><FITID>C1</FITID><NAME>GAS STATION</NAME></STMTTRN>
+      <STMTTRN><TRNTYPE>CREDIT</TRNTYPE><DTPOSTED>20250312</DTPOSTED><TRNAMT>250.00</TRNAMT><FITID>C2</FITID><NAME>PAYMENT - THANK YOU</NAME></STMTTRN>
+    </BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []OFXTransaction
+	}{
+		{"bank SGML", bankOFX, []OFXTransaction{
+			{AccountID: "0012345678", FITID: "A1", Type: "DEBIT", PostedOn: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), AmountCents: -42_17, Name: "GROCER & CO", Memo: "POS purchase"},
+			{AccountID: "0012345678", FITID: "A2", Type: "CREDIT", PostedOn: time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC), AmountCents: 15_00, Name: "GROCER & CO", Memo: "Refund"},
+			{AccountID: "0012345678", FITID: "A3", Type: "DEBIT", PostedOn: time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC), AmountCents: 0, Name: "Card check"},
+		}},
+		{"card XML", cardOFX, []OFXTransaction{
+			{AccountID: "4500********1234", CreditCard: true, FITID: "C1", Type: "DEBIT", PostedOn: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), AmountCents: -80_00, Name: "GAS STATION"},
+			{AccountID: "4500********1234", CreditCard: true, FITID: "C2", Type: "CREDIT", PostedOn: time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), AmountCents: 250_00, Name: "PAYMENT - THANK YOU"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOFX([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("transaction %d:\n got %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"not OFX":         "date,amount\n2025-03-01,10.00\n",
+		"no transactions": "<OFX><BANKTRANLIST></BANKTRANLIST></OFX>",
+		"missing FITID":   "<OFX><STMTTRN><DTPOSTED>20250301<TRNAMT>-1.00</STMTTRN></OFX>",
+		"bad amount":      "<OFX><STMTTRN><DTPOSTED>20250301<TRNAMT>ten<FITID>X</STMTTRN></OFX>",
+	} {
+		if _, err := parseOFX([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestStatementAmounts(t *testing.T) {
+	visa := Debt{ID: 1, AccountRef: "1234"}
+	loan := Debt{ID: 2}
+	tests := []struct {
+		name        string
+		txn         OFXTransaction
+		debt        Debt
+		wantPayment int64
+		wantExpense int64
+	}{
+		{"bank debit pays a debt", OFXTransaction{AccountID: "0012345678", AmountCents: -300_00}, loan, 300_00, 300_00},
+		{"bank refund is neither", OFXTransaction{AccountID: "0012345678", AmountCents: 15_00}, loan, 0, 0},
+		{"card payment received", OFXTransaction{AccountID: "4500********1234", CreditCard: true, AmountCents: 250_00}, visa, 250_00, 0},
+		{"card charge is spending", OFXTransaction{AccountID: "4500********1234", CreditCard: true, AmountCents: -80_00}, visa, 0, 80_00},
+		{"credit on the debt's account", OFXTransaction{AccountID: "LOC-1234", AmountCents: 500_00}, visa, 500_00, 0},
+		{"draw on the debt's account", OFXTransaction{AccountID: "LOC-1234", AmountCents: -500_00}, visa, 0, 500_00},
+		{"zero", OFXTransaction{AccountID: "0012345678"}, loan, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.txn.PaymentCents(tt.debt); got != tt.wantPayment {
+				t.Errorf("PaymentCents = %d, want %d", got, tt.wantPayment)
+			}
+			if got := tt.txn.ExpenseCents(); got != tt.wantExpense {
+				t.Errorf("ExpenseCents = %d, want %d", got, tt.wantExpense)
+			}
+		})
+	}
+}
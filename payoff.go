@@ -3,19 +3,51 @@ package main
 import (
 	"math"
 	"sort"
+	"time"
 )
 
 type Strategy string
 
 const (
-	Snowball  Strategy = "snowball"  // smallest balance first
-	Avalanche Strategy = "avalanche" // highest APR first
+	Snowball    Strategy = "snowball"    // smallest balance first
+	Avalanche   Strategy = "avalanche"   // highest APR first
+	Utilization Strategy = "utilization" // revolving debts under a utilization target first, then avalanche
 )
 
+// PlanOptions holds optional inputs for GeneratePlanWithOptions.
+type PlanOptions struct {
+	// UtilizationTargetPct is the per-debt utilization (e.g. 30 for 30%) that the
+	// Utilization strategy pays every revolving debt down to before anything else.
+	UtilizationTargetPct float64
+	// Windfalls are one-time lump sums (a tax refund, a bonus) added to the monthly budget in
+	// the plan month they arrive.
+	Windfalls []PlanWindfall
+}
+
+// PlanWindfall is a lump sum available in plan month MonthIndex (1 is the first plan month).
+type PlanWindfall struct {
+	MonthIndex  int
+	AmountCents int64
+}
+
+// planWindfalls places saved windfalls in plan months counted from now's month, which is plan
+// month 1. Windfalls expected in earlier months are left out.
+func planWindfalls(windfalls []Windfall, now time.Time) []PlanWindfall {
+	var out []PlanWindfall
+	for _, w := range windfalls {
+		m := (w.ExpectedOn.Year()-now.Year())*12 + int(w.ExpectedOn.Month()) - int(now.Month()) + 1
+		if m >= 1 {
+			out = append(out, PlanWindfall{MonthIndex: m, AmountCents: w.AmountCents})
+		}
+	}
+	return out
+}
+
 type PlanMonth struct {
 	MonthIndex     int
 	InterestCents  int64
 	Payments       map[int64]int64 // debtID -> paid cents this month
+	WindfallCents  int64           // lump sums added to this month's budget
 	Balances       map[int64]int64 // end-of-month balances
 	TotalPaidCents int64
 }
@@ -36,6 +68,10 @@ func roundToCents(x float64) int64 {
 }
 
 func GeneratePlan(debts []Debt, monthlyBudgetCents int64, strategy Strategy, maxMonths int) PlanResult {
+	return GeneratePlanWithOptions(debts, monthlyBudgetCents, strategy, maxMonths, PlanOptions{})
+}
+
+func GeneratePlanWithOptions(debts []Debt, monthlyBudgetCents int64, strategy Strategy, maxMonths int, opts PlanOptions) PlanResult {
 	// Filter active with positive balance
 	active := make([]Debt, 0, len(debts))
 	for _, d := range debts {
@@ -75,6 +111,33 @@ func GeneratePlan(debts []Debt, monthlyBudgetCents int64, strategy Strategy, max
 		return cp
 	}
 
+	// overTarget returns revolving debts above the utilization target, highest APR first,
+	// with the amount each needs to drop to reach the target.
+	overTarget := func() ([]Debt, map[int64]int64) {
+		var cp []Debt
+		excess := map[int64]int64{}
+		if strategy != Utilization || opts.UtilizationTargetPct <= 0 {
+			return cp, excess
+		}
+		for _, d := range active {
+			if !isRevolvingKind(d.Kind) || d.CreditLimitCents <= 0 {
+				continue
+			}
+			target := int64(float64(d.CreditLimitCents) * opts.UtilizationTargetPct / 100.0)
+			if bal[d.ID] > target {
+				cp = append(cp, d)
+				excess[d.ID] = bal[d.ID] - target
+			}
+		}
+		sort.Slice(cp, func(i, j int) bool {
+			if cp[i].APRBps == cp[j].APRBps {
+				return excess[cp[i].ID] < excess[cp[j].ID]
+			}
+			return cp[i].APRBps > cp[j].APRBps
+		})
+		return cp, excess
+	}
+
 	var res PlanResult
 	for m := 1; m <= maxMonths; m++ {
 		// Check done
@@ -114,8 +177,13 @@ func GeneratePlan(debts []Debt, monthlyBudgetCents int64, strategy Strategy, max
 		month.InterestCents = monthInterest
 		res.TotalInterestCents += monthInterest
 
-		// 2) Pay minimums
-		remaining := monthlyBudgetCents
+		// 2) Pay minimums, from the budget plus any windfall arriving this month
+		for _, w := range opts.Windfalls {
+			if w.MonthIndex == m {
+				month.WindfallCents += w.AmountCents
+			}
+		}
+		remaining := monthlyBudgetCents + month.WindfallCents
 		for _, d := range active {
 			if bal[d.ID] <= 0 {
 				continue
@@ -135,7 +203,23 @@ func GeneratePlan(debts []Debt, monthlyBudgetCents int64, strategy Strategy, max
 			}
 		}
 
-		// 3) Apply remaining to target debt by strategy, looping as debts are paid off
+		// 3) Utilization strategy: bring each revolving debt down to the target first
+		over, excess := overTarget()
+		for _, d := range over {
+			if remaining <= 0 {
+				break
+			}
+			pay := excess[d.ID]
+			if pay > remaining {
+				pay = remaining
+			}
+			bal[d.ID] -= pay
+			month.Payments[d.ID] += pay
+			month.TotalPaidCents += pay
+			remaining -= pay
+		}
+
+		// 4) Apply remaining to target debt by strategy, looping as debts are paid off
 		for remaining > 0 {
 			order := pickOrder()
 			if len(order) == 0 {
@@ -165,3 +249,42 @@ func GeneratePlan(debts []Debt, monthlyBudgetCents int64, strategy Strategy, max
 	res.PayoffMonths = maxMonths
 	return res
 }
+
+// ProjectedUtilization returns overall revolving utilization (percent) at the end of each
+// plan month, across active revolving debts with a credit limit. Nil if there are none.
+func ProjectedUtilization(debts []Debt, plan PlanResult) []float64 {
+	var limit int64
+	for _, d := range debts {
+		if d.Active && isRevolvingKind(d.Kind) && d.CreditLimitCents > 0 {
+			limit += d.CreditLimitCents
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	out := make([]float64, 0, len(plan.Months))
+	for _, m := range plan.Months {
+		var bal int64
+		for _, d := range debts {
+			if d.Active && isRevolvingKind(d.Kind) && d.CreditLimitCents > 0 {
+				bal += m.Balances[d.ID] // debts not in the plan have zero balance
+			}
+		}
+		out = append(out, utilizationPct(bal, limit))
+	}
+	return out
+}
+
+// PayoffMonthByDebt returns, for each debt in the plan, the first month index whose
+// end balance is zero. Debts not paid off within the plan are omitted.
+func PayoffMonthByDebt(plan PlanResult) map[int64]int {
+	out := map[int64]int{}
+	for _, m := range plan.Months {
+		for id, b := range m.Balances {
+			if _, done := out[id]; !done && b <= 0 {
+				out[id] = m.MonthIndex
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeneratePlanUtilization(t *testing.T) {
+	// A 20% loan and a 10% card at 50% of its limit. First-month interest is 166.67 on the
+	// loan and 41.67 on the card, so the card needs 2,041.67 to get down to 30%.
+	debts := []Debt{
+		{ID: 1, Kind: "personal_loan", BalanceCents: 10000_00, APRBps: 2000, Active: true},
+		{ID: 2, Kind: "card", BalanceCents: 5000_00, APRBps: 1000, CreditLimitCents: 10000_00, Active: true},
+	}
+	tests := []struct {
+		name      string
+		strategy  Strategy
+		targetPct float64
+		budget    int64
+		want      map[int64]int64 // first-month payments by debt
+	}{
+		{"avalanche pays the loan", Avalanche, 30, 1000_00, map[int64]int64{1: 1000_00}},
+		{"utilization pays the card", Utilization, 30, 1000_00, map[int64]int64{2: 1000_00}},
+		{"utilization stops at the target", Utilization, 30, 3000_00, map[int64]int64{1: 958_33, 2: 2041_67}},
+		{"no target falls back to avalanche", Utilization, 0, 1000_00, map[int64]int64{1: 1000_00}},
+		{"card already under target", Utilization, 60, 1000_00, map[int64]int64{1: 1000_00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := GeneratePlanWithOptions(debts, tt.budget, tt.strategy, 1, PlanOptions{UtilizationTargetPct: tt.targetPct})
+			if len(plan.Months) != 1 {
+				t.Fatalf("got %d months, want 1", len(plan.Months))
+			}
+			got := plan.Months[0].Payments
+			if len(got) != len(tt.want) {
+				t.Fatalf("payments = %v, want %v", got, tt.want)
+			}
+			for id, cents := range tt.want {
+				if got[id] != cents {
+					t.Errorf("debt %d paid %d, want %d", id, got[id], cents)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePlanWindfalls(t *testing.T) {
+	// $1,000 at 0% with $100 a month takes 10 months without windfalls.
+	debts := []Debt{{ID: 1, Kind: "personal_loan", BalanceCents: 1000_00, MinPaymentCents: 50_00, Active: true}}
+	tests := []struct {
+		name       string
+		windfalls  []PlanWindfall
+		wantMonths int
+		wantPaid   []int64 // total paid each month
+	}{
+		{"none", nil, 10, nil},
+		{"month three", []PlanWindfall{{3, 500_00}}, 5, []int64{100_00, 100_00, 600_00, 100_00, 100_00}},
+		{"same month adds up", []PlanWindfall{{1, 200_00}, {1, 300_00}}, 5, []int64{600_00, 100_00, 100_00, 100_00, 100_00}},
+		{"capped at the balance", []PlanWindfall{{2, 5000_00}}, 2, []int64{100_00, 900_00}},
+		{"after the horizon", []PlanWindfall{{20, 500_00}}, 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := GeneratePlanWithOptions(debts, 100_00, Avalanche, 12, PlanOptions{Windfalls: tt.windfalls})
+			if plan.PayoffMonths != tt.wantMonths {
+				t.Errorf("paid off in %d months, want %d", plan.PayoffMonths, tt.wantMonths)
+			}
+			for i, want := range tt.wantPaid {
+				if i >= len(plan.Months) {
+					break
+				}
+				if got := plan.Months[i].TotalPaidCents; got != want {
+					t.Errorf("month %d: paid %d, want %d", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanWindfalls(t *testing.T) {
+	now := time.Date(2025, 11, 15, 0, 0, 0, 0, time.UTC)
+	windfalls := []Windfall{
+		{Label: "last month", AmountCents: 100_00, ExpectedOn: date(t, "2025-10-31")},
+		{Label: "this month", AmountCents: 200_00, ExpectedOn: date(t, "2025-11-01")},
+		{Label: "refund", AmountCents: 300_00, ExpectedOn: date(t, "2026-04-30")},
+	}
+	got := planWindfalls(windfalls, now)
+	want := []PlanWindfall{{1, 200_00}, {6, 300_00}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("windfall %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestProjectedUtilization(t *testing.T) {
+	debts := []Debt{
+		{ID: 1, Kind: "card", BalanceCents: 3000_00, CreditLimitCents: 10000_00, Active: true},
+		{ID: 2, Kind: "line_of_credit", BalanceCents: 1000_00, CreditLimitCents: 10000_00, Active: true},
+		{ID: 3, Kind: "auto_loan", BalanceCents: 9000_00, Active: true},
+	}
+	plan := PlanResult{Months: []PlanMonth{
+		{MonthIndex: 1, Balances: map[int64]int64{1: 2000_00, 2: 1000_00, 3: 8000_00}},
+		{MonthIndex: 2, Balances: map[int64]int64{1: 0, 3: 7000_00}},
+	}}
+	got := ProjectedUtilization(debts, plan)
+	want := []float64{15, 0}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("month %d: got %v%%, want %v%%", i+1, got[i], want[i])
+		}
+	}
+	if ProjectedUtilization(debts[2:], plan) != nil {
+		t.Error("expected nil without revolving debts")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// registerPageSize is how many rows the transactions register shows per page.
+const registerPageSize = 50
+
+// sortRegister orders register rows by sort: date_desc (the default), date_asc, amount_*,
+// category_* or note_*. Ties fall back to newest first and then to expense and split ID, so rows
+// never swap places or move between pages.
+func sortRegister(rows []RegisterRow, by string) {
+	newest := func(a, b RegisterRow) bool {
+		if !a.SpentOn.Equal(b.SpentOn) {
+			return a.SpentOn.After(b.SpentOn)
+		}
+		if a.ExpenseID != b.ExpenseID {
+			return a.ExpenseID > b.ExpenseID
+		}
+		return a.SplitID > b.SplitID
+	}
+	byText := func(key func(RegisterRow) string, asc bool) func(a, b RegisterRow) bool {
+		return func(a, b RegisterRow) bool {
+			if ka, kb := strings.ToLower(key(a)), strings.ToLower(key(b)); ka != kb {
+				return (ka < kb) == asc
+			}
+			return newest(a, b)
+		}
+	}
+	less := newest
+	switch by {
+	case "date_asc":
+		less = func(a, b RegisterRow) bool { return newest(b, a) }
+	case "amount_asc", "amount_desc":
+		asc := by == "amount_asc"
+		less = func(a, b RegisterRow) bool {
+			if a.AmountCents != b.AmountCents {
+				return (a.AmountCents < b.AmountCents) == asc
+			}
+			return newest(a, b)
+		}
+	case "category_asc", "category_desc":
+		less = byText(func(r RegisterRow) string { return r.CategoryName }, by == "category_asc")
+	case "note_asc", "note_desc":
+		less = byText(func(r RegisterRow) string { return r.Note }, by == "note_asc")
+	}
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+}
+
+// registerPage sets each sorted row's RunningCents, the total of that row and every row above
+// it on earlier pages too, and returns the rows of page (1-based).
+func registerPage(rows []RegisterRow, page int) []RegisterRow {
+	var running int64
+	for i := range rows {
+		running += rows[i].AmountCents
+		rows[i].RunningCents = running
+	}
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * registerPageSize
+	if start >= len(rows) {
+		return nil
+	}
+	return rows[start:min(start+registerPageSize, len(rows))]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func registerIDs(rows []RegisterRow) string {
+	s := ""
+	for _, r := range rows {
+		if r.SplitID > 0 {
+			s += fmt.Sprintf("s%d ", r.SplitID)
+		} else {
+			s += fmt.Sprintf("e%d ", r.ExpenseID)
+		}
+	}
+	return s
+}
+
+func TestSortRegister(t *testing.T) {
+	rows := func() []RegisterRow {
+		return []RegisterRow{
+			{ExpenseID: 1, SpentOn: date(t, "2025-03-02"), AmountCents: 20_00, CategoryName: "Groceries", Note: "milk"},
+			{ExpenseID: 2, SpentOn: date(t, "2025-03-05"), AmountCents: 20_00, CategoryName: "dining", Note: "Lunch"},
+			{SplitID: 2, SpentOn: date(t, "2025-03-05"), AmountCents: 90_00, CategoryName: "Groceries, Household", Note: "market"},
+			{ExpenseID: 3, SpentOn: date(t, "2025-03-05"), AmountCents: 5_00, CategoryName: "Groceries", Note: "milk"},
+			{ExpenseID: 4, SpentOn: date(t, "2025-03-01"), AmountCents: 20_00, CategoryName: "Dining", Note: ""},
+		}
+	}
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"", "e3 e2 s2 e1 e4 "},
+		{"date_desc", "e3 e2 s2 e1 e4 "},
+		{"bogus", "e3 e2 s2 e1 e4 "},
+		{"date_asc", "e4 e1 s2 e2 e3 "},
+		// Equal amounts fall back to newest first
+		{"amount_asc", "e3 e2 e1 e4 s2 "},
+		{"amount_desc", "s2 e2 e1 e4 e3 "},
+		// Names compare ignoring case
+		{"category_asc", "e2 e4 e3 e1 s2 "},
+		{"category_desc", "s2 e3 e1 e2 e4 "},
+		{"note_asc", "e4 e2 s2 e3 e1 "},
+		{"note_desc", "e3 e1 s2 e2 e4 "},
+	}
+	for _, tt := range tests {
+		r := rows()
+		sortRegister(r, tt.sort)
+		if got := registerIDs(r); got != tt.want {
+			t.Errorf("sort %q: got %s, want %s", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterPage(t *testing.T) {
+	rows := make([]RegisterRow, registerPageSize+2)
+	for i := range rows {
+		rows[i] = RegisterRow{ExpenseID: int64(i + 1), AmountCents: int64(i + 1)}
+	}
+	first := registerPage(rows, 1)
+	if len(first) != registerPageSize || first[0].RunningCents != 1 || first[2].RunningCents != 6 {
+		t.Errorf("page 1: got %d rows starting %+v", len(first), first[:3])
+	}
+	// The second page's running total carries on from the first
+	second := registerPage(rows, 2)
+	n := int64(registerPageSize + 1)
+	if len(second) != 2 || second[0].RunningCents != n*(n+1)/2 || second[1].RunningCents != (n+1)*(n+2)/2 {
+		t.Errorf("page 2: got %+v", second)
+	}
+	if got := registerPage(rows, 0); len(got) != registerPageSize || got[0].ExpenseID != 1 {
+		t.Errorf("page 0: got %d rows, want page 1", len(got))
+	}
+	if got := registerPage(rows, 3); got != nil {
+		t.Errorf("page 3: got %d rows, want none", len(got))
+	}
+	if got := registerPage(nil, 1); got != nil {
+		t.Errorf("empty register: got %+v", got)
+	}
+}
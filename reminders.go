@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+)
+
+// nextDueDate returns the next date (today or later) falling on dueDay.
+func nextDueDate(dueDay int, today time.Time) time.Time {
+	d := time.Date(today.Year(), today.Month(), dueDay, 0, 0, 0, 0, time.UTC)
+	if d.Before(time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)) {
+		d = d.AddDate(0, 1, 0)
+	}
+	return d
+}
+
+// expectedPaymentCents is the amount we expect for a debt's next payment: the planned
+// payment if set, otherwise the minimum, capped at the balance.
+func expectedPaymentCents(d Debt) int64 {
+	amount := d.PaymentCents
+	if amount <= 0 {
+		amount = d.MinPaymentCents
+	}
+	if amount > d.BalanceCents {
+		amount = d.BalanceCents
+	}
+	return amount
+}
+
+type upcomingPayment struct {
+	Debt    Debt
+	DueDate time.Time
+	Amount  int64
+}
+
+// sendDueReminders emails each opted-in user a digest of active debts due within their
+// reminder window that have not been reminded yet. Returns the number of emails sent.
+func (a *App) sendDueReminders(now time.Time) (int, error) {
+	recipients, err := listReminderRecipients(a.db)
+	if err != nil {
+		return 0, err
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	sent := 0
+	for _, rr := range recipients {
+		debts, err := listDebts(a.db, rr.UserID)
+		if err != nil {
+			log.Printf("Reminders: listing debts for user %d: %v", rr.UserID, err)
+			continue
+		}
+		var upcoming []upcomingPayment
+		for _, d := range debts {
+			if !d.Active || d.BalanceCents <= 0 {
+				continue
+			}
+			due := nextDueDate(d.DueDay, today)
+			if due.Sub(today) > time.Duration(rr.DaysBefore)*24*time.Hour {
+				continue
+			}
+			claimed, err := claimReminder(a.db, d.ID, due)
+			if err != nil {
+				log.Printf("Reminders: claiming debt %d: %v", d.ID, err)
+				continue
+			}
+			if claimed {
+				upcoming = append(upcoming, upcomingPayment{Debt: d, DueDate: due, Amount: expectedPaymentCents(d)})
+			}
+		}
+		if len(upcoming) == 0 {
+			continue
+		}
+		subject, body := reminderEmail(upcoming, a.baseURL+"/reminders/unsubscribe?token="+rr.UnsubscribeToken)
+		if err := sendEmail(rr.Email, subject, body); err != nil {
+			log.Printf("Reminders: sending to user %d: %v", rr.UserID, err)
+			// Release claims so the next run retries
+			for _, u := range upcoming {
+				if err := releaseReminder(a.db, u.Debt.ID, u.DueDate); err != nil {
+					log.Printf("Reminders: releasing debt %d: %v", u.Debt.ID, err)
+				}
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func reminderEmail(upcoming []upcomingPayment, unsubscribeURL string) (subject, body string) {
+	var total int64
+	var lines strings.Builder
+	for _, u := range upcoming {
+		total += u.Amount
+		fmt.Fprintf(&lines, "- %s: %s due %s\n", html.UnescapeString(u.Debt.Name), money(u.Amount), u.DueDate.Format("Mon Jan 2"))
+	}
+	if len(upcoming) == 1 {
+		subject = fmt.Sprintf("Payment reminder: %s due %s - Debt Manager", html.UnescapeString(upcoming[0].Debt.Name), upcoming[0].DueDate.Format("Jan 2"))
+	} else {
+		subject = fmt.Sprintf("Payment reminder: %d payments due soon - Debt Manager", len(upcoming))
+	}
+	body = fmt.Sprintf(`Hello,
+
+These payments are coming up:
+
+%s
+Total: %s
+
+Amounts are your planned payment, or the minimum if no planned payment is set.
+
+To stop these reminders, visit:
+%s
+
+--
+Debt Manager`, lines.String(), money(total), unsubscribeURL)
+	return subject, body
+}
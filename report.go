@@ -0,0 +1,101 @@
+package main
+
+// maxReportMonths caps the range of the budget reports.
+const maxReportMonths = 36
+
+// ReportCell is one category in one month; Present is false when that month's budget had no
+// category of that name.
+type ReportCell struct {
+	LimitCents int64 `json:"limit_cents"`
+	SpentCents int64 `json:"spent_cents"`
+	Present    bool  `json:"present"`
+}
+
+// CategoryTrend is a category (matched by name across months) over the report range. Averages
+// are over the months the category was present.
+type CategoryTrend struct {
+	Name            string       `json:"name"`
+	Cells           []ReportCell `json:"months"`
+	MonthsPresent   int          `json:"months_present"`
+	TotalLimitCents int64        `json:"total_limit_cents"`
+	TotalSpentCents int64        `json:"total_spent_cents"`
+	VarianceCents   int64        `json:"variance_cents"` // limit - spent; negative means overspent
+	AvgLimitCents   int64        `json:"avg_limit_cents"`
+	AvgSpentCents   int64        `json:"avg_spent_cents"`
+}
+
+// ReportMonth is one month's income against spending and debt payments.
+type ReportMonth struct {
+	Year              int   `json:"year"`
+	Month             int   `json:"month"`
+	IncomeCents       int64 `json:"income_cents"`
+	SpentCents        int64 `json:"spent_cents"`
+	DebtPaymentsCents int64 `json:"debt_payments_cents"`
+	LeftoverCents     int64 `json:"leftover_cents"` // income - spending - debt payments
+}
+
+type BudgetReport struct {
+	Months     []ReportMonth   `json:"months"`
+	Categories []CategoryTrend `json:"categories"`
+}
+
+// ymSplit turns year*12+month back into year and month.
+func ymSplit(ym int) (year, month int) {
+	return (ym - 1) / 12, (ym-1)%12 + 1
+}
+
+// buildBudgetReport lays actuals and totals out over every month from fromYM to toYM (inclusive,
+// as year*12+month). Categories keep the order they first appear in.
+func buildBudgetReport(fromYM, toYM int, actuals []CategoryActual, totals []MonthTotal) BudgetReport {
+	n := toYM - fromYM + 1
+	var report BudgetReport
+	for ym := fromYM; ym <= toYM; ym++ {
+		year, month := ymSplit(ym)
+		report.Months = append(report.Months, ReportMonth{Year: year, Month: month})
+	}
+	for _, t := range totals {
+		i := t.Year*12 + t.Month - fromYM
+		if i < 0 || i >= n {
+			continue
+		}
+		m := &report.Months[i]
+		m.IncomeCents, m.SpentCents, m.DebtPaymentsCents = t.IncomeCents, t.SpentCents, t.DebtPaymentsCents
+		m.LeftoverCents = t.IncomeCents - t.SpentCents - t.DebtPaymentsCents
+	}
+
+	index := map[string]int{}
+	for _, a := range actuals {
+		i := a.Year*12 + a.Month - fromYM
+		if i < 0 || i >= n {
+			continue
+		}
+		key := envelopeKey(a.Name)
+		ci, ok := index[key]
+		if !ok {
+			ci = len(report.Categories)
+			index[key] = ci
+			report.Categories = append(report.Categories, CategoryTrend{Name: a.Name, Cells: make([]ReportCell, n)})
+		}
+		cell := &report.Categories[ci].Cells[i]
+		cell.LimitCents += a.LimitCents
+		cell.SpentCents += a.SpentCents
+		cell.Present = true
+	}
+	for ci := range report.Categories {
+		c := &report.Categories[ci]
+		for _, cell := range c.Cells {
+			if !cell.Present {
+				continue
+			}
+			c.MonthsPresent++
+			c.TotalLimitCents += cell.LimitCents
+			c.TotalSpentCents += cell.SpentCents
+		}
+		c.VarianceCents = c.TotalLimitCents - c.TotalSpentCents
+		if c.MonthsPresent > 0 {
+			c.AvgLimitCents = c.TotalLimitCents / int64(c.MonthsPresent)
+			c.AvgSpentCents = c.TotalSpentCents / int64(c.MonthsPresent)
+		}
+	}
+	return report
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestYMSplit(t *testing.T) {
+	for _, tt := range []struct{ year, month int }{{2025, 1}, {2025, 12}, {2024, 6}} {
+		if y, m := ymSplit(tt.year*12 + tt.month); y != tt.year || m != tt.month {
+			t.Errorf("ymSplit(%d-%02d) = %d-%02d", tt.year, tt.month, y, m)
+		}
+	}
+}
+
+func TestBuildBudgetReport(t *testing.T) {
+	from, to := 2025*12+1, 2025*12+3
+	actuals := []CategoryActual{
+		{Year: 2024, Month: 12, Name: "Groceries", LimitCents: 900_00, SpentCents: 900_00}, // before the range
+		{Year: 2025, Month: 1, Name: "Groceries", LimitCents: 500_00, SpentCents: 450_00},
+		{Year: 2025, Month: 2, Name: "Dining", LimitCents: 200_00, SpentCents: 100_00},
+		{Year: 2025, Month: 2, Name: "DINING", LimitCents: 50_00, SpentCents: 20_00},
+		{Year: 2025, Month: 3, Name: " groceries", LimitCents: 600_00, SpentCents: 700_00},
+	}
+	totals := []MonthTotal{
+		{Year: 2025, Month: 2, IncomeCents: 4000_00, SpentCents: 3000_00, DebtPaymentsCents: 500_00},
+		{Year: 2025, Month: 4, IncomeCents: 9999_00}, // after the range
+	}
+	report := buildBudgetReport(from, to, actuals, totals)
+
+	if len(report.Months) != 3 {
+		t.Fatalf("got %d months, want 3", len(report.Months))
+	}
+	for i, m := range report.Months {
+		if m.Year != 2025 || m.Month != i+1 {
+			t.Errorf("month %d is %d-%02d", i, m.Year, m.Month)
+		}
+	}
+	if m := report.Months[0]; m.IncomeCents != 0 || m.LeftoverCents != 0 {
+		t.Errorf("January: got %+v, want an empty month", m)
+	}
+	if m := report.Months[1]; m.IncomeCents != 4000_00 || m.SpentCents != 3000_00 || m.DebtPaymentsCents != 500_00 || m.LeftoverCents != 500_00 {
+		t.Errorf("February: got %+v", m)
+	}
+
+	want := []CategoryTrend{
+		{Name: "Groceries", MonthsPresent: 2, TotalLimitCents: 1100_00, TotalSpentCents: 1150_00, VarianceCents: -50_00, AvgLimitCents: 550_00, AvgSpentCents: 575_00},
+		{Name: "Dining", MonthsPresent: 1, TotalLimitCents: 250_00, TotalSpentCents: 120_00, VarianceCents: 130_00, AvgLimitCents: 250_00, AvgSpentCents: 120_00},
+	}
+	if len(report.Categories) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(report.Categories), len(want))
+	}
+	for i, w := range want {
+		got := report.Categories[i]
+		if got.Name != w.Name || got.MonthsPresent != w.MonthsPresent || got.TotalLimitCents != w.TotalLimitCents ||
+			got.TotalSpentCents != w.TotalSpentCents || got.VarianceCents != w.VarianceCents ||
+			got.AvgLimitCents != w.AvgLimitCents || got.AvgSpentCents != w.AvgSpentCents {
+			t.Errorf("category %d: got %+v, want %+v", i, got, w)
+		}
+		if len(got.Cells) != 3 {
+			t.Errorf("%s: got %d cells, want 3", got.Name, len(got.Cells))
+		}
+	}
+	groceries := report.Categories[0].Cells
+	if !groceries[0].Present || groceries[1].Present || !groceries[2].Present {
+		t.Errorf("groceries cells: %+v", groceries)
+	}
+	if dining := report.Categories[1].Cells[1]; dining.LimitCents != 250_00 || dining.SpentCents != 120_00 {
+		t.Errorf("dining in February: got %+v, want both categories added up", dining)
+	}
+}
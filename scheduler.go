@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// schedulerInterval is how often background jobs (recurring payments, missed-payment checks, reminders) run.
+const schedulerInterval = 1 * time.Hour
+
+// startScheduler runs background jobs once at startup and then every schedulerInterval.
+// Jobs are idempotent, so a restart (or an overlapping run) never double-posts.
+func (a *App) startScheduler() {
+	go func() {
+		for {
+			a.runScheduledJobs(time.Now())
+			time.Sleep(schedulerInterval)
+		}
+	}()
+}
+
+func (a *App) runScheduledJobs(now time.Time) {
+	if n, err := a.postDueRecurringPayments(now); err != nil {
+		log.Printf("Scheduler: recurring payments: %v", err)
+	} else if n > 0 {
+		log.Printf("Scheduler: auto-posted %d recurring payment(s)", n)
+	}
+	if n, err := a.detectMissedPayments(now); err != nil {
+		log.Printf("Scheduler: missed payments: %v", err)
+	} else if n > 0 {
+		log.Printf("Scheduler: recorded %d missed payment(s)", n)
+	}
+	if n, err := a.sendDueReminders(now); err != nil {
+		log.Printf("Scheduler: reminders: %v", err)
+	} else if n > 0 {
+		log.Printf("Scheduler: sent %d reminder email(s)", n)
+	}
+	if n, err := a.refreshAllSalaryIncomes(now); err != nil {
+		log.Printf("Scheduler: salary incomes: %v", err)
+	} else if n > 0 {
+		log.Printf("Scheduler: updated %d salary budget income(s)", n)
+	}
+}
+
+// refreshAllSalaryIncomes brings every user's salary-following budgets up to date, so tax table
+// changes and budgets created in a new tax year pick up the right income. Returns the number of
+// budgets changed.
+func (a *App) refreshAllSalaryIncomes(now time.Time) (int, error) {
+	profiles, err := listSalaryProfiles(a.db)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, p := range profiles {
+		n, err := a.refreshSalaryIncome(p, now)
+		if err != nil {
+			log.Printf("Scheduler: salary incomes for user %d: %v", p.UserID, err)
+			continue
+		}
+		updated += n
+	}
+	return updated, nil
+}
+
+// followsSalary reports whether a budget's income still tracks the salary: it was set from the
+// salary and the month is open and not over. Past and closed months keep the income they had.
+func followsSalary(b Budget, now time.Time) bool {
+	return b.IncomeFromSalary && !b.ClosedAt.Valid && b.Year*12+b.Month >= now.Year()*12+int(now.Month())
+}
+
+// refreshSalaryIncome sets the income of the user's budgets that follow the salary (see
+// followsSalary) to its after-tax monthly pay. Returns the number of budgets changed.
+func (a *App) refreshSalaryIncome(p SalaryProfile, now time.Time) (int, error) {
+	budgets, err := listSalaryBudgets(a.db, p.UserID, now.Year(), int(now.Month()))
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, b := range budgets {
+		income := salaryNetPay(p, b.Year).MonthlyCents
+		if !followsSalary(b, now) || b.IncomeCents == income {
+			continue
+		}
+		if err := setBudgetSalaryIncome(a.db, p.UserID, b.ID, income); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// postDueRecurringPayments posts every occurrence of every active rule that falls on or before
+// today and after the rule's last posted occurrence. Returns the number of payments posted.
+func (a *App) postDueRecurringPayments(now time.Time) (int, error) {
+	rules, err := listActiveRecurringPayments(a.db)
+	if err != nil {
+		return 0, err
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	posted := 0
+	for _, rule := range rules {
+		for _, on := range recurringOccurrences(rule.RecurringPayment, today) {
+			id, err := postRecurringOccurrence(a.db, rule.UserID, rule.ID, on)
+			if err != nil {
+				log.Printf("Scheduler: rule %d on %s: %v", rule.ID, on.Format("2006-01-02"), err)
+				break
+			}
+			if id > 0 {
+				posted++
+				a.checkPaymentAlerts(rule.UserID, on)
+			}
+		}
+	}
+	return posted, nil
+}
+
+// recurringOccurrences returns the rule's due dates after LastPostedOn (or from StartDate)
+// up to and including today, honoring EndDate. Every missed date is returned, so downtime
+// does not lose payments; resuming a paused rule moves LastPostedOn past the pause instead.
+func recurringOccurrences(rp RecurringPayment, today time.Time) []time.Time {
+	from := rp.StartDate
+	if rp.LastPostedOn.Valid && !rp.LastPostedOn.Time.Before(from) {
+		from = rp.LastPostedOn.Time.AddDate(0, 0, 1)
+	}
+	until := today
+	if rp.EndDate.Valid && rp.EndDate.Time.Before(until) {
+		until = rp.EndDate.Time
+	}
+	var out []time.Time
+	d := time.Date(from.Year(), from.Month(), rp.DayOfMonth, 0, 0, 0, 0, time.UTC)
+	for !d.After(until) {
+		if !d.Before(from) {
+			out = append(out, d)
+		}
+		d = d.AddDate(0, 1, 0)
+	}
+	return out
+}
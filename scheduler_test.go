@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRecurringOccurrences(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	posted := func(s string) sql.NullTime { return sql.NullTime{Time: day(s), Valid: true} }
+	today := day("2025-03-15")
+	tests := []struct {
+		name string
+		rp   RecurringPayment
+		want []string
+	}{
+		{"due this month", RecurringPayment{DayOfMonth: 10, StartDate: day("2025-03-01")}, []string{"2025-03-10"}},
+		{"not due yet", RecurringPayment{DayOfMonth: 20, StartDate: day("2025-03-01")}, nil},
+		{"due today", RecurringPayment{DayOfMonth: 15, StartDate: day("2025-03-15")}, []string{"2025-03-15"}},
+		{"starts in the future", RecurringPayment{DayOfMonth: 10, StartDate: day("2025-04-01")}, nil},
+		{"already posted", RecurringPayment{DayOfMonth: 10, StartDate: day("2025-01-01"), LastPostedOn: posted("2025-03-10")}, nil},
+		{"next after last posted", RecurringPayment{DayOfMonth: 10, StartDate: day("2025-01-01"), LastPostedOn: posted("2025-02-10")}, []string{"2025-03-10"}},
+		{"catches up a missed run", RecurringPayment{DayOfMonth: 12, StartDate: day("2025-01-01"), LastPostedOn: posted("2025-02-12")}, []string{"2025-03-12"}},
+		{"catches up after long downtime", RecurringPayment{DayOfMonth: 1, StartDate: day("2024-12-01"), LastPostedOn: posted("2024-12-01")},
+			[]string{"2025-01-01", "2025-02-01", "2025-03-01"}},
+		{"resumed today skips the paused months", RecurringPayment{DayOfMonth: 10, StartDate: day("2024-01-01"), LastPostedOn: posted("2025-03-14")}, nil},
+		{"catch-up stops at the end date", RecurringPayment{DayOfMonth: 5, StartDate: day("2025-01-01"), LastPostedOn: posted("2025-01-05"), EndDate: posted("2025-02-28")},
+			[]string{"2025-02-05"}},
+		{"ended", RecurringPayment{DayOfMonth: 10, StartDate: day("2025-03-01"), EndDate: posted("2025-03-05")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recurringOccurrences(tt.rp, today)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, w := range tt.want {
+				if !got[i].Equal(day(w)) {
+					t.Errorf("occurrence %d = %s, want %s", i, got[i].Format("2006-01-02"), w)
+				}
+			}
+		})
+	}
+}
+
+func TestFollowsSalary(t *testing.T) {
+	now := date(t, "2025-03-15")
+	closed := sql.NullTime{Time: now, Valid: true}
+	tests := []struct {
+		name string
+		b    Budget
+		want bool
+	}{
+		{"this month", Budget{Year: 2025, Month: 3, IncomeFromSalary: true}, true},
+		{"next year", Budget{Year: 2026, Month: 1, IncomeFromSalary: true}, true},
+		{"last month", Budget{Year: 2025, Month: 2, IncomeFromSalary: true}, false},
+		{"closed", Budget{Year: 2025, Month: 4, IncomeFromSalary: true, ClosedAt: closed}, false},
+		{"income entered by hand", Budget{Year: 2025, Month: 3}, false},
+	}
+	for _, tt := range tests {
+		if got := followsSalary(tt.b, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// splitFormRows is how many allocation rows the split form shows beyond those already saved.
+const splitFormRows = 2
+
+// splitTotal sums a split's allocations.
+func splitTotal(allocs []SplitAllocation) int64 {
+	var total int64
+	for _, a := range allocs {
+		total += a.AmountCents
+	}
+	return total
+}
+
+// normalizeSplit drops empty rows and merges rows for the same category, then checks the
+// allocations cover at least two categories and add up to totalCents exactly.
+func normalizeSplit(totalCents int64, allocs []SplitAllocation) ([]SplitAllocation, error) {
+	var out []SplitAllocation
+	index := map[int64]int{}
+	for _, a := range allocs {
+		if a.AmountCents == 0 {
+			continue
+		}
+		if a.AmountCents < 0 || a.CategoryID <= 0 {
+			return nil, errors.New("each allocation needs a category and an amount greater than zero")
+		}
+		if i, ok := index[a.CategoryID]; ok {
+			out[i].AmountCents += a.AmountCents
+			continue
+		}
+		index[a.CategoryID] = len(out)
+		out = append(out, a)
+	}
+	if len(out) < 2 {
+		return nil, errors.New("a split needs at least two categories")
+	}
+	if sum := splitTotal(out); sum != totalCents {
+		return nil, fmt.Errorf("allocations add up to %s but the total is %s", money(sum), money(totalCents))
+	}
+	return out, nil
+}
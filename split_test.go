@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeSplit(t *testing.T) {
+	alloc := func(categoryID, cents int64) SplitAllocation {
+		return SplitAllocation{CategoryID: categoryID, AmountCents: cents}
+	}
+	tests := []struct {
+		name    string
+		total   int64
+		allocs  []SplitAllocation
+		want    []SplitAllocation
+		wantErr string
+	}{
+		{"two categories", 120_00, []SplitAllocation{alloc(1, 80_00), alloc(2, 40_00)},
+			[]SplitAllocation{alloc(1, 80_00), alloc(2, 40_00)}, ""},
+		{"empty rows dropped", 100_00, []SplitAllocation{alloc(1, 60_00), alloc(0, 0), alloc(2, 40_00), alloc(3, 0)},
+			[]SplitAllocation{alloc(1, 60_00), alloc(2, 40_00)}, ""},
+		{"same category merged", 100_00, []SplitAllocation{alloc(1, 30_00), alloc(2, 40_00), alloc(1, 30_00)},
+			[]SplitAllocation{alloc(1, 60_00), alloc(2, 40_00)}, ""},
+		{"one category after merging", 100_00, []SplitAllocation{alloc(1, 50_00), alloc(1, 50_00)},
+			nil, "a split needs at least two categories"},
+		{"negative amount", 100_00, []SplitAllocation{alloc(1, 150_00), alloc(2, -50_00)},
+			nil, "each allocation needs a category and an amount greater than zero"},
+		{"missing category", 100_00, []SplitAllocation{alloc(1, 50_00), alloc(0, 50_00)},
+			nil, "each allocation needs a category and an amount greater than zero"},
+		{"short by a cent", 100_00, []SplitAllocation{alloc(1, 66_66), alloc(2, 33_33)},
+			nil, "allocations add up to $99.99 but the total is $100.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeSplit(tt.total, tt.allocs)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if splitTotal(got) != tt.total {
+				t.Errorf("splitTotal = %d, want %d", splitTotal(got), tt.total)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import "fmt"
 
-// TaxBracket represents one combined (federal + provincial) tax bracket.
+// TaxBracket represents one tax bracket, federal, provincial or the two combined.
 // MaxCents is the top of the bracket in cents; Rate is e.g. 19.55 for 19.55%.
 type TaxBracket struct {
 	MaxCents int64   // upper bound of this bracket (cumulative)
@@ -19,102 +19,6 @@ type BracketFill struct {
 	FillPct              float64 // 0–100 for CSS width
 }
 
-// Province brackets: combined federal + provincial marginal rates (other income), 2025.
-// Source: TaxTips.ca and CRA. Amounts in cents.
-var provinceBrackets = map[string][]TaxBracket{
-	"ON": {
-		{5288600, 19.55},
-		{5737500, 23.65},
-		{9313200, 29.65},
-		{10577500, 31.48},
-		{10972700, 33.89},
-		{11475000, 37.91},
-		{15000000, 43.41},
-		{17788200, 44.97},
-		{22000000, 48.28},
-		{25341400, 49.84},
-		{9999999900, 53.53}, // top bracket
-	},
-	"BC": {
-		{4927900, 19.56},   // $0–$49,279
-		{9856000, 28.20},   // up to $98,560
-		{11315800, 31.00},
-		{13740700, 32.79},
-		{18630600, 38.29},
-		{25982900, 49.80},
-		{9999999900, 53.50},
-	},
-	"AB": {
-		{142292_00, 25.00},
-		{170751_00, 30.50},
-		{227668_00, 36.00},
-		{341502_00, 38.00},
-		{9999999900, 48.00},
-	},
-	"QC": {
-		{51425_00, 27.53},
-		{102865_00, 32.53},
-		{119545_00, 37.12},
-		{9999999900, 45.71},
-	},
-	"SK": {
-		{52057_00, 25.50},
-		{148734_00, 32.50},
-		{9999999900, 35.50},
-	},
-	"MB": {
-		{47000_00, 25.80},
-		{100000_00, 27.75},
-		{9999999900, 33.25},
-	},
-	"NS": {
-		{29590_00, 23.79},
-		{59180_00, 30.00},
-		{93000_00, 31.00},
-		{150000_00, 34.67},
-		{9999999900, 39.00},
-	},
-	"NB": {
-		{47715_00, 24.20},
-		{95431_00, 31.32},
-		{176756_00, 34.32},
-		{9999999900, 36.84},
-	},
-	"NL": {
-		{43198_00, 23.70},
-		{86395_00, 30.50},
-		{154244_00, 33.80},
-		{215000_00, 36.50},
-		{9999999900, 39.50},
-	},
-	"PE": {
-		{31984_00, 23.75},
-		{63969_00, 30.25},
-		{9999999900, 33.25},
-	},
-	"NT": {
-		{50897_00, 19.90},
-		{101792_00, 26.40},
-		{165429_00, 29.90},
-		{235675_00, 33.40},
-		{9999999900, 36.90},
-	},
-	"NU": {
-		{50897_00, 19.90},
-		{101792_00, 26.40},
-		{165429_00, 29.90},
-		{235675_00, 33.40},
-		{9999999900, 36.90},
-	},
-	"YT": {
-		{55867_00, 19.05},
-		{111733_00, 25.55},
-		{173205_00, 31.05},
-		{246752_00, 34.37},
-		{9999999900, 37.70},
-	},
-}
-
 var provinceNames = map[string]string{
 	"ON": "Ontario",
 	"BC": "British Columbia",
@@ -131,13 +35,9 @@ var provinceNames = map[string]string{
 	"YT": "Yukon",
 }
 
-// ComputeBracketFills returns a slice of BracketFill for the given province and income (cents).
+// ComputeBracketFills returns a slice of BracketFill for the given brackets and income (cents).
 // Total income and total tax are also computed.
-func ComputeBracketFills(province string, incomeCents int64) (fills []BracketFill, totalTaxCents int64) {
-	brackets, ok := provinceBrackets[province]
-	if !ok {
-		return nil, 0
-	}
+func ComputeBracketFills(brackets []TaxBracket, incomeCents int64) (fills []BracketFill, totalTaxCents int64) {
 	var prev int64
 	for _, b := range brackets {
 		bandTop := b.MaxCents
@@ -195,3 +95,53 @@ func formatDollars(cents int64) string {
 	}
 	return fmt.Sprintf("%d", d)
 }
+
+// DeductionWhatIf is the effect of a deduction, such as an RRSP or FHSA contribution, on the
+// income tax on a salary.
+type DeductionWhatIf struct {
+	DeductionCents int64
+	TaxableCents   int64 // taxable income after the deduction
+	TaxBeforeCents int64
+	TaxAfterCents  int64
+	RefundCents    int64   // tax saved, usually paid back as a refund
+	SavedAtPct     float64 // refund as a share of the deduction
+	FromRatePct    float64 // marginal rate on the last dollar before the deduction
+	ToRatePct      float64 // marginal rate on the last dollar after it
+}
+
+// ComputeDeduction works out how much tax a deduction saves on incomeCents of employment income
+// in province, with the same credits, surtax, abatement and health premium as ComputeNetPay.
+// The deduction is capped at the income. ok is false for an unknown province.
+func ComputeDeduction(t TaxTables, province string, incomeCents, deductionCents int64) (d DeductionWhatIf, ok bool) {
+	brackets, ok := t.combinedBrackets(province)
+	if !ok {
+		return DeductionWhatIf{}, false
+	}
+	deductionCents = min(max(deductionCents, 0), max(incomeCents, 0))
+	before, _ := computeNetPay(t, province, incomeCents, 0, "")
+	after, _ := computeNetPay(t, province, incomeCents, deductionCents, "")
+	d.DeductionCents = deductionCents
+	d.TaxableCents = after.TaxableCents
+	d.TaxBeforeCents = before.TaxCents
+	d.TaxAfterCents = after.TaxCents
+	d.RefundCents = d.TaxBeforeCents - d.TaxAfterCents
+	if deductionCents > 0 {
+		d.SavedAtPct = float64(d.RefundCents) / float64(deductionCents) * 100
+	}
+	d.FromRatePct = marginalRate(brackets, before.TaxableCents)
+	d.ToRatePct = marginalRate(brackets, after.TaxableCents)
+	return d, true
+}
+
+// marginalRate is the rate on the last dollar of incomeCents.
+func marginalRate(brackets []TaxBracket, incomeCents int64) float64 {
+	for _, b := range brackets {
+		if incomeCents <= b.MaxCents {
+			return b.RatePct
+		}
+	}
+	if len(brackets) == 0 {
+		return 0
+	}
+	return brackets[len(brackets)-1].RatePct
+}
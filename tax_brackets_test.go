@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// Refunds on 2025 salaries, worked by hand from the combined marginal rates on the slice of
+// taxable income the deduction removes.
+func TestComputeDeduction(t *testing.T) {
+	tables := taxTables(t, 2025)
+	tests := []struct {
+		name                   string
+		province               string
+		income, deduction      int64
+		wantTaxable, wantSaved int64
+		wantFrom, wantTo       float64
+	}{
+		// $59,435 taxable: $2,060 at 29.65% and $2,940 at 23.65%.
+		{"ON crosses a bracket", "ON", 60000_00, 5000_00, 54435_00, 1306_10, 29.65, 23.65},
+		// $148,926 taxable, all at 26% + 11.16% × 1.56 surtax.
+		{"ON surtax", "ON", 150000_00, 10000_00, 138926_00, 4340_96, 43.41, 43.41},
+		// 20.5% × (1 − 16.5% abatement) + 19%.
+		{"QC abatement", "QC", 100000_00, 10000_00, 88926_00, 3611_75, 36.12, 36.12},
+		// Credits already cover the tax on $15,000, so there is nothing to refund.
+		{"AB under the credits", "AB", 15000_00, 2000_00, 12885_00, 0, 22.5, 22.5},
+		{"capped at income", "AB", 15000_00, 20000_00, 0, 0, 22.5, 22.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := ComputeDeduction(tables, tt.province, tt.income, tt.deduction)
+			if !ok {
+				t.Fatal("unknown province")
+			}
+			if d.TaxableCents != tt.wantTaxable || d.RefundCents != tt.wantSaved {
+				t.Errorf("taxable %d, refund %d; want %d, %d", d.TaxableCents, d.RefundCents, tt.wantTaxable, tt.wantSaved)
+			}
+			if d.RefundCents != d.TaxBeforeCents-d.TaxAfterCents {
+				t.Errorf("refund %d is not %d - %d", d.RefundCents, d.TaxBeforeCents, d.TaxAfterCents)
+			}
+			if d.FromRatePct != tt.wantFrom || d.ToRatePct != tt.wantTo {
+				t.Errorf("rates %.2f%% to %.2f%%, want %.2f%% to %.2f%%", d.FromRatePct, d.ToRatePct, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+	if _, ok := ComputeDeduction(tables, "XX", 50000_00, 1000_00); ok {
+		t.Error("ComputeDeduction(XX) ok, want false")
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+)
+
+// Tax tables are data files, one per tax year, in taxdata/. Amounts there are in dollars and
+// rates in percent; a bracket without up_to is the open-ended top bracket. Add a year by adding
+// its file: every file is loaded and checked when the server starts.
+//
+//go:embed taxdata/*.json
+var taxDataFS embed.FS
+
+// topBracketCents stands in for "no upper bound" on the top bracket.
+const topBracketCents = 9999999900
+
+// TaxTables is one tax year: federal and provincial schedules, kept separate so credits can be
+// applied per jurisdiction, and the payroll rates.
+type TaxTables struct {
+	Year      int
+	Source    string
+	Federal   TaxSchedule
+	Provinces map[string]TaxSchedule
+	Payroll   PayrollRates
+	// Share of capital gains that is taxable, and the gross-ups that turn a dividend into
+	// taxable income, all in percent.
+	CapitalGainsInclusionPct float64
+	EligibleGrossUpPct       float64
+	NonEligibleGrossUpPct    float64
+}
+
+// taxYears holds every loaded year, keyed by year.
+var taxYears = map[int]TaxTables{}
+
+type taxFile struct {
+	Year                  int                        `json:"year"`
+	Source                string                     `json:"source"`
+	CapitalGainsInclusion float64                    `json:"capital_gains_inclusion"`
+	DividendGrossUp       dividendRatesFile          `json:"dividend_gross_up"`
+	Federal               taxScheduleFile            `json:"federal"`
+	Provinces             map[string]taxScheduleFile `json:"provinces"`
+	Payroll               payrollFile                `json:"payroll"`
+}
+
+type dividendRatesFile struct {
+	Eligible    float64 `json:"eligible"`
+	NonEligible float64 `json:"non_eligible"`
+}
+
+type taxScheduleFile struct {
+	Note     string `json:"note"`
+	Brackets []struct {
+		UpTo *float64 `json:"up_to"`
+		Rate float64  `json:"rate"`
+	} `json:"brackets"`
+	BasicPersonal    float64           `json:"basic_personal"`
+	BasicPersonalMin float64           `json:"basic_personal_min"`
+	PhaseOut         [2]float64        `json:"phase_out"`
+	EmploymentAmount float64           `json:"employment_amount"`
+	PayrollCredits   bool              `json:"payroll_credits"`
+	QuebecAbatement  float64           `json:"quebec_abatement"`
+	DividendCredit   dividendRatesFile `json:"dividend_credit"`
+	Surtax           []struct {
+		Over float64 `json:"over"`
+		Rate float64 `json:"rate"`
+	} `json:"surtax"`
+	HealthPremium []struct {
+		From float64 `json:"from"`
+		Rate float64 `json:"rate"`
+		Max  float64 `json:"max"`
+	} `json:"health_premium"`
+}
+
+type payrollFile struct {
+	YMPE                   float64 `json:"ympe"`
+	BasicExemption         float64 `json:"basic_exemption"`
+	CPPBaseRate            float64 `json:"cpp_base_rate"`
+	CPPFirstAdditionalRate float64 `json:"cpp_first_additional_rate"`
+	QPPBaseRate            float64 `json:"qpp_base_rate"`
+	QPPFirstAdditionalRate float64 `json:"qpp_first_additional_rate"`
+	YAMPE                  float64 `json:"yampe"`
+	SecondAdditionalRate   float64 `json:"second_additional_rate"`
+	EIMaxInsurable         float64 `json:"ei_max_insurable"`
+	EIRate                 float64 `json:"ei_rate"`
+	EIQuebecRate           float64 `json:"ei_quebec_rate"`
+	QPIPMaxInsurable       float64 `json:"qpip_max_insurable"`
+	QPIPRate               float64 `json:"qpip_rate"`
+}
+
+func dollarsToCents(d float64) int64 {
+	return int64(math.Round(d * 100))
+}
+
+// loadTaxTables reads and validates every embedded tax year. It is called once at startup; a
+// bad file stops the server rather than producing wrong estimates.
+func loadTaxTables() error {
+	names, err := taxDataFS.ReadDir("taxdata")
+	if err != nil {
+		return err
+	}
+	years := map[int]TaxTables{}
+	for _, entry := range names {
+		name := path.Join("taxdata", entry.Name())
+		data, err := taxDataFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var f taxFile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		t, err := f.tables()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if _, dup := years[t.Year]; dup {
+			return fmt.Errorf("%s: tax year %d is defined twice", name, t.Year)
+		}
+		years[t.Year] = t
+	}
+	if len(years) == 0 {
+		return fmt.Errorf("no tax tables in taxdata/")
+	}
+	taxYears = years
+	return nil
+}
+
+// tables converts and validates a file's contents.
+func (f taxFile) tables() (TaxTables, error) {
+	if f.Year < 2000 || f.Year > 2100 {
+		return TaxTables{}, fmt.Errorf("bad year %d", f.Year)
+	}
+	t := TaxTables{
+		Year:                     f.Year,
+		Source:                   f.Source,
+		Provinces:                map[string]TaxSchedule{},
+		CapitalGainsInclusionPct: f.CapitalGainsInclusion,
+		EligibleGrossUpPct:       f.DividendGrossUp.Eligible,
+		NonEligibleGrossUpPct:    f.DividendGrossUp.NonEligible,
+	}
+	if t.CapitalGainsInclusionPct <= 0 || t.CapitalGainsInclusionPct > 100 {
+		return TaxTables{}, fmt.Errorf("capital_gains_inclusion must be a percent above 0")
+	}
+	if t.EligibleGrossUpPct <= 0 || t.NonEligibleGrossUpPct <= 0 {
+		return TaxTables{}, fmt.Errorf("dividend_gross_up needs eligible and non_eligible rates")
+	}
+	var err error
+	if t.Federal, err = f.Federal.schedule(); err != nil {
+		return TaxTables{}, fmt.Errorf("federal: %v", err)
+	}
+	for code := range provinceNames {
+		pf, ok := f.Provinces[code]
+		if !ok {
+			return TaxTables{}, fmt.Errorf("missing province %s", code)
+		}
+		if t.Provinces[code], err = pf.schedule(); err != nil {
+			return TaxTables{}, fmt.Errorf("%s: %v", code, err)
+		}
+	}
+	for code := range f.Provinces {
+		if _, ok := provinceNames[code]; !ok {
+			return TaxTables{}, fmt.Errorf("unknown province %s", code)
+		}
+	}
+	if t.Payroll, err = f.Payroll.rates(); err != nil {
+		return TaxTables{}, fmt.Errorf("payroll: %v", err)
+	}
+	return t, nil
+}
+
+// schedule converts a bracket set, checking that brackets are sorted and contiguous: each
+// starts where the one before ended, and only the last is open-ended.
+func (f taxScheduleFile) schedule() (TaxSchedule, error) {
+	if len(f.Brackets) == 0 {
+		return TaxSchedule{}, fmt.Errorf("no brackets")
+	}
+	var s TaxSchedule
+	var prev int64
+	for i, b := range f.Brackets {
+		if b.Rate < 0 || b.Rate > 100 {
+			return TaxSchedule{}, fmt.Errorf("bracket %d: rate %v%% is out of range", i+1, b.Rate)
+		}
+		last := i == len(f.Brackets)-1
+		switch {
+		case last && b.UpTo != nil:
+			return TaxSchedule{}, fmt.Errorf("top bracket must not have up_to")
+		case last:
+			s.Brackets = append(s.Brackets, TaxBracket{topBracketCents, b.Rate})
+			continue
+		case b.UpTo == nil:
+			return TaxSchedule{}, fmt.Errorf("bracket %d: only the top bracket can leave out up_to", i+1)
+		}
+		top := dollarsToCents(*b.UpTo)
+		if top <= prev || top >= topBracketCents {
+			return TaxSchedule{}, fmt.Errorf("bracket %d: up_to %v is not above the bracket before", i+1, *b.UpTo)
+		}
+		s.Brackets = append(s.Brackets, TaxBracket{top, b.Rate})
+		prev = top
+	}
+	s.BasicPersonalCents = dollarsToCents(f.BasicPersonal)
+	s.BasicPersonalMinCents = dollarsToCents(f.BasicPersonalMin)
+	s.PhaseOutFromCents, s.PhaseOutToCents = dollarsToCents(f.PhaseOut[0]), dollarsToCents(f.PhaseOut[1])
+	if s.BasicPersonalCents <= 0 {
+		return TaxSchedule{}, fmt.Errorf("basic_personal is required")
+	}
+	if s.PhaseOutToCents != 0 && (s.PhaseOutToCents <= s.PhaseOutFromCents || s.BasicPersonalMinCents > s.BasicPersonalCents) {
+		return TaxSchedule{}, fmt.Errorf("phase_out must run from a lower to a higher income, down to basic_personal_min")
+	}
+	s.EmploymentAmountCents = dollarsToCents(f.EmploymentAmount)
+	s.PayrollCredits = f.PayrollCredits
+	s.AbatementPct = f.QuebecAbatement
+	s.EligibleDividendCreditPct = f.DividendCredit.Eligible
+	s.NonEligibleDividendCreditPct = f.DividendCredit.NonEligible
+	if s.EligibleDividendCreditPct <= 0 || s.NonEligibleDividendCreditPct <= 0 {
+		return TaxSchedule{}, fmt.Errorf("dividend_credit needs eligible and non_eligible rates")
+	}
+	for i, st := range f.Surtax {
+		tier := SurtaxTier{dollarsToCents(st.Over), st.Rate}
+		if i > 0 && tier.OverCents <= s.Surtax[i-1].OverCents {
+			return TaxSchedule{}, fmt.Errorf("surtax thresholds must increase")
+		}
+		s.Surtax = append(s.Surtax, tier)
+	}
+	for i, hp := range f.HealthPremium {
+		tier := PremiumTier{dollarsToCents(hp.From), hp.Rate, dollarsToCents(hp.Max)}
+		if i > 0 && (tier.FromCents <= s.HealthPremium[i-1].FromCents || tier.MaxCents < s.HealthPremium[i-1].MaxCents) {
+			return TaxSchedule{}, fmt.Errorf("health premium tiers must increase")
+		}
+		s.HealthPremium = append(s.HealthPremium, tier)
+	}
+	return s, nil
+}
+
+func (f payrollFile) rates() (PayrollRates, error) {
+	p := PayrollRates{
+		YMPECents:                 dollarsToCents(f.YMPE),
+		BasicExemptionCents:       dollarsToCents(f.BasicExemption),
+		CPPBaseRatePct:            f.CPPBaseRate,
+		CPPFirstAdditionalRatePct: f.CPPFirstAdditionalRate,
+		QPPBaseRatePct:            f.QPPBaseRate,
+		QPPFirstAdditionalRatePct: f.QPPFirstAdditionalRate,
+		YAMPECents:                dollarsToCents(f.YAMPE),
+		SecondAdditionalRatePct:   f.SecondAdditionalRate,
+		EIMaxInsurableCents:       dollarsToCents(f.EIMaxInsurable),
+		EIRatePct:                 f.EIRate,
+		EIQuebecRatePct:           f.EIQuebecRate,
+		QPIPMaxInsurableCents:     dollarsToCents(f.QPIPMaxInsurable),
+		QPIPRatePct:               f.QPIPRate,
+	}
+	if p.YMPECents <= p.BasicExemptionCents || p.YAMPECents < p.YMPECents {
+		return PayrollRates{}, fmt.Errorf("need basic_exemption < ympe <= yampe")
+	}
+	if p.EIMaxInsurableCents <= 0 || p.QPIPMaxInsurableCents <= 0 {
+		return PayrollRates{}, fmt.Errorf("ei_max_insurable and qpip_max_insurable are required")
+	}
+	return p, nil
+}
+
+// taxYearList returns the loaded tax years, newest first.
+func taxYearList() []int {
+	years := make([]int, 0, len(taxYears))
+	for y := range taxYears {
+		years = append(years, y)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return years
+}
+
+// taxTablesFor returns the tables for year: that year's, or the newest before it, or the oldest
+// loaded year when year is earlier than all of them.
+func taxTablesFor(year int) TaxTables {
+	years := taxYearList()
+	for _, y := range years {
+		if y <= year {
+			return taxYears[y]
+		}
+	}
+	return taxYears[years[len(years)-1]]
+}
+
+// combinedBrackets builds one set of combined federal and provincial marginal rates, breaking
+// at every threshold of either schedule and wherever the provincial surtax starts. The federal
+// rate is cut by the Quebec abatement in QC, and the provincial rate grows by each surtax tier
+// in force. ok is false for an unknown province.
+func (t TaxTables) combinedBrackets(province string) (out []TaxBracket, ok bool) {
+	prov, ok := t.Provinces[province]
+	if !ok {
+		return nil, false
+	}
+	fedShare := 1.0
+	if province == "QC" {
+		fedShare -= t.Federal.AbatementPct / 100
+	}
+	starts := prov.surtaxStarts()
+
+	var tops []int64
+	for _, b := range t.Federal.Brackets {
+		tops = append(tops, b.MaxCents)
+	}
+	for _, b := range prov.Brackets {
+		tops = append(tops, b.MaxCents)
+	}
+	tops = append(tops, starts...)
+	sort.Slice(tops, func(i, j int) bool { return tops[i] < tops[j] })
+
+	var prev int64
+	for _, top := range tops {
+		if top <= prev {
+			continue
+		}
+		surtax := 0.0
+		for i, s := range starts {
+			if s <= prev {
+				surtax += prov.Surtax[i].RatePct / 100
+			}
+		}
+		rate := marginalRate(t.Federal.Brackets, prev+1)*fedShare + marginalRate(prov.Brackets, prev+1)*(1+surtax)
+		rate = math.Round(rate*100) / 100
+		if n := len(out); n > 0 && out[n-1].RatePct == rate {
+			out[n-1].MaxCents = top
+		} else {
+			out = append(out, TaxBracket{top, rate})
+		}
+		prev = top
+	}
+	return out, true
+}
+
+// surtaxStarts is, for each surtax tier, the taxable income at which basic provincial tax after
+// the basic personal amount passes the tier's threshold (topBracketCents if it never does).
+func (s TaxSchedule) surtaxStarts() []int64 {
+	out := make([]int64, len(s.Surtax))
+	for i, tier := range s.Surtax {
+		lo, hi := int64(0), int64(topBracketCents)
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			tax, credits := scheduleTax(s, mid, 0, 0)
+			if tax-credits > tier.OverCents {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		out[i] = lo
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// The 2025 combined marginal rates published for each province, with Ontario's surtax (which
+// starts near $93,100 and $109,700 of taxable income) and the 16.5% Quebec abatement.
+func TestCombinedBracketsMarginalRates(t *testing.T) {
+	tables := taxTables(t, 2025)
+	tests := []struct {
+		province    string
+		incomeCents int64
+		want        float64
+	}{
+		{"ON", 50000_00, 19.55},
+		{"ON", 90000_00, 29.65},
+		{"ON", 100000_00, 31.48},
+		{"ON", 108000_00, 33.89},
+		{"ON", 112000_00, 37.91},
+		{"ON", 160000_00, 44.97},
+		{"ON", 300000_00, 53.53},
+		{"QC", 50000_00, 26.11},
+		{"QC", 100000_00, 36.12},
+		{"QC", 150000_00, 47.46},
+		{"QC", 300000_00, 53.31},
+		{"BC", 50000_00, 22.2},
+		{"BC", 120000_00, 38.29},
+		{"BC", 300000_00, 53.5},
+	}
+	for _, tt := range tests {
+		brackets, ok := tables.combinedBrackets(tt.province)
+		if !ok {
+			t.Fatalf("%s: unknown province", tt.province)
+		}
+		if got := marginalRate(brackets, tt.incomeCents); got != tt.want {
+			t.Errorf("%s at %d: marginal rate %.2f%%, want %.2f%%", tt.province, tt.incomeCents, got, tt.want)
+		}
+	}
+}
+
+func TestCombinedBracketsUnknownProvince(t *testing.T) {
+	if _, ok := taxTables(t, 2025).combinedBrackets("XX"); ok {
+		t.Error("combinedBrackets(XX) ok, want false")
+	}
+}